@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Заголовок с ID пользователя. Предполагается, что его выставляет шлюз после аутентификации
+const UserIDHeader = "X-User-ID"
+
+// Виды ошибок доступа для проверки через errors.Is
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrAccessDenied    = errors.New("access denied")
+)
+
+type User struct {
+	ID        string
+	Moderator bool
+}
+
+type ctxKey struct{}
+
+type Authenticator struct {
+	moderators map[string]bool // ID пользователей с правами модератора
+}
+
+func New(moderatorIDs []string) *Authenticator {
+	moderators := make(map[string]bool, len(moderatorIDs))
+	for _, id := range moderatorIDs {
+		moderators[id] = true
+	}
+	return &Authenticator{moderators: moderators}
+}
+
+// Разбор списка ID вида "id1,id2,id3"
+func ParseIDs(list string) []string {
+	var ids []string
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Middleware кладет пользователя из заголовков запроса в контекст
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(UserIDHeader))
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := &User{ID: id, Moderator: a.moderators[id]}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, ctxKey{}, user)
+}
+
+// Пользователь запроса, nil для анонимного
+func ForContext(ctx context.Context) *User {
+	user, _ := ctx.Value(ctxKey{}).(*User)
+	return user
+}
+
+func UserID(ctx context.Context) string {
+	if user := ForContext(ctx); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+func RequireUser(ctx context.Context) (*User, error) {
+	user := ForContext(ctx)
+	if user == nil {
+		return nil, ErrUnauthenticated
+	}
+	return user, nil
+}
+
+func RequireModerator(ctx context.Context) (*User, error) {
+	user, err := RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Moderator {
+		return nil, fmt.Errorf("%w: moderator role required", ErrAccessDenied)
+	}
+	return user, nil
+}
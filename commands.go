@@ -0,0 +1,102 @@
+package main
+
+import (
+	"PostAndComment/storage"
+	"PostAndComment/transfer"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Подкоманды сервера: перенос постов с комментариями между хранилищами.
+//
+//	server export [-space id] [-format ndjson|json] [-o файл]
+//	server import [-space id] [-i файл]
+//
+// Хранилище выбирается так же, как для сервера (STORAGE_TYPE и DB_*).
+// Команда работает с одним пространством, по умолчанию - с пространством default
+func runCommand(name string, args []string, s storage.Storage) error {
+	switch name {
+	case "export":
+		return runExport(args, s)
+	case "import":
+		return runImport(args, s)
+	default:
+		return fmt.Errorf("unknown command %q, use export or import", name)
+	}
+}
+
+func runExport(args []string, s storage.Storage) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", string(transfer.NDJSON), "output format: ndjson or json")
+	output := flags.String("o", "", "output file (stdout by default)")
+	space := flags.String("space", storage.DefaultSpace, "space to export")
+	flags.Parse(args)
+
+	s, err := inSpace(s, *space)
+	if err != nil {
+		return err
+	}
+
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := transfer.Export(w, s, f)
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d posts", count)
+	return nil
+}
+
+func runImport(args []string, s storage.Storage) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("i", "", "input file in ndjson or json format (stdin by default)")
+	space := flags.String("space", storage.DefaultSpace, "space to import into")
+	flags.Parse(args)
+
+	s, err := inSpace(s, *space)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	count, err := transfer.Import(r, s)
+	if err != nil {
+		return fmt.Errorf("imported %d posts before error: %w", count, err)
+	}
+	log.Printf("Imported %d posts", count)
+	return nil
+}
+
+// Хранилище, ограниченное пространством id
+func inSpace(s storage.Storage, id string) (storage.Storage, error) {
+	if err := storage.ValidateSpaceID(id); err != nil {
+		return nil, err
+	}
+	return storage.ForContext(storage.WithSpace(context.Background(), id), s), nil
+}
@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Участок разницы: текст, который совпадает, добавлен или удален
+type Chunk struct {
+	Op   Op
+	Text string
+}
+
+// Разница по словам. Пробелы и знаки препинания - отдельные элементы, поэтому склеенный
+// текст Equal и Delete дает старую версию, а Equal и Insert - новую
+func Words(from, to string) []Chunk {
+	return compute(splitWords(from), splitWords(to))
+}
+
+// Разница по строкам (перевод строки остается в конце своей строки)
+func Lines(from, to string) []Chunk {
+	return compute(splitLines(from), splitLines(to))
+}
+
+// Наибольшая общая подпоследовательность элементов. Тексты постов и комментариев короткие
+// (до нескольких тысяч символов), поэтому квадратичной таблицы достаточно
+func compute(a, b []string) []Chunk {
+	// lcs[i][j] - длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var chunks []Chunk
+	add := func(op Op, text string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += text
+			return
+		}
+		chunks = append(chunks, Chunk{Op: op, Text: text})
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(Equal, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, a[i])
+			i++
+		default:
+			add(Insert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(Delete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(Insert, b[j])
+	}
+
+	return chunks
+}
+
+// Слова (буквы и цифры), пробельные последовательности и отдельные знаки
+func splitWords(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph/model"
+	"context"
+	"fmt"
+)
+
+// Действие разрешено автору поста или модератору
+func (r *Resolver) requirePostOwner(ctx context.Context, postID string) (*auth.User, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Moderator {
+		return user, nil
+	}
+
+	post, err := r.storage(ctx).GetPost(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorID == nil || *post.AuthorID != user.ID {
+		return nil, fmt.Errorf("%w: only the post author or a moderator can do this", auth.ErrAccessDenied)
+	}
+	return user, nil
+}
+
+// Действие с комментарием разрешено автору его поста или модератору
+func (r *Resolver) requireCommentPostOwner(ctx context.Context, commentID string) error {
+	if _, err := auth.RequireUser(ctx); err != nil {
+		return err
+	}
+	if commentID == "" {
+		return invalidArgument("commentID can`t be empty")
+	}
+
+	comment, err := r.storage(ctx).GetComment(commentID)
+	if err != nil {
+		return err
+	}
+	_, err = r.requirePostOwner(ctx, comment.PostID)
+	return err
+}
+
+// Закрепление комментария: автор поста или модератор
+func (r *Resolver) setCommentPinned(ctx context.Context, commentID string, pinned bool, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	if err := r.requireCommentPostOwner(ctx, commentID); err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).SetCommentPinned(commentID, pinned, expectedVersion))
+	})
+}
+
+// Блокировка ветки: автор поста или модератор
+func (r *Resolver) setThreadLocked(ctx context.Context, commentID string, locked bool, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	if err := r.requireCommentPostOwner(ctx, commentID); err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).SetThreadLocked(commentID, locked, expectedVersion))
+	})
+}
+
+// Правка комментария разрешена его автору или модератору. Скрытый модерацией комментарий
+// автор править не может, иначе правка обходила бы решение модератора
+func (r *Resolver) requireCommentAuthor(ctx context.Context, commentID string) (*auth.User, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Moderator {
+		return user, nil
+	}
+
+	comment, err := r.storage(ctx).GetComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorID == nil || *comment.AuthorID != user.ID {
+		return nil, fmt.Errorf("%w: only the comment author or a moderator can do this", auth.ErrAccessDenied)
+	}
+	if comment.Status != model.ModerationStatusVisible {
+		return nil, fmt.Errorf("%w: comment is hidden by moderation", auth.ErrAccessDenied)
+	}
+	return user, nil
+}
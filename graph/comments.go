@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"PostAndComment/storage"
+	"errors"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	errCommentsClosed = "COMMENTS_CLOSED"
+	errThreadLocked   = "THREAD_LOCKED"
+	errSlowMode       = "SLOW_MODE"
+	errAnonymous      = "UNAUTHENTICATED"
+)
+
+// Правило закрытия комментариев нового поста: аргументы мутации поверх правила по умолчанию.
+// Явный 0 отключает ограничение, null оставляет значение по умолчанию
+func commentsPolicy(defaults storage.CommentsPolicy, closeAfterDays, maxComments *int32) storage.CommentsPolicy {
+	policy := defaults
+	if closeAfterDays != nil {
+		policy.CloseAfterDays = *closeAfterDays
+	}
+	if maxComments != nil {
+		policy.MaxComments = *maxComments
+	}
+	return policy
+}
+
+// Отказы правил обсуждения отдаются клиенту с отдельными кодами, чтобы их можно было отличить
+// от выключенных вручную комментариев. Для медленного режима extensions.retryAfter - секунды до следующей попытки
+func commentError(err error) error {
+	var slowMode *storage.SlowModeError
+	switch {
+	case errors.Is(err, storage.ErrCommentsClosed):
+		return codedError(err, errCommentsClosed)
+	case errors.Is(err, storage.ErrThreadLocked):
+		return codedError(err, errThreadLocked)
+	case errors.Is(err, storage.ErrAnonymous):
+		return codedError(err, errAnonymous)
+	case errors.As(err, &slowMode):
+		gqlErr := codedError(err, errSlowMode)
+		gqlErr.Extensions["retryAfter"] = int(math.Ceil(slowMode.RetryAfter.Seconds()))
+		return gqlErr
+	}
+	return err
+}
+
+// Ошибка с кодом в extensions. Исходная ошибка остается доступной через errors.Is и errors.As
+func codedError(err error, code string) *gqlerror.Error {
+	gqlErr := gqlerror.Wrap(err)
+	errcode.Set(gqlErr, code)
+	return gqlErr
+}
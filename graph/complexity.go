@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"math"
+	"time"
+)
+
+// Config с функциями стоимости полей для extension.ComplexityLimit
+func NewConfig(resolver *Resolver) Config {
+	cfg := Config{Resolvers: resolver}
+
+	cfg.Complexity.Query.GetPosts = func(childComplexity int, limit *int32, offset *int32, createdAfter *time.Time, createdBefore *time.Time) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Query.PostsByTag = func(childComplexity int, tag string, first *int32, after *string) int {
+		return listCost(childComplexity, first)
+	}
+	cfg.Complexity.Query.Tags = func(childComplexity int, prefix *string, limit *int32) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Query.Notifications = func(childComplexity int, limit *int32, offset *int32) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Query.MyDrafts = func(childComplexity int, limit *int32, offset *int32) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Query.ModerationQueue = func(childComplexity int, limit *int32, offset *int32) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Post.Comments = func(childComplexity int, limit *int32, offset *int32, createdAfter *time.Time, createdBefore *time.Time) int {
+		return listCost(childComplexity, limit)
+	}
+	cfg.Complexity.Comment.Replies = func(childComplexity int, limit *int32, offset *int32) int {
+		return listCost(childComplexity, limit)
+	}
+
+	return cfg
+}
+
+// Стоимость списка: каждый из limit элементов стоит как его поля.
+// Считаем по запрошенному limit, а не по MaxPageSize, чтобы клиент видел реальную цену запроса
+func listCost(childComplexity int, limit *int32) int {
+	size := int(DefaultPageSize)
+	if limit != nil && *limit > 0 {
+		size = int(*limit)
+	}
+
+	// Защита от переполнения при глубокой вложенности
+	if childComplexity > 0 && size > (math.MaxInt32-1)/childComplexity {
+		return math.MaxInt32
+	}
+	return size*childComplexity + 1
+}
@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+const defaultBatchWait = 2 * time.Millisecond // Сколько ждать остальные ключи пачки
+
+type loadersCtx struct{}
+
+// Загрузчики одной операции
+type Loaders struct {
+	comments *batchLoader[commentsKey, []*model.Comment]
+}
+
+// Ключ загрузки комментариев: пачка собирается из постов с одинаковой пагинацией и фильтром
+type commentsKey struct {
+	postID string
+	limit  int32
+	offset int32
+	filter timeRangeKey
+}
+
+// Сравнимое представление storage.TimeRange: время в наносекундах,
+// отсутствующие границы заменены на крайние значения
+type timeRangeKey struct {
+	after  int64
+	before int64
+}
+
+func newTimeRangeKey(r storage.TimeRange) timeRangeKey {
+	key := timeRangeKey{after: math.MinInt64, before: math.MaxInt64}
+	if r.After != nil {
+		key.after = r.After.UnixNano()
+	}
+	if r.Before != nil {
+		key.before = r.Before.UnixNano()
+	}
+	return key
+}
+
+func (k timeRangeKey) timeRange() storage.TimeRange {
+	var r storage.TimeRange
+	if k.after != math.MinInt64 {
+		after := time.Unix(0, k.after)
+		r.After = &after
+	}
+	if k.before != math.MaxInt64 {
+		before := time.Unix(0, k.before)
+		r.Before = &before
+	}
+	return r
+}
+
+func NewLoaders(s storage.Storage, wait time.Duration) *Loaders {
+	return &Loaders{
+		comments: newBatchLoader(wait, func(keys []commentsKey) map[commentsKey]loadResult[[]*model.Comment] {
+			return loadCommentsTrees(s, keys)
+		}),
+	}
+}
+
+// Комментарии для пачки постов: один вызов хранилища на каждую комбинацию limit/offset/фильтра
+func loadCommentsTrees(s storage.Storage, keys []commentsKey) map[commentsKey]loadResult[[]*model.Comment] {
+	type page struct {
+		limit, offset int32
+		filter        timeRangeKey
+	}
+	postsByPage := make(map[page][]string)
+	for _, key := range keys {
+		p := page{key.limit, key.offset, key.filter}
+		postsByPage[p] = append(postsByPage[p], key.postID)
+	}
+
+	results := make(map[commentsKey]loadResult[[]*model.Comment], len(keys))
+	for p, postIDs := range postsByPage {
+		trees, err := s.GetCommentsTrees(postIDs, p.limit, p.offset, p.filter.timeRange())
+		for _, postID := range postIDs {
+			key := commentsKey{postID, p.limit, p.offset, p.filter}
+			switch comments, ok := trees[postID]; {
+			case err != nil:
+				results[key] = loadResult[[]*model.Comment]{err: err}
+			case !ok:
+				results[key] = loadResult[[]*model.Comment]{err: storage.PostNotFound(postID)}
+			default:
+				results[key] = loadResult[[]*model.Comment]{value: comments}
+			}
+		}
+	}
+
+	return results
+}
+
+// Расширение gqlgen: свои загрузчики на каждую операцию
+type Dataloaders struct {
+	Storage storage.Storage
+	Wait    time.Duration
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = Dataloaders{}
+
+func (d Dataloaders) ExtensionName() string {
+	return "Dataloaders"
+}
+
+func (d Dataloaders) Validate(schema graphql.ExecutableSchema) error {
+	if d.Storage == nil {
+		return fmt.Errorf("dataloader storage can not be nil")
+	}
+	return nil
+}
+
+func (d Dataloaders) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	wait := d.Wait
+	if wait <= 0 {
+		wait = defaultBatchWait
+	}
+	return next(context.WithValue(ctx, loadersCtx{}, NewLoaders(storage.ForContext(ctx, d.Storage), wait)))
+}
+
+// Загрузчики операции. Без расширения (например, в тестах резолверов) пачки из одного ключа
+func loadersFor(ctx context.Context, s storage.Storage) *Loaders {
+	if loaders, ok := ctx.Value(loadersCtx{}).(*Loaders); ok {
+		return loaders
+	}
+	return NewLoaders(s, 0)
+}
+
+type loadResult[V any] struct {
+	value V
+	err   error
+}
+
+// Пачка ключей, собранная за время ожидания
+type batch[K comparable, V any] struct {
+	keys    []K
+	results map[K]loadResult[V]
+	done    chan struct{}
+}
+
+// Простой dataloader: собирает ключи в течение wait и загружает их одним вызовом fetch.
+// Результаты между пачками не кешируются, чтобы подписки не получали устаревших данных
+type batchLoader[K comparable, V any] struct {
+	wait  time.Duration
+	fetch func(keys []K) map[K]loadResult[V]
+
+	mu      sync.Mutex
+	current *batch[K, V]
+}
+
+func newBatchLoader[K comparable, V any](wait time.Duration, fetch func(keys []K) map[K]loadResult[V]) *batchLoader[K, V] {
+	return &batchLoader[K, V]{wait: wait, fetch: fetch}
+}
+
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	b := l.current
+	if b == nil {
+		b = &batch[K, V]{done: make(chan struct{})}
+		l.current = b
+		go l.dispatch(b)
+	}
+	if !containsKey(b.keys, key) {
+		b.keys = append(b.keys, key)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+		result := b.results[key]
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (l *batchLoader[K, V]) dispatch(b *batch[K, V]) {
+	if l.wait > 0 {
+		time.Sleep(l.wait)
+	}
+
+	// Новые ключи идут уже в следующую пачку
+	l.mu.Lock()
+	if l.current == b {
+		l.current = nil
+	}
+	keys := b.keys
+	l.mu.Unlock()
+
+	b.results = l.fetch(keys)
+	close(b.done)
+}
+
+func containsKey[K comparable](keys []K, key K) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
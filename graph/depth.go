@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// Расширение gqlgen: ограничение глубины вложенности запроса
+type DepthLimit struct {
+	Max int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = DepthLimit{}
+
+func (d DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (d DepthLimit) Validate(schema graphql.ExecutableSchema) error {
+	if d.Max <= 0 {
+		return fmt.Errorf("depth limit must be positive")
+	}
+	return nil
+}
+
+func (d DepthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+
+	depth := selectionDepth(op.SelectionSet, map[string]bool{})
+	if depth > d.Max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.Max)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+
+	return nil
+}
+
+// Глубина набора полей. Поля интроспекции не учитываются
+func selectionDepth(selections ast.SelectionSet, visited map[string]bool) int {
+	maxDepth := 0
+	for _, selection := range selections {
+		depth := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(s.SelectionSet, visited)
+		case *ast.InlineFragment:
+			depth = selectionDepth(s.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			if s.Definition == nil || visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			depth = selectionDepth(s.Definition.SelectionSet, visited)
+			delete(visited, s.Name)
+		}
+
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	return maxDepth
+}
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+	"time"
+)
+
+// Проверка состояния нового поста. Черновик и отложенный пост нужны автору, чтобы найти их
+// в myDrafts, поэтому анонимно создать их нельзя
+func applyPostStatus(ctx context.Context, params *storage.NewPostParams, status *model.PostStatus, publishAt *time.Time) error {
+	s := model.PostStatusPublished
+	if status != nil {
+		s = *status
+	}
+	if s != model.PostStatusPublished {
+		if _, err := auth.RequireUser(ctx); err != nil {
+			return err
+		}
+	}
+
+	switch s {
+	case model.PostStatusScheduled:
+		if publishAt == nil {
+			return invalidArgument("publishAt is required for scheduled posts")
+		}
+		if !publishAt.After(time.Now()) {
+			return invalidArgument("publishAt must be in the future")
+		}
+		params.PublishAt = publishAt
+	case model.PostStatusDraft:
+		params.Draft = true
+		fallthrough
+	default:
+		if publishAt != nil {
+			return invalidArgument("publishAt can only be set for scheduled posts")
+		}
+	}
+	return nil
+}
+
+// Неопубликованный пост виден только автору и модераторам, остальным - как несуществующий
+func (r *Resolver) visiblePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	if post.Status == model.PostStatusPublished {
+		return post, nil
+	}
+
+	user := auth.ForContext(ctx)
+	if user != nil && (user.Moderator || (post.AuthorID != nil && *post.AuthorID == user.ID)) {
+		return post, nil
+	}
+	return nil, storage.PostNotFound(post.ID)
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Мутация с ключом идемпотентности clientMutationID: ключ занимается до выполнения run,
+// ответ запоминается после. Повтор с тем же ключом получает запомненный ответ, не выполняя run.
+// Проверки доступа выполняются до вызова, поэтому повтор проходит их заново
+func idempotent[T any](ctx context.Context, r *Resolver, clientMutationID *string, run func() (T, error)) (T, error) {
+	if clientMutationID == nil {
+		return run()
+	}
+
+	var zero T
+	s := r.storage(ctx)
+	// Анонимные клиенты делят одно пространство ключей, поэтому ключ должен быть случайным (UUID)
+	key := storage.IdempotencyKey{Scope: auth.UserID(ctx), Key: *clientMutationID}
+	operation := operationName(ctx)
+
+	rec, err := s.ClaimIdempotencyKey(key, operation, r.idempotencyTTL())
+	if err != nil {
+		return zero, err
+	}
+	if rec != nil {
+		var result T
+		if err := json.Unmarshal(rec.Result, &result); err != nil {
+			return zero, fmt.Errorf("failed to decode result of %s: %w", operation, err)
+		}
+		return result, nil
+	}
+
+	result, err := run()
+	if err != nil {
+		if releaseErr := s.ReleaseIdempotencyKey(key); releaseErr != nil {
+			log.Printf("Failed to release clientMutationId of %s: %v", operation, releaseErr)
+		}
+		return result, err
+	}
+
+	// Мутация уже выполнена: без сохраненного ответа повтор после IdempotencyPendingTimeout выполнит ее снова
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = s.CompleteIdempotencyKey(key, data)
+	}
+	if err != nil {
+		log.Printf("Failed to save result of %s for clientMutationId: %v", operation, err)
+	}
+	return result, nil
+}
+
+type operationCtx struct{}
+
+// Контекст вызова резолвера мутации name вне GraphQL (REST API). Имя совпадает с полем мутации,
+// поэтому повтор с тем же ключом через другой API получает тот же ответ
+func WithOperation(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationCtx{}, name)
+}
+
+func operationName(ctx context.Context) string {
+	if name, ok := ctx.Value(operationCtx{}).(string); ok {
+		return name
+	}
+	return graphql.GetFieldContext(ctx).Field.Name
+}
+
+func (r *Resolver) idempotencyTTL() time.Duration {
+	if r.IdempotencyTTL > 0 {
+		return r.IdempotencyTTL
+	}
+	return storage.DefaultIdempotencyTTL
+}
@@ -2,28 +2,602 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
 type Comment struct {
-	ID        string     `json:"id"`
-	PostID    string     `json:"postID"`
-	ParentID  *string    `json:"parentID,omitempty"`
-	Text      string     `json:"text"`
-	Replies   []*Comment `json:"replies"`
-	CreatedAt string     `json:"createdAt"`
+	ID              string           `json:"id"`
+	PostID          string           `json:"postID"`
+	ParentID        *string          `json:"parentID,omitempty"`
+	AuthorID        *string          `json:"authorID,omitempty"`
+	Text            string           `json:"text"`
+	Mentions        []*Mention       `json:"mentions"`
+	Hashtags        []*Hashtag       `json:"hashtags"`
+	Replies         []*Comment       `json:"replies"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	EditedAt        *time.Time       `json:"editedAt,omitempty"`
+	Revisions       []*Revision      `json:"revisions"`
+	Diff            []*DiffChunk     `json:"diff"`
+	Status          ModerationStatus `json:"status"`
+	IsPinned        bool             `json:"isPinned"`
+	IsLocked        bool             `json:"isLocked"`
+	ReplyCount      int32            `json:"replyCount"`
+	DescendantCount int32            `json:"descendantCount"`
+	Version         int32            `json:"version"`
+}
+
+type DiffChunk struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+type Hashtag struct {
+	Tag    string `json:"tag"`
+	Offset int32  `json:"offset"`
+	Length int32  `json:"length"`
+}
+
+type Mention struct {
+	Username string `json:"username"`
+	Offset   int32  `json:"offset"`
+	Length   int32  `json:"length"`
+}
+
+type ModerationDecision struct {
+	ID          string           `json:"id"`
+	TargetID    string           `json:"targetID"`
+	ModeratorID string           `json:"moderatorID"`
+	Action      ModerationAction `json:"action"`
+	Reason      *string          `json:"reason,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+type ModerationItem struct {
+	Comment     *Comment  `json:"comment"`
+	ReportCount int32     `json:"reportCount"`
+	Reports     []*Report `json:"reports"`
+}
+
+type ModerationQueue struct {
+	Items         []*ModerationItem `json:"items"`
+	Total         int32             `json:"total"`
+	ReportedCount int32             `json:"reportedCount"`
+	HeldCount     int32             `json:"heldCount"`
 }
 
 type Mutation struct {
 }
 
+type Notification struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"userID"`
+	Kind      NotificationKind `json:"kind"`
+	PostID    string           `json:"postID"`
+	CommentID *string          `json:"commentID,omitempty"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
 type Post struct {
-	ID              string     `json:"id"`
-	Text            string     `json:"text"`
-	CommentsEnabled bool       `json:"commentsEnabled"`
-	CreatedAt       string     `json:"createdAt"`
-	Comments        []*Comment `json:"comments"`
+	ID               string       `json:"id"`
+	AuthorID         *string      `json:"authorID,omitempty"`
+	Title            string       `json:"title"`
+	Slug             string       `json:"slug"`
+	Text             string       `json:"text"`
+	Tags             []string     `json:"tags"`
+	Mentions         []*Mention   `json:"mentions"`
+	Hashtags         []*Hashtag   `json:"hashtags"`
+	CommentsEnabled  bool         `json:"commentsEnabled"`
+	CommentsCloseAt  *time.Time   `json:"commentsCloseAt,omitempty"`
+	MaxComments      *int32       `json:"maxComments,omitempty"`
+	SlowModeSeconds  int32        `json:"slowModeSeconds"`
+	Status           PostStatus   `json:"status"`
+	PublishAt        *time.Time   `json:"publishAt,omitempty"`
+	CreatedAt        time.Time    `json:"createdAt"`
+	EditedAt         *time.Time   `json:"editedAt,omitempty"`
+	Revisions        []*Revision  `json:"revisions"`
+	Diff             []*DiffChunk `json:"diff"`
+	Comments         []*Comment   `json:"comments"`
+	CommentCount     int32        `json:"commentCount"`
+	RootCommentCount int32        `json:"rootCommentCount"`
+	Version          int32        `json:"version"`
+}
+
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
 }
 
 type Query struct {
 }
 
+type Report struct {
+	ID         string    `json:"id"`
+	TargetID   string    `json:"targetID"`
+	ReporterID string    `json:"reporterID"`
+	Reason     string    `json:"reason"`
+	Resolved   bool      `json:"resolved"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type Revision struct {
+	Number    int32     `json:"number"`
+	Text      string    `json:"text"`
+	EditorID  *string   `json:"editorID,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Space struct {
+	ID    string   `json:"id"`
+	Hosts []string `json:"hosts"`
+}
+
 type Subscription struct {
 }
+
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int32  `json:"postCount"`
+}
+
+type DiffOp string
+
+const (
+	DiffOpEqual  DiffOp = "EQUAL"
+	DiffOpInsert DiffOp = "INSERT"
+	DiffOpDelete DiffOp = "DELETE"
+)
+
+var AllDiffOp = []DiffOp{
+	DiffOpEqual,
+	DiffOpInsert,
+	DiffOpDelete,
+}
+
+func (e DiffOp) IsValid() bool {
+	switch e {
+	case DiffOpEqual, DiffOpInsert, DiffOpDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffOp) String() string {
+	return string(e)
+}
+
+func (e *DiffOp) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffOp(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffOp", str)
+	}
+	return nil
+}
+
+func (e DiffOp) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DiffOp) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DiffOp) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type DiffUnit string
+
+const (
+	DiffUnitWord DiffUnit = "WORD"
+	DiffUnitLine DiffUnit = "LINE"
+)
+
+var AllDiffUnit = []DiffUnit{
+	DiffUnitWord,
+	DiffUnitLine,
+}
+
+func (e DiffUnit) IsValid() bool {
+	switch e {
+	case DiffUnitWord, DiffUnitLine:
+		return true
+	}
+	return false
+}
+
+func (e DiffUnit) String() string {
+	return string(e)
+}
+
+func (e *DiffUnit) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffUnit(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffUnit", str)
+	}
+	return nil
+}
+
+func (e DiffUnit) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DiffUnit) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DiffUnit) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ExportFormat string
+
+const (
+	ExportFormatNdjson ExportFormat = "NDJSON"
+	ExportFormatJSON   ExportFormat = "JSON"
+)
+
+var AllExportFormat = []ExportFormat{
+	ExportFormatNdjson,
+	ExportFormatJSON,
+}
+
+func (e ExportFormat) IsValid() bool {
+	switch e {
+	case ExportFormatNdjson, ExportFormatJSON:
+		return true
+	}
+	return false
+}
+
+func (e ExportFormat) String() string {
+	return string(e)
+}
+
+func (e *ExportFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ExportFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ExportFormat", str)
+	}
+	return nil
+}
+
+func (e ExportFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ExportFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ExportFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ModerationAction string
+
+const (
+	ModerationActionApprove ModerationAction = "APPROVE"
+	ModerationActionReject  ModerationAction = "REJECT"
+	ModerationActionHide    ModerationAction = "HIDE"
+)
+
+var AllModerationAction = []ModerationAction{
+	ModerationActionApprove,
+	ModerationActionReject,
+	ModerationActionHide,
+}
+
+func (e ModerationAction) IsValid() bool {
+	switch e {
+	case ModerationActionApprove, ModerationActionReject, ModerationActionHide:
+		return true
+	}
+	return false
+}
+
+func (e ModerationAction) String() string {
+	return string(e)
+}
+
+func (e *ModerationAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationAction", str)
+	}
+	return nil
+}
+
+func (e ModerationAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ModerationAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ModerationAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ModerationStatus string
+
+const (
+	ModerationStatusVisible  ModerationStatus = "VISIBLE"
+	ModerationStatusHeld     ModerationStatus = "HELD"
+	ModerationStatusHidden   ModerationStatus = "HIDDEN"
+	ModerationStatusRejected ModerationStatus = "REJECTED"
+)
+
+var AllModerationStatus = []ModerationStatus{
+	ModerationStatusVisible,
+	ModerationStatusHeld,
+	ModerationStatusHidden,
+	ModerationStatusRejected,
+}
+
+func (e ModerationStatus) IsValid() bool {
+	switch e {
+	case ModerationStatusVisible, ModerationStatusHeld, ModerationStatusHidden, ModerationStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e ModerationStatus) String() string {
+	return string(e)
+}
+
+func (e *ModerationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationStatus", str)
+	}
+	return nil
+}
+
+func (e ModerationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ModerationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ModerationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationKind string
+
+const (
+	NotificationKindMention NotificationKind = "MENTION"
+)
+
+var AllNotificationKind = []NotificationKind{
+	NotificationKindMention,
+}
+
+func (e NotificationKind) IsValid() bool {
+	switch e {
+	case NotificationKindMention:
+		return true
+	}
+	return false
+}
+
+func (e NotificationKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationKind", str)
+	}
+	return nil
+}
+
+func (e NotificationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "DRAFT"
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PostStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PostStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type TextFormat string
+
+const (
+	TextFormatMarkdown TextFormat = "MARKDOWN"
+	TextFormatHTML     TextFormat = "HTML"
+)
+
+var AllTextFormat = []TextFormat{
+	TextFormatMarkdown,
+	TextFormatHTML,
+}
+
+func (e TextFormat) IsValid() bool {
+	switch e {
+	case TextFormatMarkdown, TextFormatHTML:
+		return true
+	}
+	return false
+}
+
+func (e TextFormat) String() string {
+	return string(e)
+}
+
+func (e *TextFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TextFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TextFormat", str)
+	}
+	return nil
+}
+
+func (e TextFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *TextFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e TextFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
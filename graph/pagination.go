@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"PostAndComment/storage"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize int32 = 10  // Размер страницы, если limit не указан
+	MaxPageSize     int32 = 100 // Максимальный limit для списков
+)
+
+// Проверка аргументов пагинации и значения по умолчанию
+func pageArgs(limit, offset *int32) (int32, int32, error) {
+	lim, off := DefaultPageSize, int32(0)
+	if limit != nil {
+		lim = *limit
+		if lim < 0 {
+			return 0, 0, invalidArgument("limit must be non-negative")
+		}
+		if lim > MaxPageSize {
+			return 0, 0, invalidArgument("limit must not exceed %d", MaxPageSize)
+		}
+	}
+	if offset != nil {
+		off = *offset
+		if off < 0 {
+			return 0, 0, invalidArgument("offset must be non-negative")
+		}
+	}
+
+	return lim, off, nil
+}
+
+// Проверка аргумента first для выдачи по курсору
+func firstArg(first *int32) (int32, error) {
+	if first == nil {
+		return DefaultPageSize, nil
+	}
+	if *first < 0 {
+		return 0, invalidArgument("first must be non-negative")
+	}
+	if *first > MaxPageSize {
+		return 0, invalidArgument("first must not exceed %d", MaxPageSize)
+	}
+	return *first, nil
+}
+
+// Курсор для клиента: непрозрачная строка из времени создания и ID
+func encodeCursor(c storage.PostCursor) string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*storage.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidArgument("invalid cursor")
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, invalidArgument("invalid cursor")
+	}
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, invalidArgument("invalid cursor")
+	}
+
+	return &storage.PostCursor{CreatedAt: time.Unix(0, ts), ID: id}, nil
+}
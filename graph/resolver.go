@@ -4,8 +4,30 @@ package graph
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
-import "PostAndComment/storage"
+import (
+	"PostAndComment/markdown"
+	"PostAndComment/storage"
+	"context"
+	"time"
+)
 
 type Resolver struct {
-	Storage storage.Storage
+	Storage  storage.Storage
+	Markdown *markdown.Renderer // Рендер Markdown в HTML, nil - общий рендер по умолчанию
+
+	// Правило закрытия комментариев для новых постов, если не задано свое. Копируется в пост при создании,
+	// поэтому посты, созданные до смены правила, остаются со старым
+	CommentsPolicy storage.CommentsPolicy
+
+	IdempotencyTTL time.Duration // Сколько помнить ответ мутации с clientMutationId, 0 - storage.DefaultIdempotencyTTL
+}
+
+// Хранилище для запроса ctx: с репликами чтение запроса, который уже что-то записал, идет в основную базу
+func (r *Resolver) storage(ctx context.Context) storage.Storage {
+	return storage.ForContext(ctx, r.Storage)
+}
+
+// Отказ проверки аргументов. Вид ошибки отличает его от внутренних ошибок, например в REST API
+func invalidArgument(format string, args ...any) error {
+	return storage.Errorf(storage.ErrInvalidArgument, format, args...)
 }
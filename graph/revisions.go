@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"PostAndComment/diff"
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+)
+
+var diffOps = map[diff.Op]model.DiffOp{
+	diff.Equal:  model.DiffOpEqual,
+	diff.Insert: model.DiffOpInsert,
+	diff.Delete: model.DiffOpDelete,
+}
+
+// Разница между двумя версиями текста поста или комментария
+func (r *Resolver) revisionsDiff(ctx context.Context, targetID string, fromRevision, toRevision int32, unit *model.DiffUnit) ([]*model.DiffChunk, error) {
+	revisions, err := r.storage(ctx).GetRevisions(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make(map[int32]string, len(revisions))
+	for _, revision := range revisions {
+		texts[revision.Number] = revision.Text
+	}
+	from, ok := texts[fromRevision]
+	if !ok {
+		return nil, storage.Errorf(storage.ErrNotFound, "revision %d not found", fromRevision)
+	}
+	to, ok := texts[toRevision]
+	if !ok {
+		return nil, storage.Errorf(storage.ErrNotFound, "revision %d not found", toRevision)
+	}
+
+	var chunks []diff.Chunk
+	if unit != nil && *unit == model.DiffUnitLine {
+		chunks = diff.Lines(from, to)
+	} else {
+		chunks = diff.Words(from, to)
+	}
+
+	result := make([]*model.DiffChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		result = append(result, &model.DiffChunk{Op: diffOps[chunk.Op], Text: chunk.Text})
+	}
+	return result, nil
+}
@@ -5,29 +5,31 @@ package graph
 // Code generated by github.com/99designs/gqlgen version v0.17.75
 
 import (
+	"PostAndComment/auth"
 	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tenant"
+	"PostAndComment/transfer"
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
+// Text is the resolver for the text field.
+func (r *commentResolver) Text(ctx context.Context, obj *model.Comment, format *model.TextFormat) (string, error) {
+	return r.formatText(obj.Text, format)
+}
+
 // Replies is the resolver for the replies field.
 func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, limit *int32, offset *int32) ([]*model.Comment, error) {
-	if obj.Replies == nil {
-		return []*model.Comment{}, nil
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	var lim, off int32 = 10, 0
-	if limit != nil {
-		lim = *limit
-		if lim < 0 {
-			return nil, fmt.Errorf("limit must be non-negative")
-		}
-	}
-	if offset != nil {
-		off = *offset
-		if off < 0 {
-			return nil, fmt.Errorf("offset must be non-negative")
-		}
+	if obj.Replies == nil {
+		return []*model.Comment{}, nil
 	}
 
 	if int(off) >= len(obj.Replies) {
@@ -42,102 +44,496 @@ func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, limit
 	return obj.Replies[off:end], nil
 }
 
+// Revisions is the resolver for the revisions field.
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment) ([]*model.Revision, error) {
+	// Версии скрытого комментария показывали бы скрытый текст
+	if obj.Status != model.ModerationStatusVisible {
+		return []*model.Revision{}, nil
+	}
+	return r.storage(ctx).GetRevisions(obj.ID)
+}
+
+// Diff is the resolver for the diff field.
+func (r *commentResolver) Diff(ctx context.Context, obj *model.Comment, fromRevision int32, toRevision int32, unit *model.DiffUnit) ([]*model.DiffChunk, error) {
+	if obj.Status != model.ModerationStatusVisible {
+		return nil, storage.Errorf(auth.ErrAccessDenied, "revisions of a moderated comment are not available")
+	}
+	return r.revisionsDiff(ctx, obj.ID, fromRevision, toRevision, unit)
+}
+
 // AddComment is the resolver for the addComment field.
-func (r *mutationResolver) AddComment(ctx context.Context, postID string, parentID *string, text string) (*model.Comment, error) {
+func (r *mutationResolver) AddComment(ctx context.Context, postID string, parentID *string, text string, clientMutationID *string) (*model.Comment, error) {
+	if postID == "" {
+		return nil, invalidArgument("postID can`t be empty")
+	}
+
+	size := len([]rune(text))
+	if size > 2000 {
+		return nil, invalidArgument("message too long: maximum allowed is 2000 characters")
+	}
+
+	if text == "" {
+		return nil, invalidArgument("message must contain at least one character")
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Comment, error) {
+		comment, err := r.storage(ctx).AddComment(storage.NewCommentParams{
+			PostID: postID, ParentID: parentID, AuthorID: auth.UserID(ctx), Text: text,
+		})
+		if err != nil {
+			return nil, commentError(err)
+		}
+		return comment, nil
+	})
+}
 
+// EditPost is the resolver for the editPost field.
+func (r *mutationResolver) EditPost(ctx context.Context, postID string, text string, expectedVersion *int32, clientMutationID *string) (*model.Post, error) {
 	if postID == "" {
-		return nil, fmt.Errorf("postID can`t be empty")
+		return nil, invalidArgument("postID can`t be empty")
 	}
 
 	size := len([]rune(text))
 	if size > 2000 {
-		return nil, fmt.Errorf("message too long: maximum allowed is 2000 characters")
+		return nil, invalidArgument("message too long: maximum allowed is 2000 characters")
 	}
 
 	if text == "" {
-		return nil, fmt.Errorf("message must contain at least one character")
+		return nil, invalidArgument("message must contain at least one character")
 	}
 
-	return r.Storage.AddComment(postID, parentID, text)
+	user, err := r.requirePostOwner(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return versioned(r.storage(ctx).EditPost(postID, user.ID, text, expectedVersion))
+	})
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, commentID string, text string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	if commentID == "" {
+		return nil, invalidArgument("commentID can`t be empty")
+	}
+
+	size := len([]rune(text))
+	if size > 2000 {
+		return nil, invalidArgument("message too long: maximum allowed is 2000 characters")
+	}
+
+	if text == "" {
+		return nil, invalidArgument("message must contain at least one character")
+	}
+
+	user, err := r.requireCommentAuthor(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).EditComment(commentID, user.ID, text, expectedVersion))
+	})
 }
 
 // AddPost is the resolver for the addPost field.
-func (r *mutationResolver) NewPost(ctx context.Context, text string, commentsEnabled bool) (*model.Post, error) {
+func (r *mutationResolver) NewPost(ctx context.Context, title *string, text string, tags []string, commentsEnabled bool, status *model.PostStatus, publishAt *time.Time, closeCommentsAfterDays *int32, maxComments *int32, clientMutationID *string) (*model.Post, error) {
+	params := storage.NewPostParams{AuthorID: auth.UserID(ctx), Text: text, Tags: tags, CommentsEnabled: commentsEnabled}
+	if title != nil {
+		params.Title = strings.TrimSpace(*title)
+		if len([]rune(params.Title)) > 200 {
+			return nil, invalidArgument("title too long: maximum allowed is 200 characters")
+		}
+	}
+
 	size := len([]rune(text))
 	if size > 2000 {
-		return nil, fmt.Errorf("message too long: maximum allowed is 2000 characters")
+		return nil, invalidArgument("message too long: maximum allowed is 2000 characters")
 	}
 
 	if text == "" {
-		return nil, fmt.Errorf("message must contain at least one character")
+		return nil, invalidArgument("message must contain at least one character")
+	}
+
+	if err := applyPostStatus(ctx, &params, status, publishAt); err != nil {
+		return nil, err
 	}
+	params.CommentsPolicy = commentsPolicy(r.CommentsPolicy, closeCommentsAfterDays, maxComments)
 
-	return r.Storage.NewPost(text, commentsEnabled)
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return r.storage(ctx).NewPost(params)
+	})
+}
+
+// PublishPost is the resolver for the publishPost field.
+func (r *mutationResolver) PublishPost(ctx context.Context, postID string, publishAt *time.Time, expectedVersion *int32, clientMutationID *string) (*model.Post, error) {
+	if postID == "" {
+		return nil, invalidArgument("postID can`t be empty")
+	}
+
+	if _, err := r.requirePostOwner(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return versioned(r.storage(ctx).PublishPost(postID, publishAt, expectedVersion))
+	})
 }
 
 // SetCommentsEnabled is the resolver for the setCommentsEnabled field.
-func (r *mutationResolver) SetCommentsEnabled(ctx context.Context, postID string, enabled bool) (*model.Post, error) {
+func (r *mutationResolver) SetCommentsEnabled(ctx context.Context, postID string, enabled bool, expectedVersion *int32, clientMutationID *string) (*model.Post, error) {
 	if postID == "" {
-		return nil, fmt.Errorf("postID can`t be empty")
+		return nil, invalidArgument("postID can`t be empty")
 	}
 
-	return r.Storage.SetCommentsEnabled(postID, enabled)
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return versioned(r.storage(ctx).SetCommentsEnabled(postID, enabled, expectedVersion))
+	})
 }
 
-// Comments is the resolver for the comments field.
-func (r *postResolver) Comments(ctx context.Context, obj *model.Post, limit *int32, offset *int32) ([]*model.Comment, error) {
-	var lim, off int32 = 10, 0
-	if limit != nil {
-		lim = *limit
-		if lim < 0 {
-			return nil, fmt.Errorf("limit must be non-negative")
-		}
+// SetCommentsPolicy is the resolver for the setCommentsPolicy field.
+func (r *mutationResolver) SetCommentsPolicy(ctx context.Context, postID string, closeAfterDays *int32, maxComments *int32, expectedVersion *int32, clientMutationID *string) (*model.Post, error) {
+	if postID == "" {
+		return nil, invalidArgument("postID can`t be empty")
 	}
-	if offset != nil {
-		off = *offset
-		if off < 0 {
-			return nil, fmt.Errorf("offset must be non-negative")
-		}
+
+	if _, err := r.requirePostOwner(ctx, postID); err != nil {
+		return nil, err
 	}
 
-	return r.Storage.GetCommentsTree(obj.ID, lim, off)
+	policy := commentsPolicy(storage.CommentsPolicy{}, closeAfterDays, maxComments)
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return versioned(r.storage(ctx).SetCommentsPolicy(postID, policy, expectedVersion))
+	})
 }
 
-// GetPosts is the resolver for the getPosts field.
-func (r *queryResolver) GetPosts(ctx context.Context, limit *int32, offset *int32) ([]*model.Post, error) {
-	var lim, off int32 = 10, 0
-	if limit != nil {
-		lim = *limit
-		if lim < 0 {
-			return nil, fmt.Errorf("limit must be non-negative")
-		}
+// ReportContent is the resolver for the reportContent field.
+func (r *mutationResolver) ReportContent(ctx context.Context, targetID string, reason string, clientMutationID *string) (*model.Report, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetID == "" {
+		return nil, invalidArgument("targetID can`t be empty")
+	}
+
+	size := len([]rune(reason))
+	if size > 500 {
+		return nil, invalidArgument("reason too long: maximum allowed is 500 characters")
+	}
+
+	if reason == "" {
+		return nil, invalidArgument("reason must contain at least one character")
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Report, error) {
+		return r.storage(ctx).ReportComment(targetID, user.ID, reason)
+	})
+}
+
+// ApproveContent is the resolver for the approveContent field.
+func (r *mutationResolver) ApproveContent(ctx context.Context, targetID string, reason *string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	moderator, err := auth.RequireModerator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetID == "" {
+		return nil, invalidArgument("targetID can`t be empty")
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).ModerateComment(targetID, moderator.ID, model.ModerationActionApprove, reason, expectedVersion))
+	})
+}
+
+// RejectContent is the resolver for the rejectContent field.
+func (r *mutationResolver) RejectContent(ctx context.Context, targetID string, reason *string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	moderator, err := auth.RequireModerator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetID == "" {
+		return nil, invalidArgument("targetID can`t be empty")
 	}
-	if offset != nil {
-		off = *offset
-		if off < 0 {
-			return nil, fmt.Errorf("offset must be non-negative")
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).ModerateComment(targetID, moderator.ID, model.ModerationActionReject, reason, expectedVersion))
+	})
+}
+
+// HideContent is the resolver for the hideContent field.
+func (r *mutationResolver) HideContent(ctx context.Context, targetID string, reason *string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	moderator, err := auth.RequireModerator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetID == "" {
+		return nil, invalidArgument("targetID can`t be empty")
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Comment, error) {
+		return versioned(r.storage(ctx).ModerateComment(targetID, moderator.ID, model.ModerationActionHide, reason, expectedVersion))
+	})
+}
+
+// PinComment is the resolver for the pinComment field.
+func (r *mutationResolver) PinComment(ctx context.Context, commentID string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	return r.setCommentPinned(ctx, commentID, true, expectedVersion, clientMutationID)
+}
+
+// UnpinComment is the resolver for the unpinComment field.
+func (r *mutationResolver) UnpinComment(ctx context.Context, commentID string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	return r.setCommentPinned(ctx, commentID, false, expectedVersion, clientMutationID)
+}
+
+// LockThread is the resolver for the lockThread field.
+func (r *mutationResolver) LockThread(ctx context.Context, commentID string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	return r.setThreadLocked(ctx, commentID, true, expectedVersion, clientMutationID)
+}
+
+// UnlockThread is the resolver for the unlockThread field.
+func (r *mutationResolver) UnlockThread(ctx context.Context, commentID string, expectedVersion *int32, clientMutationID *string) (*model.Comment, error) {
+	return r.setThreadLocked(ctx, commentID, false, expectedVersion, clientMutationID)
+}
+
+// SetSlowMode is the resolver for the setSlowMode field.
+func (r *mutationResolver) SetSlowMode(ctx context.Context, postID string, seconds int32, expectedVersion *int32, clientMutationID *string) (*model.Post, error) {
+	if postID == "" {
+		return nil, invalidArgument("postID can`t be empty")
+	}
+
+	if seconds < 0 || seconds > storage.MaxSlowModeSeconds {
+		return nil, invalidArgument("seconds must be between 0 and %d", storage.MaxSlowModeSeconds)
+	}
+
+	if _, err := r.requirePostOwner(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (*model.Post, error) {
+		return versioned(r.storage(ctx).SetSlowMode(postID, seconds, expectedVersion))
+	})
+}
+
+// ImportPosts is the resolver for the importPosts field.
+func (r *mutationResolver) ImportPosts(ctx context.Context, data string, clientMutationID *string) (int32, error) {
+	if _, err := auth.RequireModerator(ctx); err != nil {
+		return 0, err
+	}
+
+	return idempotent(ctx, r.Resolver, clientMutationID, func() (int32, error) {
+		count, err := transfer.Import(strings.NewReader(data), r.storage(ctx))
+		if err != nil {
+			return int32(count), fmt.Errorf("imported %d posts before error: %w", count, err)
 		}
+		return int32(count), nil
+	})
+}
+
+// Text is the resolver for the text field.
+func (r *postResolver) Text(ctx context.Context, obj *model.Post, format *model.TextFormat) (string, error) {
+	return r.formatText(obj.Text, format)
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.Revision, error) {
+	return r.storage(ctx).GetRevisions(obj.ID)
+}
+
+// Diff is the resolver for the diff field.
+func (r *postResolver) Diff(ctx context.Context, obj *model.Post, fromRevision int32, toRevision int32, unit *model.DiffUnit) ([]*model.DiffChunk, error) {
+	return r.revisionsDiff(ctx, obj.ID, fromRevision, toRevision, unit)
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, limit *int32, offset *int32, createdAfter *time.Time, createdBefore *time.Time) ([]*model.Comment, error) {
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	return r.Storage.GetPosts(lim, off)
+	// Комментарии нескольких постов страницы загружаются одной пачкой
+	filter := newTimeRangeKey(storage.TimeRange{After: createdAfter, Before: createdBefore})
+	return loadersFor(ctx, r.storage(ctx)).comments.Load(ctx, commentsKey{obj.ID, lim, off, filter})
+}
+
+// GetPosts is the resolver for the getPosts field.
+func (r *queryResolver) GetPosts(ctx context.Context, limit *int32, offset *int32, createdAfter *time.Time, createdBefore *time.Time) ([]*model.Post, error) {
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.storage(ctx).GetPosts(lim, off, storage.TimeRange{After: createdAfter, Before: createdBefore})
 }
 
 // GetPost is the resolver for the getPost field.
 func (r *queryResolver) GetPost(ctx context.Context, postID string) (*model.Post, error) {
 	if postID == "" {
-		return nil, fmt.Errorf("postID can`t be empty")
+		return nil, invalidArgument("postID can`t be empty")
+	}
+
+	post, err := r.storage(ctx).GetPost(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.visiblePost(ctx, post)
+}
+
+// PostsByTag is the resolver for the postsByTag field.
+func (r *queryResolver) PostsByTag(ctx context.Context, tag string, first *int32, after *string) (*model.PostConnection, error) {
+	if storage.NormalizeTag(tag) == "" {
+		return nil, invalidArgument("tag can`t be empty")
+	}
+
+	lim, err := firstArg(first)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor *storage.PostCursor
+	if after != nil {
+		if cursor, err = decodeCursor(*after); err != nil {
+			return nil, err
+		}
+	}
+
+	// Запрашиваем на один пост больше, чтобы узнать, есть ли следующая страница
+	posts, err := r.storage(ctx).GetPostsByTag(tag, lim+1, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &model.PostConnection{
+		Edges:    make([]*model.PostEdge, 0, len(posts)),
+		PageInfo: &model.PageInfo{HasNextPage: len(posts) > int(lim)},
+	}
+	if conn.PageInfo.HasNextPage {
+		posts = posts[:lim]
+	}
+	for _, post := range posts {
+		conn.Edges = append(conn.Edges, &model.PostEdge{Cursor: encodeCursor(storage.CursorFor(post)), Node: post})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, prefix *string, limit *int32) ([]*model.Tag, error) {
+	lim, _, err := pageArgs(limit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p string
+	if prefix != nil {
+		p = *prefix
+	}
+
+	return r.storage(ctx).GetTags(p, lim)
+}
+
+// ModerationQueue is the resolver for the moderationQueue field.
+func (r *queryResolver) ModerationQueue(ctx context.Context, limit *int32, offset *int32) (*model.ModerationQueue, error) {
+	if _, err := auth.RequireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.storage(ctx).GetModerationQueue(lim, off)
+}
+
+// ModerationLog is the resolver for the moderationLog field.
+func (r *queryResolver) ModerationLog(ctx context.Context, targetID string) ([]*model.ModerationDecision, error) {
+	if _, err := auth.RequireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	if targetID == "" {
+		return nil, invalidArgument("targetID can`t be empty")
+	}
+
+	return r.storage(ctx).GetModerationLog(targetID)
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, limit *int32, offset *int32) ([]*model.Notification, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.storage(ctx).GetNotifications(user.ID, lim, off)
+}
+
+// MyDrafts is the resolver for the myDrafts field.
+func (r *queryResolver) MyDrafts(ctx context.Context, limit *int32, offset *int32) ([]*model.Post, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lim, off, err := pageArgs(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.storage(ctx).GetDrafts(user.ID, lim, off)
+}
+
+// ExportPosts is the resolver for the exportPosts field.
+func (r *queryResolver) ExportPosts(ctx context.Context, format *model.ExportFormat) (string, error) {
+	if _, err := auth.RequireModerator(ctx); err != nil {
+		return "", err
+	}
+
+	f := transfer.NDJSON
+	if format != nil {
+		f = transfer.Format(strings.ToLower(string(*format)))
+	}
+
+	var out strings.Builder
+	if _, err := transfer.Export(&out, r.storage(ctx), f); err != nil {
+		return "", err
 	}
+	return out.String(), nil
+}
 
-	return r.Storage.GetPost(postID)
+// Space is the resolver for the space field.
+func (r *queryResolver) Space(ctx context.Context) (*model.Space, error) {
+	// Вне tenant.Middleware (тесты, встраивание) запросы идут в пространство из контекста хранилища
+	space := tenant.ForContext(ctx)
+	if space == nil {
+		return &model.Space{ID: storage.SpaceFrom(ctx), Hosts: []string{}}, nil
+	}
+	return &model.Space{ID: space.ID, Hosts: append([]string{}, space.Hosts...)}, nil
 }
 
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
 	if postID == "" {
-		return nil, fmt.Errorf("postID can`t be empty")
+		return nil, invalidArgument("postID can`t be empty")
 	}
 
-	ch, unsubscribe, err := r.Storage.SubscribeToComments(postID)
+	ch, unsubscribe, err := r.storage(ctx).SubscribeToComments(postID)
 	if err != nil {
 		return nil, err
 	}
@@ -151,6 +547,43 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 	return ch, err
 }
 
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, unsubscribe, err := r.storage(ctx).SubscribeToNotifications(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		if unsubscribe != nil {
+			(*unsubscribe)()
+		}
+	}()
+	return ch, nil
+}
+
+// PostPublished is the resolver for the postPublished field.
+func (r *subscriptionResolver) PostPublished(ctx context.Context) (<-chan *model.Post, error) {
+	ch, unsubscribe, err := r.storage(ctx).SubscribeToPosts()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		if unsubscribe != nil {
+			(*unsubscribe)()
+		}
+	}()
+	return ch, nil
+}
+
 // Comment returns CommentResolver implementation.
 func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
 
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"PostAndComment/storage"
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Расширение gqlgen: сессия хранилища на каждую операцию, чтобы операция видела свои записи
+// даже при чтении с реплик. Мутация с самого начала читает из основной базы: проверки доступа
+// перед изменением и ответ мутации должны видеть последние данные.
+// Подключается до Dataloaders, иначе загрузчики операции не увидят сессию
+type Sessions struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = Sessions{}
+
+func (Sessions) ExtensionName() string {
+	return "Sessions"
+}
+
+func (Sessions) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Sessions) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	ctx = storage.NewSession(ctx)
+	if op := graphql.GetOperationContext(ctx).Operation; op != nil && op.Operation == ast.Mutation {
+		storage.SessionFrom(ctx).MarkWrite()
+	}
+	return next(ctx)
+}
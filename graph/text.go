@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/markdown"
+	"sync"
+)
+
+// Рендер для резолверов, созданных без своего (например, в тестах)
+var defaultMarkdown = sync.OnceValue(func() *markdown.Renderer {
+	return markdown.New(markdown.DefaultCacheSize)
+})
+
+// Текст в запрошенном формате: исходный Markdown или санитизированный HTML
+func (r *Resolver) formatText(text string, format *model.TextFormat) (string, error) {
+	if format == nil || *format == model.TextFormatMarkdown {
+		return text, nil
+	}
+
+	renderer := r.Markdown
+	if renderer == nil {
+		renderer = defaultMarkdown()
+	}
+	return renderer.Render(text)
+}
@@ -0,0 +1,20 @@
+package graph
+
+import (
+	"PostAndComment/storage"
+	"errors"
+)
+
+const errVersionConflict = "CONFLICT"
+
+// Результат изменения с проверкой expectedVersion. Несовпадение версий отдается клиенту с кодом CONFLICT,
+// в extensions.currentVersion - текущая версия объекта, чтобы клиент мог перечитать его и повторить
+func versioned[T any](result T, err error) (T, error) {
+	var conflict *storage.VersionConflictError
+	if errors.As(err, &conflict) {
+		gqlErr := codedError(err, errVersionConflict)
+		gqlErr.Extensions["currentVersion"] = conflict.Actual
+		return result, gqlErr
+	}
+	return result, err
+}
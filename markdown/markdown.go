@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"regexp"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const DefaultCacheSize = 10000 // Сколько отрендеренных текстов держать в памяти
+
+// Рендер CommonMark в безопасный HTML. Результат кешируется по содержимому текста,
+// поэтому повторный запрос того же поста или комментария не рендерит его заново
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	cache  *lru.Cache[[sha256.Size]byte, string]
+}
+
+func New(cacheSize int) *Renderer {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	cache, _ := lru.New[[sha256.Size]byte, string](cacheSize)
+
+	// Сырой HTML goldmark не пропускает (нет html.WithUnsafe), санитайзер - вторая линия защиты
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return &Renderer{
+		md:     goldmark.New(goldmark.WithExtensions(extension.Linkify, extension.Strikethrough)),
+		policy: policy,
+		cache:  cache,
+	}
+}
+
+func (r *Renderer) Render(text string) (string, error) {
+	key := sha256.Sum256([]byte(text))
+	if html, ok := r.cache.Get(key); ok {
+		return html, nil
+	}
+
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(text), &buf); err != nil {
+		return "", err
+	}
+
+	html := r.policy.Sanitize(buf.String())
+	r.cache.Add(key, html)
+	return html, nil
+}
+
+// Число текстов в кеше
+func (r *Renderer) Len() int {
+	return r.cache.Len()
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"PostAndComment/auth"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Заголовок с API-ключом клиента
+const APIKeyHeader = "X-API-Key"
+
+type clientKeyCtx struct{}
+
+// Откуда брать ключ клиента для лимитов
+type ClientOptions struct {
+	TrustForwardedFor bool     // IP клиента из X-Forwarded-For (сервер за прокси)
+	TrustUserID       bool     // X-User-ID выставляет шлюз после аутентификации, клиент подменить его не может
+	APIKeys           []string // API-ключи со своим лимитом
+}
+
+// Middleware определяет ключ клиента для лимитов: пользователь, затем API-ключ, затем IP.
+// Пользователь учитывается только с TrustUserID, ключи - только из APIKeys: иначе клиент, меняя
+// заголовок в каждом запросе, получал бы новый бакет и обходил лимит по IP. Такие запросы считаются по IP.
+// Должен стоять после auth.Middleware, чтобы пользователь уже был в контексте
+func Middleware(opts ClientOptions) func(http.Handler) http.Handler {
+	known := make(map[string]bool, len(opts.APIKeys))
+	for _, apiKey := range opts.APIKeys {
+		known[hashAPIKey(apiKey)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := clientKey(r, opts, known)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKeyCtx{}, key)))
+		})
+	}
+}
+
+// Ключ клиента запроса
+func ClientKey(ctx context.Context) string {
+	if key, ok := ctx.Value(clientKeyCtx{}).(string); ok {
+		return key
+	}
+	return "unknown"
+}
+
+func clientKey(r *http.Request, opts ClientOptions, apiKeys map[string]bool) string {
+	if userID := auth.UserID(r.Context()); userID != "" && opts.TrustUserID {
+		return "user:" + userID
+	}
+
+	// Сами ключи в хранилище лимитов не попадают, только их хеш
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		if hash := hashAPIKey(apiKey); apiKeys[hash] {
+			return "apikey:" + hash
+		}
+	}
+
+	return "ip:" + clientIP(r, opts.TrustForwardedFor)
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
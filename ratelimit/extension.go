@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Код ошибки в extensions ответа
+const ErrorCode = "RATE_LIMITED"
+
+// Расширение gqlgen: лимиты по типу операции и на число одновременных подписок клиента
+type Extension struct {
+	Store         Store
+	Limits        map[ast.Operation]Limit
+	Subscriptions *ConcurrencyLimiter // nil - без ограничения
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = &Extension{}
+
+func (e *Extension) ExtensionName() string {
+	return "RateLimit"
+}
+
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	if e.Store == nil {
+		return fmt.Errorf("rate limit store can not be nil")
+	}
+	return nil
+}
+
+func (e *Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	opType := opCtx.Operation.Operation
+	key := ClientKey(ctx)
+
+	if result := e.Take(opType, key); !result.Allowed {
+		return rateLimited(fmt.Sprintf("rate limit exceeded for %s operations", opType), result.RetryAfter.Seconds())
+	}
+
+	if opType == ast.Subscription && e.Subscriptions != nil {
+		release, ok := e.Subscriptions.Acquire(key)
+		if !ok {
+			return rateLimited("too many concurrent subscriptions", 0)
+		}
+
+		// Контекст подписки отменяется при ее завершении
+		go func() {
+			<-ctx.Done()
+			release()
+		}()
+	}
+
+	return next(ctx)
+}
+
+// Списание токена за операцию типа opType клиента key. Другие API (REST) списывают
+// из тех же бакетов, что и GraphQL, поэтому у клиента один лимит на оба API
+func (e *Extension) Take(opType ast.Operation, key string) Result {
+	limit, ok := e.Limits[opType]
+	if !ok || !limit.Enabled() {
+		return Result{Allowed: true}
+	}
+
+	result, err := e.Store.Take(string(opType)+":"+key, limit)
+	if err != nil {
+		// Недоступность хранилища лимитов не должна ронять API
+		log.Printf("Rate limit store error: %v", err)
+		return Result{Allowed: true}
+	}
+	return result
+}
+
+func rateLimited(message string, retryAfter float64) graphql.ResponseHandler {
+	extensions := map[string]any{"code": ErrorCode}
+	if retryAfter > 0 {
+		extensions["retryAfter"] = int(math.Ceil(retryAfter)) // Секунды до следующей попытки
+	}
+
+	return graphql.OneShot(&graphql.Response{
+		Errors: gqlerror.List{{Message: message, Extensions: extensions}},
+	})
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const cleanupInterval = time.Minute // Как часто удалять заполненные бакеты
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     Limit
+}
+
+// In-process хранилище бакетов (для одной реплики сервера)
+type MemoryStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:     make(map[string]*bucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cleanup(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = takeToken(b.tokens, b.updatedAt, limit, now)
+	b.updatedAt = now
+	b.limit = limit
+
+	return result, nil
+}
+
+// Удаляем бакеты, которые уже полностью восстановились: они ничем не отличаются от новых
+func (s *MemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < cleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+
+	for key, b := range s.buckets {
+		tokens, _ := takeToken(b.tokens, b.updatedAt, b.limit, now)
+		if tokens+1 >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
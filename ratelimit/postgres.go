@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Хранилище бакетов в Postgres, общее для нескольких реплик сервера
+type PostgresStore struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, lastCleanup: time.Now()}
+}
+
+func (s *PostgresStore) Take(key string, limit Limit) (Result, error) {
+	s.cleanup()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// Создаем бакет при первом обращении, иначе блокируем существующую строку до конца транзакции
+	var tokens float64
+	var updatedAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO rate_limits (key, tokens, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+		RETURNING tokens, updated_at
+	`, key, float64(limit.Burst), now).Scan(&tokens, &updatedAt)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to load bucket: %w", err)
+	}
+
+	tokens, result := takeToken(tokens, updatedAt, limit, now)
+
+	_, err = tx.Exec("UPDATE rate_limits SET tokens = $1, updated_at = $2 WHERE key = $3", tokens, now, key)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to update bucket: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// Удаляем давно не использованные бакеты
+func (s *PostgresStore) cleanup() {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < cleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	_, _ = s.db.Exec("DELETE FROM rate_limits WHERE updated_at < $1", time.Now().Add(-time.Hour))
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Параметры token bucket: Rate токенов в секунду, не больше Burst токенов в запасе
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Нулевая скорость - ограничение выключено
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration // Через сколько появится следующий токен (если запрос отклонен)
+}
+
+// Хранилище состояния бакетов
+type Store interface {
+	Take(key string, limit Limit) (Result, error) // Списать один токен из бакета key
+}
+
+// Пересчет бакета на момент now: пополнение и попытка списать токен
+func takeToken(tokens float64, updatedAt time.Time, limit Limit, now time.Time) (float64, Result) {
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+	}
+
+	if tokens >= 1 {
+		return tokens - 1, Result{Allowed: true}
+	}
+
+	wait := time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	return tokens, Result{Allowed: false, RetryAfter: wait}
+}
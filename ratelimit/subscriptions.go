@@ -0,0 +1,50 @@
+package ratelimit
+
+import "sync"
+
+// Ограничение числа одновременных подписок одного клиента.
+// Подписка живет в websocket-соединении конкретной реплики, поэтому счетчики in-process
+type ConcurrencyLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+}
+
+// Не больше max подписок на клиента, 0 - без ограничения
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, active: make(map[string]int)}
+}
+
+// Занять слот клиента. Возвращает функцию освобождения слота
+func (l *ConcurrencyLimiter) Acquire(key string) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.active[key] >= l.max {
+		return nil, false
+	}
+	l.active[key]++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.active[key]--
+			if l.active[key] <= 0 {
+				delete(l.active, key)
+			}
+		})
+	}
+
+	return release, true
+}
+
+// Кол-во активных подписок клиента
+func (l *ConcurrencyLimiter) Active(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.active[key]
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/storage"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Коды ошибок в ответах. Коды правил обсуждения и конфликта версий совпадают с кодами GraphQL
+const (
+	codeInternal         = "INTERNAL"
+	codeInvalidArgument  = "INVALID_ARGUMENT"
+	codeNotFound         = "NOT_FOUND"
+	codeAlreadyExists    = "ALREADY_EXISTS"
+	codeConflict         = "CONFLICT"
+	codeUnauthenticated  = "UNAUTHENTICATED"
+	codeAccessDenied     = "ACCESS_DENIED"
+	codeCommentsDisabled = "COMMENTS_DISABLED"
+	codeCommentsClosed   = "COMMENTS_CLOSED"
+	codeThreadLocked     = "THREAD_LOCKED"
+	codeSlowMode         = "SLOW_MODE"
+)
+
+// Тело ответа с ошибкой
+type apiError struct {
+	Message        string `json:"message"`
+	Code           string `json:"code"`
+	CurrentVersion *int32 `json:"currentVersion,omitempty"` // Для CONFLICT по expectedVersion
+	RetryAfter     *int   `json:"retryAfter,omitempty"`     // Секунды до следующей попытки
+}
+
+// Статус и код ответа по виду ошибки. Ошибка без вида - внутренняя (например, отказ базы):
+// ее текст только в журнале, клиент получает 500 с общим сообщением
+func writeError(w http.ResponseWriter, err error) {
+	body := &apiError{Message: err.Error()}
+	status := http.StatusBadRequest
+
+	var conflict *storage.VersionConflictError
+	var slowMode *storage.SlowModeError
+	switch {
+	case errors.As(err, &conflict):
+		status, body.Code = http.StatusConflict, codeConflict
+		body.CurrentVersion = &conflict.Actual
+	case errors.As(err, &slowMode):
+		retryAfter := int(math.Ceil(slowMode.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		status, body.Code = http.StatusTooManyRequests, codeSlowMode
+		body.RetryAfter = &retryAfter
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, storage.ErrAnonymous):
+		status, body.Code = http.StatusUnauthorized, codeUnauthenticated
+	case errors.Is(err, auth.ErrAccessDenied):
+		status, body.Code = http.StatusForbidden, codeAccessDenied
+	case errors.Is(err, storage.ErrCommentsDisabled):
+		status, body.Code = http.StatusForbidden, codeCommentsDisabled
+	case errors.Is(err, storage.ErrCommentsClosed):
+		status, body.Code = http.StatusForbidden, codeCommentsClosed
+	case errors.Is(err, storage.ErrThreadLocked):
+		status, body.Code = http.StatusForbidden, codeThreadLocked
+	case errors.Is(err, storage.ErrNotFound):
+		status, body.Code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		status, body.Code = http.StatusConflict, codeAlreadyExists
+	case errors.Is(err, storage.ErrConflict):
+		status, body.Code = http.StatusConflict, codeConflict
+	case errors.Is(err, storage.ErrInvalidArgument):
+		body.Code = codeInvalidArgument
+	default:
+		log.Printf("REST request failed: %v", err)
+		status, body.Code = http.StatusInternalServerError, codeInternal
+		body.Message = "internal server error"
+	}
+
+	writeJSON(w, status, body)
+}
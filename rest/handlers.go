@@ -0,0 +1,261 @@
+package rest
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/graph/model"
+	"net/http"
+	"time"
+)
+
+func (h *Handler) routes() []route {
+	return []route{
+		{http.MethodGet, "/posts", http.StatusOK, h.listPosts},
+		{http.MethodPost, "/posts", http.StatusCreated, h.createPost},
+		{http.MethodGet, "/posts/{id}", http.StatusOK, h.getPost},
+		{http.MethodPatch, "/posts/{id}", http.StatusOK, h.editPost},
+		{http.MethodPost, "/posts/{id}/publish", http.StatusOK, h.publishPost},
+		{http.MethodGet, "/posts/{id}/comments", http.StatusOK, h.listComments},
+		{http.MethodPost, "/posts/{id}/comments", http.StatusCreated, h.addComment},
+		{http.MethodPatch, "/comments/{id}", http.StatusOK, h.editComment},
+		{http.MethodGet, "/tags", http.StatusOK, h.listTags},
+		{http.MethodGet, "/tags/{tag}/posts", http.StatusOK, h.postsByTag},
+	}
+}
+
+// GET /posts?limit=&offset=&createdAfter=&createdBefore=&format= - опубликованные посты, новые первыми
+func (h *Handler) listPosts(r *http.Request) (any, error) {
+	q := r.URL.Query()
+	limit, err := intParam(q, "limit")
+	if err != nil {
+		return nil, err
+	}
+	offset, err := intParam(q, "offset")
+	if err != nil {
+		return nil, err
+	}
+	createdAfter, err := timeParam(q, "createdAfter")
+	if err != nil {
+		return nil, err
+	}
+	createdBefore, err := timeParam(q, "createdBefore")
+	if err != nil {
+		return nil, err
+	}
+	format, err := textFormat(q)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := h.resolver.Query().GetPosts(r.Context(), limit, offset, createdAfter, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	views, err := h.postsView(r.Context(), posts, format)
+	if err != nil {
+		return nil, err
+	}
+	return &postList{Posts: views}, nil
+}
+
+type newPostRequest struct {
+	Title                  *string           `json:"title"`
+	Text                   string            `json:"text"`
+	Tags                   []string          `json:"tags"`
+	CommentsEnabled        *bool             `json:"commentsEnabled"` // По умолчанию true
+	Status                 *model.PostStatus `json:"status"`
+	PublishAt              *time.Time        `json:"publishAt"`
+	CloseCommentsAfterDays *int32            `json:"closeCommentsAfterDays"`
+	MaxComments            *int32            `json:"maxComments"`
+}
+
+// POST /posts - новый пост
+func (h *Handler) createPost(r *http.Request) (any, error) {
+	var req newPostRequest
+	if err := decodeBody(r, &req, false); err != nil {
+		return nil, err
+	}
+	if req.Status != nil && !req.Status.IsValid() {
+		return nil, invalidArgument("invalid status %q", *req.Status)
+	}
+	commentsEnabled := req.CommentsEnabled == nil || *req.CommentsEnabled
+
+	ctx := graph.WithOperation(r.Context(), "newPost")
+	p, err := h.resolver.Mutation().NewPost(ctx, req.Title, req.Text, req.Tags, commentsEnabled, req.Status,
+		req.PublishAt, req.CloseCommentsAfterDays, req.MaxComments, idempotencyKey(r))
+	if err != nil {
+		return nil, err
+	}
+	return h.postView(ctx, p, nil)
+}
+
+// GET /posts/{id}?format= - пост. Черновик виден только автору и модераторам
+func (h *Handler) getPost(r *http.Request) (any, error) {
+	format, err := textFormat(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := h.resolver.Query().GetPost(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	return h.postView(r.Context(), p, format)
+}
+
+type editRequest struct {
+	Text            string `json:"text"`
+	ExpectedVersion *int32 `json:"expectedVersion"`
+}
+
+// PATCH /posts/{id} - правка текста поста
+func (h *Handler) editPost(r *http.Request) (any, error) {
+	var req editRequest
+	if err := decodeBody(r, &req, false); err != nil {
+		return nil, err
+	}
+
+	ctx := graph.WithOperation(r.Context(), "editPost")
+	p, err := h.resolver.Mutation().EditPost(ctx, r.PathValue("id"), req.Text, req.ExpectedVersion, idempotencyKey(r))
+	if err != nil {
+		return nil, err
+	}
+	return h.postView(ctx, p, nil)
+}
+
+type publishRequest struct {
+	PublishAt       *time.Time `json:"publishAt"`
+	ExpectedVersion *int32     `json:"expectedVersion"`
+}
+
+// POST /posts/{id}/publish - публикация черновика сейчас или по расписанию
+func (h *Handler) publishPost(r *http.Request) (any, error) {
+	var req publishRequest
+	if err := decodeBody(r, &req, true); err != nil {
+		return nil, err
+	}
+
+	ctx := graph.WithOperation(r.Context(), "publishPost")
+	p, err := h.resolver.Mutation().PublishPost(ctx, r.PathValue("id"), req.PublishAt, req.ExpectedVersion, idempotencyKey(r))
+	if err != nil {
+		return nil, err
+	}
+	return h.postView(ctx, p, nil)
+}
+
+// GET /posts/{id}/comments?limit=&offset=&createdAfter=&createdBefore=&format= - корневые комментарии
+// поста с ответами, закрепленные первыми
+func (h *Handler) listComments(r *http.Request) (any, error) {
+	q := r.URL.Query()
+	limit, err := intParam(q, "limit")
+	if err != nil {
+		return nil, err
+	}
+	offset, err := intParam(q, "offset")
+	if err != nil {
+		return nil, err
+	}
+	createdAfter, err := timeParam(q, "createdAfter")
+	if err != nil {
+		return nil, err
+	}
+	createdBefore, err := timeParam(q, "createdBefore")
+	if err != nil {
+		return nil, err
+	}
+	format, err := textFormat(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Как в GraphQL, комментарии доступны через пост: черновик чужого автора не найден
+	p, err := h.resolver.Query().GetPost(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	comments, err := h.resolver.Post().Comments(r.Context(), p, limit, offset, createdAfter, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	views, err := h.commentsView(r.Context(), comments, format)
+	if err != nil {
+		return nil, err
+	}
+	return &commentList{Comments: views}, nil
+}
+
+type addCommentRequest struct {
+	ParentID *string `json:"parentID"`
+	Text     string  `json:"text"`
+}
+
+// POST /posts/{id}/comments - комментарий к посту или ответ на комментарий parentID
+func (h *Handler) addComment(r *http.Request) (any, error) {
+	var req addCommentRequest
+	if err := decodeBody(r, &req, false); err != nil {
+		return nil, err
+	}
+
+	ctx := graph.WithOperation(r.Context(), "addComment")
+	c, err := h.resolver.Mutation().AddComment(ctx, r.PathValue("id"), req.ParentID, req.Text, idempotencyKey(r))
+	if err != nil {
+		return nil, err
+	}
+	return h.commentView(ctx, c, nil)
+}
+
+// PATCH /comments/{id} - правка текста комментария
+func (h *Handler) editComment(r *http.Request) (any, error) {
+	var req editRequest
+	if err := decodeBody(r, &req, false); err != nil {
+		return nil, err
+	}
+
+	ctx := graph.WithOperation(r.Context(), "editComment")
+	c, err := h.resolver.Mutation().EditComment(ctx, r.PathValue("id"), req.Text, req.ExpectedVersion, idempotencyKey(r))
+	if err != nil {
+		return nil, err
+	}
+	return h.commentView(ctx, c, nil)
+}
+
+// GET /tags?prefix=&limit= - теги по убыванию числа постов
+func (h *Handler) listTags(r *http.Request) (any, error) {
+	q := r.URL.Query()
+	limit, err := intParam(q, "limit")
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := h.resolver.Query().Tags(r.Context(), stringParam(q, "prefix"), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &tagList{Tags: nonNil(tags)}, nil
+}
+
+// GET /tags/{tag}/posts?first=&after=&format= - посты с тегом по курсору pageInfo.endCursor
+func (h *Handler) postsByTag(r *http.Request) (any, error) {
+	q := r.URL.Query()
+	first, err := intParam(q, "first")
+	if err != nil {
+		return nil, err
+	}
+	format, err := textFormat(q)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := h.resolver.Query().PostsByTag(r.Context(), r.PathValue("tag"), first, stringParam(q, "after"))
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]*model.Post, 0, len(conn.Edges))
+	for _, edge := range conn.Edges {
+		posts = append(posts, edge.Node)
+	}
+	views, err := h.postsView(r.Context(), posts, format)
+	if err != nil {
+		return nil, err
+	}
+	return &postList{Posts: views, PageInfo: conn.PageInfo}, nil
+}
@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/ratelimit"
+	"PostAndComment/storage"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// REST/JSON API для клиентов без GraphQL. Обработчики вызывают те же резолверы, что и GraphQL,
+// поэтому проверки аргументов, права доступа, идемпотентность и ошибки у обоих API общие
+
+// Префикс версии API. Несовместимые изменения выходят под новым префиксом
+const Prefix = "/api/v1"
+
+// Заголовок с ключом идемпотентности, аналог clientMutationId
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Максимальный размер тела запроса
+const maxBodySize = 1 << 20
+
+// Описание API в формате OpenAPI 3, поддерживается вместе с маршрутами
+//
+//go:embed openapi.json
+var openAPI []byte
+
+type Handler struct {
+	resolver *graph.Resolver
+	limits   *ratelimit.Extension // nil - без лимитов
+	mux      *http.ServeMux
+}
+
+// Обработчик запросов к API: resolver - тот же, что у GraphQL, limits - лимиты GraphQL,
+// запросы GET списываются как query, остальные - как mutation
+func New(resolver *graph.Resolver, limits *ratelimit.Extension) *Handler {
+	h := &Handler{resolver: resolver, limits: limits, mux: http.NewServeMux()}
+	for _, rt := range h.routes() {
+		h.mux.Handle(rt.method+" "+Prefix+rt.path, h.endpoint(rt))
+	}
+	h.mux.HandleFunc("GET "+Prefix+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	// Неизвестные пути внутри API - ошибка в формате API, а не страница net/http
+	h.mux.HandleFunc(Prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, &apiError{Message: fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path), Code: codeNotFound})
+	})
+	return h
+}
+
+// Маршруты API в виде "GET /api/v1/posts/{id}", включая описание API
+func (h *Handler) Routes() []string {
+	routes := []string{"GET " + Prefix + "/openapi.json"}
+	for _, rt := range h.routes() {
+		routes = append(routes, rt.method+" "+Prefix+rt.path)
+	}
+	return routes
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Обработчик маршрута: результат - тело ответа со статусом status
+type route struct {
+	method string
+	path   string
+	status int
+	handle func(r *http.Request) (any, error)
+}
+
+// Сессия хранилища, лимиты и запись ответа, общие для всех маршрутов
+func (h *Handler) endpoint(rt route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opType := ast.Query
+		if r.Method != http.MethodGet {
+			opType = ast.Mutation
+		}
+
+		if h.limits != nil {
+			if result := h.limits.Take(opType, ratelimit.ClientKey(r.Context())); !result.Allowed {
+				retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeJSON(w, http.StatusTooManyRequests, &apiError{
+					Message: fmt.Sprintf("rate limit exceeded for %s operations", opType), Code: ratelimit.ErrorCode, RetryAfter: &retryAfter,
+				})
+				return
+			}
+		}
+
+		// Как в graph.Sessions: изменение с самого начала читает из основной базы
+		ctx := storage.NewSession(r.Context())
+		if opType == ast.Mutation {
+			storage.SessionFrom(ctx).MarkWrite()
+		}
+
+		result, err := rt.handle(r.WithContext(ctx))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, rt.status, result)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write REST response: %v", err)
+	}
+}
+
+// Разбор JSON тела запроса в dst. Пустое тело допустимо, если allowEmpty
+func decodeBody(r *http.Request, dst any, allowEmpty bool) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) && allowEmpty {
+			return nil
+		}
+		return invalidArgument("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// Ключ идемпотентности из заголовка, nil - без ключа
+func idempotencyKey(r *http.Request) *string {
+	if key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader)); key != "" {
+		return &key
+	}
+	return nil
+}
+
+// Необязательный целочисленный параметр запроса
+func intParam(q url.Values, name string) (*int32, error) {
+	value := q.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, invalidArgument("invalid %s: %q is not an integer", name, value)
+	}
+	v := int32(n)
+	return &v, nil
+}
+
+// Необязательный параметр-время в формате RFC 3339
+func timeParam(q url.Values, name string) (*time.Time, error) {
+	value := q.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, invalidArgument("invalid %s: %q is not an RFC 3339 time", name, value)
+	}
+	return &t, nil
+}
+
+func invalidArgument(format string, args ...any) error {
+	return storage.Errorf(storage.ErrInvalidArgument, format, args...)
+}
+
+func stringParam(q url.Values, name string) *string {
+	if !q.Has(name) {
+		return nil
+	}
+	value := q.Get(name)
+	return &value
+}
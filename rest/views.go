@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"PostAndComment/graph/model"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Представления объектов в ответах API. Поля совпадают с полями типов GraphQL,
+// вложенные списки (комментарии поста, версии текста) отдаются отдельными маршрутами
+
+type post struct {
+	ID               string           `json:"id"`
+	AuthorID         *string          `json:"authorID"`
+	Title            string           `json:"title"`
+	Slug             string           `json:"slug"`
+	Text             string           `json:"text"`
+	Tags             []string         `json:"tags"`
+	Mentions         []*model.Mention `json:"mentions"`
+	Hashtags         []*model.Hashtag `json:"hashtags"`
+	CommentsEnabled  bool             `json:"commentsEnabled"`
+	CommentsCloseAt  *time.Time       `json:"commentsCloseAt"`
+	MaxComments      *int32           `json:"maxComments"`
+	SlowModeSeconds  int32            `json:"slowModeSeconds"`
+	Status           model.PostStatus `json:"status"`
+	PublishAt        *time.Time       `json:"publishAt"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	EditedAt         *time.Time       `json:"editedAt"`
+	CommentCount     int32            `json:"commentCount"`
+	RootCommentCount int32            `json:"rootCommentCount"`
+	Version          int32            `json:"version"`
+}
+
+type comment struct {
+	ID              string                 `json:"id"`
+	PostID          string                 `json:"postID"`
+	ParentID        *string                `json:"parentID"`
+	AuthorID        *string                `json:"authorID"`
+	Text            string                 `json:"text"`
+	Mentions        []*model.Mention       `json:"mentions"`
+	Hashtags        []*model.Hashtag       `json:"hashtags"`
+	Replies         []*comment             `json:"replies"`
+	CreatedAt       time.Time              `json:"createdAt"`
+	EditedAt        *time.Time             `json:"editedAt"`
+	Status          model.ModerationStatus `json:"status"`
+	IsPinned        bool                   `json:"isPinned"`
+	IsLocked        bool                   `json:"isLocked"`
+	ReplyCount      int32                  `json:"replyCount"`
+	DescendantCount int32                  `json:"descendantCount"`
+	Version         int32                  `json:"version"`
+}
+
+type postList struct {
+	Posts    []*post         `json:"posts"`
+	PageInfo *model.PageInfo `json:"pageInfo,omitempty"` // Только для постраничной выдачи по курсору
+}
+
+type commentList struct {
+	Comments []*comment `json:"comments"`
+}
+
+type tagList struct {
+	Tags []*model.Tag `json:"tags"`
+}
+
+// Формат текста из параметра format: markdown (исходный текст, по умолчанию) или html
+func textFormat(q url.Values) (*model.TextFormat, error) {
+	value := q.Get("format")
+	if value == "" {
+		return nil, nil
+	}
+	format := model.TextFormat(strings.ToUpper(value))
+	if !format.IsValid() {
+		return nil, invalidArgument("invalid format %q: use markdown or html", value)
+	}
+	return &format, nil
+}
+
+func (h *Handler) postView(ctx context.Context, p *model.Post, format *model.TextFormat) (*post, error) {
+	text, err := h.resolver.Post().Text(ctx, p, format)
+	if err != nil {
+		return nil, err
+	}
+	return &post{
+		ID: p.ID, AuthorID: p.AuthorID, Title: p.Title, Slug: p.Slug, Text: text, Tags: nonNil(p.Tags),
+		Mentions: nonNil(p.Mentions), Hashtags: nonNil(p.Hashtags),
+		CommentsEnabled: p.CommentsEnabled, CommentsCloseAt: p.CommentsCloseAt, MaxComments: p.MaxComments,
+		SlowModeSeconds: p.SlowModeSeconds, Status: p.Status, PublishAt: p.PublishAt,
+		CreatedAt: p.CreatedAt, EditedAt: p.EditedAt,
+		CommentCount: p.CommentCount, RootCommentCount: p.RootCommentCount, Version: p.Version,
+	}, nil
+}
+
+func (h *Handler) postsView(ctx context.Context, posts []*model.Post, format *model.TextFormat) ([]*post, error) {
+	views := make([]*post, 0, len(posts))
+	for _, p := range posts {
+		view, err := h.postView(ctx, p, format)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// Комментарий с ответами
+func (h *Handler) commentView(ctx context.Context, c *model.Comment, format *model.TextFormat) (*comment, error) {
+	text, err := h.resolver.Comment().Text(ctx, c, format)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := h.commentsView(ctx, c.Replies, format)
+	if err != nil {
+		return nil, err
+	}
+	return &comment{
+		ID: c.ID, PostID: c.PostID, ParentID: c.ParentID, AuthorID: c.AuthorID, Text: text,
+		Mentions: nonNil(c.Mentions), Hashtags: nonNil(c.Hashtags), Replies: replies,
+		CreatedAt: c.CreatedAt, EditedAt: c.EditedAt, Status: c.Status, IsPinned: c.IsPinned, IsLocked: c.IsLocked,
+		ReplyCount: c.ReplyCount, DescendantCount: c.DescendantCount, Version: c.Version,
+	}, nil
+}
+
+func (h *Handler) commentsView(ctx context.Context, comments []*model.Comment, format *model.TextFormat) ([]*comment, error) {
+	views := make([]*comment, 0, len(comments))
+	for _, c := range comments {
+		view, err := h.commentView(ctx, c, format)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// Пустой список вместо null, как в ответах GraphQL для непустых списков
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
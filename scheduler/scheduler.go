@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"PostAndComment/storage"
+	"context"
+	"log"
+	"time"
+)
+
+const DefaultInterval = 10 * time.Second // Как часто проверять запланированные посты
+
+// Фоновая публикация запланированных постов. Событие postPublished рассылает хранилище,
+// поэтому планировщику достаточно перевести пост в опубликованные
+type Scheduler struct {
+	storage  storage.Storage
+	interval time.Duration
+}
+
+func New(s storage.Storage, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Scheduler{storage: s, interval: interval}
+}
+
+// Проверка расписания каждые interval до отмены ctx
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.PublishDue(now); err != nil {
+				log.Printf("Failed to publish scheduled posts: %v", err)
+			}
+		}
+	}
+}
+
+// Публикация постов, у которых наступил publishAt
+func (s *Scheduler) PublishDue(now time.Time) (int, error) {
+	posts, err := s.storage.PublishDuePosts(now)
+	if err != nil {
+		return 0, err
+	}
+	for _, post := range posts {
+		log.Printf("Published scheduled post %s", post.ID)
+	}
+	return len(posts), nil
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"PostAndComment/auth"
 	"PostAndComment/graph"
+	"PostAndComment/markdown"
+	"PostAndComment/ratelimit"
+	"PostAndComment/rest"
+	"PostAndComment/scheduler"
 	"PostAndComment/storage"
+	"PostAndComment/storage/cache"
 	"PostAndComment/storage/memory"
+	"PostAndComment/storage/migrations"
 	"PostAndComment/storage/postgres"
-	"database/sql"
+	"PostAndComment/storage/sqlite"
+	"PostAndComment/tenant"
+	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -18,7 +30,8 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gorilla/websocket"
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
@@ -37,24 +50,100 @@ func main() {
 	}
 
 	var storageInstance storage.Storage
+	var pool *pgxpool.Pool
 	var err error
 
 	switch storageType {
 	case "postgres":
-		storageInstance, err = initPostgresStorage()
+		pool, err = connectPostgres()
 		if err != nil {
 			log.Fatalf("Failed to initialize Postgres storage: %v", err)
 		}
-		log.Println("Using Postgres storage")
+		defer pool.Close()
+		// Реплики для чтения: DSN через запятую в DB_REPLICA_DSNS
+		if dsns := getEnvList("DB_REPLICA_DSNS"); len(dsns) > 0 {
+			replicas, err := connectReplicas(dsns)
+			if err != nil {
+				log.Fatalf("Failed to initialize Postgres replicas: %v", err)
+			}
+			defer replicas.Close()
+			storageInstance = postgres.NewReplicated(pool, replicas)
+			log.Printf("Using Postgres storage with %d read replicas", len(dsns))
+		} else {
+			storageInstance = postgres.New(pool)
+			log.Println("Using Postgres storage")
+		}
+	case "sqlite":
+		// Встроенная база в файле SQLITE_PATH для развертываний без Postgres
+		path := getEnv("SQLITE_PATH", "comments.db")
+		sqliteDB, err := sqlite.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to initialize SQLite storage: %v", err)
+		}
+		defer sqliteDB.Close()
+		storageInstance = sqlite.New(sqliteDB)
+		log.Printf("Using SQLite storage in %s", path)
 	case "memory":
-		storageInstance = memory.New()
-		log.Println("Using in-memory storage")
+		// С MEMORY_DATA_DIR состояние переживает перезапуск: журнал операций и периодические снимки
+		if dir := os.Getenv("MEMORY_DATA_DIR"); dir != "" {
+			fsync, err := memory.ParseFsyncPolicy(getEnv("MEMORY_FSYNC", string(memory.FsyncInterval)))
+			if err != nil {
+				log.Fatalf("Failed to initialize memory storage: %v", err)
+			}
+			durable, err := memory.Open(memory.Options{
+				Dir:           dir,
+				Fsync:         fsync,
+				FsyncInterval: getEnvDuration("MEMORY_FSYNC_INTERVAL", memory.DefaultFsyncInterval),
+				SnapshotEvery: getEnvInt("MEMORY_SNAPSHOT_EVERY", memory.DefaultSnapshotEvery),
+			})
+			if err != nil {
+				log.Fatalf("Failed to open memory storage in %s: %v", dir, err)
+			}
+			defer durable.Close()
+			storageInstance = durable
+			log.Printf("Using in-memory storage persisted to %s", dir)
+		} else {
+			storageInstance = memory.New()
+			log.Println("Using in-memory storage")
+		}
 	default:
-		log.Fatalf("Unknown storage type: %s, use 'postgres' or 'memory'", storageType)
+		log.Fatalf("Unknown storage type: %s, use 'postgres', 'sqlite' or 'memory'", storageType)
 	}
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{
-		Resolvers: &graph.Resolver{Storage: storageInstance}}))
+	// Подкоманды export и import выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], storageInstance); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Кеш постов и страниц комментариев перед хранилищем, STORAGE_CACHE_SIZE=0 - без кеша.
+	// Кеш у каждого экземпляра свой: изменения с других реплик видны после STORAGE_CACHE_TTL
+	if size := getEnvInt("STORAGE_CACHE_SIZE", 0); size > 0 {
+		cached := cache.New(storageInstance, cache.NewLRU(size, getEnvDuration("STORAGE_CACHE_TTL", cache.DefaultTTL)))
+		expvar.Publish("storage_cache", expvar.Func(func() any { return cached.Stats() }))
+		storageInstance = cached
+		log.Printf("Using storage cache for %d entries", size)
+	}
+
+	// Публикация запланированных постов, интервал проверки из SCHEDULER_INTERVAL (например, "30s")
+	go scheduler.New(storageInstance, getEnvDuration("SCHEDULER_INTERVAL", scheduler.DefaultInterval)).Run(context.Background())
+
+	// Резолверы общие для GraphQL и REST API
+	resolver := &graph.Resolver{
+		Storage:  storageInstance,
+		Markdown: markdown.New(getEnvInt("MARKDOWN_CACHE_SIZE", markdown.DefaultCacheSize)),
+		// Закрытие комментариев по умолчанию: через N дней после публикации и после N комментариев, 0 - без ограничения.
+		// Записывается в новые посты, на уже созданные не действует
+		CommentsPolicy: storage.CommentsPolicy{
+			CloseAfterDays: int32(getEnvInt("COMMENTS_CLOSE_AFTER_DAYS", 0)),
+			MaxComments:    int32(getEnvInt("COMMENTS_MAX_COUNT", 0)),
+		},
+		// Сколько помнить ответы мутаций с clientMutationId
+		IdempotencyTTL: getEnvDuration("IDEMPOTENCY_TTL", storage.DefaultIdempotencyTTL),
+	}
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(resolver)))
 
 	srv.AddTransport(transport.Websocket{
 		Upgrader: websocket.Upgrader{
@@ -72,19 +161,74 @@ func main() {
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
 	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(getEnvInt("MAX_QUERY_COMPLEXITY", 10000)))
+	srv.Use(graph.DepthLimit{Max: getEnvInt("MAX_QUERY_DEPTH", 12)})
+	srv.Use(graph.Sessions{})
+	srv.Use(graph.Dataloaders{Storage: storageInstance})
 	srv.Use(extension.AutomaticPersistedQuery{
 		Cache: lru.New[string](100),
 	})
 
+	// Хранилище лимитов: in-process или общее для реплик в Postgres (RATE_LIMIT_STORE)
+	var rateLimitStore ratelimit.Store
+	switch getEnv("RATE_LIMIT_STORE", "memory") {
+	case "postgres":
+		if pool == nil {
+			pool, err = connectPostgres()
+			if err != nil {
+				log.Fatalf("Failed to initialize Postgres rate limit store: %v", err)
+			}
+			defer pool.Close()
+		}
+		rateLimitStore = ratelimit.NewPostgresStore(stdlib.OpenDBFromPool(pool))
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	default:
+		log.Fatalf("Unknown rate limit store: %s, use 'postgres' or 'memory'", os.Getenv("RATE_LIMIT_STORE"))
+	}
+
+	limits := &ratelimit.Extension{
+		Store: rateLimitStore,
+		Limits: map[ast.Operation]ratelimit.Limit{
+			ast.Query:        getEnvLimit("RATE_LIMIT_QUERY", 20, 40),
+			ast.Mutation:     getEnvLimit("RATE_LIMIT_MUTATION", 5, 10),
+			ast.Subscription: getEnvLimit("RATE_LIMIT_SUBSCRIPTION", 1, 5),
+		},
+		Subscriptions: ratelimit.NewConcurrencyLimiter(getEnvInt("MAX_SUBSCRIPTIONS_PER_CLIENT", 10)),
+	}
+	srv.Use(limits)
+
+	// ID модераторов через запятую из переменной MODERATOR_IDS
+	authenticator := auth.New(auth.ParseIDs(os.Getenv("MODERATOR_IDS")))
+	// API-ключи клиентов со своим лимитом через запятую из переменной API_KEYS
+	clientKey := ratelimit.Middleware(ratelimit.ClientOptions{
+		TrustForwardedFor: getEnv("TRUST_FORWARDED_FOR", "false") == "true",
+		TrustUserID:       getEnv("TRUST_USER_ID", "false") == "true",
+		APIKeys:           getEnvList("API_KEYS"),
+	})
+
+	// Пространства вида "id=host1|host2,..." из переменной SPACES, без нее - одно пространство default
+	spaces, err := tenant.ParseSpaces(os.Getenv("SPACES"))
+	if err != nil {
+		log.Fatalf("Invalid value of SPACES: %v", err)
+	}
+	spaceResolver, err := tenant.New(spaces)
+	if err != nil {
+		log.Fatalf("Invalid value of SPACES: %v", err)
+	}
+
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	http.Handle("/query", spaceResolver.Middleware(authenticator.Middleware(clientKey(srv))))
+	// REST API с теми же резолверами и лимитами, описание - в /api/v1/openapi.json
+	http.Handle(rest.Prefix+"/", spaceResolver.Middleware(authenticator.Middleware(clientKey(rest.New(resolver, limits)))))
 
 	log.Printf("Server running on http://localhost:%s/", port)
 	log.Printf("GraphQL playground available at http://localhost:%s/", port)
+	log.Printf("REST API available at http://localhost:%s%s/", port, rest.Prefix)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
 
-func initPostgresStorage() (storage.Storage, error) {
+func connectPostgres() (*pgxpool.Pool, error) {
 
 	host := getEnv("DB_HOST", "localhost")
 	port := getEnv("DB_PORT", "5432")
@@ -96,22 +240,17 @@ func initPostgresStorage() (storage.Storage, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, password, dbname, sslmode)
 
-	var db *sql.DB
-	var err error
+	// Размеры пула и режим выполнения запросов - умолчания postgres.ParseConfig
+	pool, err := postgres.Connect(context.Background(), connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %v", err)
+	}
 
 	maxRetries := 30 //кол-во попыток подключения
 	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", connStr) //Попытка подключиться
-		if err != nil {
-			log.Printf("Failed to open database connection, attempt %d/%d : %v", i+1, maxRetries, err)
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
-		err = db.Ping() // Попытка пинга
+		err = pool.Ping(context.Background()) // Попытка пинга
 		if err != nil {
 			log.Printf("Failed to ping database, attempt %d/%d : %v", i+1, maxRetries, err)
-			db.Close()
 			time.Sleep(2 * time.Second)
 			continue
 		}
@@ -120,64 +259,42 @@ func initPostgresStorage() (storage.Storage, error) {
 	}
 
 	if err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to connect to database after %d attempts: %v", maxRetries, err)
 	}
 
-	db.SetMaxOpenConns(25)                 // Кол-во соединений
-	db.SetMaxIdleConns(5)                  // Кол-во готовых к подключению соединений
-	db.SetConnMaxLifetime(5 * time.Minute) // Время жизни соединения
-
-	if err := createTables(db); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to create tables: %v", err)
+	// Миграции работают через database/sql поверх того же пула
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+	if err := migrations.Apply(db, migrations.Postgres); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
-	log.Println("Successfully connected to Postgres storage")
-	return postgres.New(db), nil
+	log.Println("Successfully connected to Postgres")
+	return pool, nil
 }
 
-func createTables(db *sql.DB) error {
-	// Создание таблицы постов
-	createPostsTable := `
-        CREATE TABLE IF NOT EXISTS posts (
-            id VARCHAR(36) PRIMARY KEY,
-            text TEXT NOT NULL,
-            comments_enabled BOOLEAN NOT NULL DEFAULT true,
-            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-        );
-    `
-
-	// Создание таблицы комментариев
-	createCommentsTable := `
-        CREATE TABLE IF NOT EXISTS comments (
-            id VARCHAR(36) PRIMARY KEY,
-            post_id VARCHAR(36) NOT NULL,
-            parent_id VARCHAR(36),
-            text TEXT NOT NULL,
-            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-            FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
-        );
-    `
-
-	// Создание индексов
-	createIndexes := `
-        CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
-        CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
-        CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments(created_at);
-        CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);
-    `
-
-	queries := []string{createPostsTable, createCommentsTable, createIndexes}
-
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("failed to create tables: %v", err)
+// Соединения с репликами. Недоступная при запуске реплика не мешает старту: чтение идет
+// в основную базу, пока проверка не покажет, что реплика доступна
+func connectReplicas(dsns []string) (*postgres.Replicas, error) {
+	pools := make([]*pgxpool.Pool, 0, len(dsns))
+	for _, dsn := range dsns {
+		pool, err := postgres.Connect(context.Background(), dsn)
+		if err != nil {
+			for _, opened := range pools {
+				opened.Close()
+			}
+			return nil, err
 		}
+		pools = append(pools, pool)
 	}
 
-	log.Println("Database tables created successfully")
-	return nil
+	replicas := postgres.NewReplicas(pools,
+		getEnvDuration("DB_REPLICA_HEALTH_INTERVAL", postgres.DefaultHealthInterval),
+		getEnvDuration("DB_REPLICA_MAX_LAG", 0))
+	replicas.Check()
+	return replicas, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -186,3 +303,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// Непустые значения через запятую
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		log.Fatalf("Invalid value of %s: %v", key, err)
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, defaultValue.String()))
+	if err != nil {
+		log.Fatalf("Invalid value of %s: %v", key, err)
+	}
+	return value
+}
+
+// Лимит из пары переменных <key>_RPS и <key>_BURST. RPS=0 выключает лимит
+func getEnvLimit(key string, defaultRate float64, defaultBurst int) ratelimit.Limit {
+	rate, err := strconv.ParseFloat(getEnv(key+"_RPS", strconv.FormatFloat(defaultRate, 'f', -1, 64)), 64)
+	if err != nil {
+		log.Fatalf("Invalid value of %s_RPS: %v", key, err)
+	}
+	return ratelimit.Limit{Rate: rate, Burst: getEnvInt(key+"_BURST", defaultBurst)}
+}
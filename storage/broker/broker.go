@@ -0,0 +1,56 @@
+package broker
+
+import "sync"
+
+// Рассылка событий подписчикам внутри процесса. Подписчики группируются по ключу
+// (пост, пользователь), медленный подписчик пропускает события, а не блокирует отправителя
+type Broker[K comparable, V any] struct {
+	mu          sync.Mutex
+	subscribers map[K][]chan V
+}
+
+func New[K comparable, V any]() *Broker[K, V] {
+	return &Broker[K, V]{subscribers: make(map[K][]chan V)}
+}
+
+// Подписка на события по ключу. Функция отписки закрывает канал
+func (b *Broker[K, V]) Subscribe(key K) (<-chan V, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan V, 1)
+	b.subscribers[key] = append(b.subscribers[key], ch)
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subs := b.subscribers[key]
+		for i, subscriber := range subs {
+			if subscriber == ch {
+				subs = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(subs) == 0 {
+			delete(b.subscribers, key)
+		} else {
+			b.subscribers[key] = subs
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Отправка события подписчикам ключа
+func (b *Broker[K, V]) Publish(key K, value V) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subscribers[key] {
+		select {
+		case ch <- value:
+		default: // Медленный подписчик не блокирует запись
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+const (
+	DefaultTTL = 30 * time.Second // Сколько живет запись, если ее не сбросило изменение
+)
+
+// Хранилище записей кеша. Значения - сериализованные объекты, поэтому реализация может быть
+// общей для нескольких экземпляров сервиса (например, Redis), а не только в памяти процесса
+type Backend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// Кеш в памяти процесса: не больше size записей, вытесняются давно не читанные и старше ttl
+type LRU struct {
+	cache *expirable.LRU[string, []byte]
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &LRU{cache: expirable.NewLRU[string, []byte](size, nil, ttl)}
+}
+
+func (l *LRU) Get(key string) ([]byte, bool) {
+	return l.cache.Get(key)
+}
+
+func (l *LRU) Set(key string, value []byte) {
+	l.cache.Add(key, value)
+}
+
+func (l *LRU) Delete(key string) {
+	l.cache.Remove(key)
+}
+
+// Число записей в кеше
+func (l *LRU) Len() int {
+	return l.cache.Len()
+}
@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Хранилище с кешем постов и страниц дерева комментариев поверх другого хранилища.
+//
+// Записи поста привязаны к его поколению: изменение поста или его комментариев выдает посту новое
+// поколение, и все прежние записи (сам пост и все страницы дерева) перестают читаться, а потом
+// вытесняются. Чтение, начатое до изменения, сохраняет результат под старым поколением,
+// поэтому устаревшие данные в кеш не попадают.
+//
+// Остальные методы идут напрямую во вложенное хранилище. Новый метод Storage, который меняет пост
+// или комментарии, нужно обернуть здесь, иначе кеш будет отдавать старые данные до истечения TTL
+type CachedStorage struct {
+	storage.Storage
+
+	backend Backend
+	stats   *counters
+	space   string // Пространство запроса: записи разных пространств не пересекаются
+}
+
+type counters struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// Счетчики кеша с момента запуска
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+func New(inner storage.Storage, backend Backend) *CachedStorage {
+	return &CachedStorage{Storage: inner, backend: backend, stats: &counters{}, space: storage.DefaultSpace}
+}
+
+// Кеш поверх хранилища, привязанного к запросу (например, к выбору реплики и пространству).
+// Счетчики и хранилище записей общие, ключи записей включают пространство запроса
+func (c *CachedStorage) WithContext(ctx context.Context) storage.Storage {
+	return &CachedStorage{
+		Storage: storage.ForContext(ctx, c.Storage), backend: c.backend, stats: c.stats, space: storage.SpaceFrom(ctx),
+	}
+}
+
+func (c *CachedStorage) Stats() Stats {
+	return Stats{Hits: c.stats.hits.Load(), Misses: c.stats.misses.Load(), Invalidations: c.stats.invalidations.Load()}
+}
+
+// Пост по ID
+func (c *CachedStorage) GetPost(postID string) (*model.Post, error) {
+	key := storage.SpaceKey(c.space, "post:"+postID+":"+c.generation(postID))
+
+	var post *model.Post
+	if c.load(key, &post) {
+		return post, nil
+	}
+
+	post, err := c.Storage.GetPost(postID)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, post)
+	return post, nil
+}
+
+// Страница дерева комментариев поста
+func (c *CachedStorage) GetCommentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Comment, error) {
+	key := c.treeKey(postID, limit, offset, createdIn)
+
+	var comments []*model.Comment
+	if c.load(key, &comments) {
+		return comments, nil
+	}
+
+	comments, err := c.Storage.GetCommentsTree(postID, limit, offset, createdIn)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, comments)
+	return comments, nil
+}
+
+// Страницы деревьев нескольких постов: из вложенного хранилища читаются только отсутствующие в кеше
+func (c *CachedStorage) GetCommentsTrees(postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	result := make(map[string][]*model.Comment, len(postIDs))
+	keys := make(map[string]string)
+	var missing []string
+
+	for _, postID := range postIDs {
+		if _, ok := keys[postID]; ok {
+			continue
+		}
+		key := c.treeKey(postID, limit, offset, createdIn)
+		var comments []*model.Comment
+		if c.load(key, &comments) {
+			result[postID] = comments
+			continue
+		}
+		keys[postID] = key
+		missing = append(missing, postID)
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	trees, err := c.Storage.GetCommentsTrees(missing, limit, offset, createdIn)
+	if err != nil {
+		return nil, err
+	}
+	for postID, comments := range trees {
+		result[postID] = comments
+		c.store(keys[postID], comments)
+	}
+	return result, nil
+}
+
+func (c *CachedStorage) AddComment(params storage.NewCommentParams) (*model.Comment, error) {
+	comment, err := c.Storage.AddComment(params)
+	if err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return comment, err
+}
+
+func (c *CachedStorage) EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) {
+	post, err := c.Storage.EditPost(postID, editorID, text, expectedVersion)
+	if err == nil {
+		c.invalidate(post.ID)
+	}
+	return post, err
+}
+
+func (c *CachedStorage) EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) {
+	comment, err := c.Storage.EditComment(commentID, editorID, text, expectedVersion)
+	if err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return comment, err
+}
+
+func (c *CachedStorage) PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) {
+	post, err := c.Storage.PublishPost(postID, publishAt, expectedVersion)
+	if err == nil {
+		c.invalidate(post.ID)
+	}
+	return post, err
+}
+
+func (c *CachedStorage) PublishDuePosts(now time.Time) ([]*model.Post, error) {
+	posts, err := c.Storage.PublishDuePosts(now)
+	for _, post := range posts {
+		c.invalidate(post.ID)
+	}
+	return posts, err
+}
+
+func (c *CachedStorage) SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) {
+	post, err := c.Storage.SetCommentsEnabled(postID, enabled, expectedVersion)
+	if err == nil {
+		c.invalidate(post.ID)
+	}
+	return post, err
+}
+
+func (c *CachedStorage) SetCommentsPolicy(postID string, policy storage.CommentsPolicy, expectedVersion *int32) (*model.Post, error) {
+	post, err := c.Storage.SetCommentsPolicy(postID, policy, expectedVersion)
+	if err == nil {
+		c.invalidate(post.ID)
+	}
+	return post, err
+}
+
+func (c *CachedStorage) SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) {
+	post, err := c.Storage.SetSlowMode(postID, seconds, expectedVersion)
+	if err == nil {
+		c.invalidate(post.ID)
+	}
+	return post, err
+}
+
+func (c *CachedStorage) SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) {
+	comment, err := c.Storage.SetCommentPinned(commentID, pinned, expectedVersion)
+	if err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return comment, err
+}
+
+func (c *CachedStorage) SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) {
+	comment, err := c.Storage.SetThreadLocked(commentID, locked, expectedVersion)
+	if err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return comment, err
+}
+
+// Жалоба может скрыть комментарий до решения модератора, поэтому дерево его поста сбрасывается
+func (c *CachedStorage) ReportComment(commentID, reporterID, reason string) (*model.Report, error) {
+	report, err := c.Storage.ReportComment(commentID, reporterID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if comment, err := c.Storage.GetComment(commentID); err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return report, nil
+}
+
+func (c *CachedStorage) ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) {
+	comment, err := c.Storage.ModerateComment(commentID, moderatorID, action, reason, expectedVersion)
+	if err == nil {
+		c.invalidate(comment.PostID)
+	}
+	return comment, err
+}
+
+func (c *CachedStorage) ImportPost(post *storage.PostExport) error {
+	err := c.Storage.ImportPost(post)
+	if err == nil {
+		c.invalidate(post.Post.ID)
+	}
+	return err
+}
+
+// Текущее поколение записей поста. Если его нет (пост еще не читали или запись вытеснена),
+// выдается новое: записи под прежним поколением могли устареть. Поколение не зависит от
+// пространства: ID постов уникальны, а публикацию по расписанию сбрасывает планировщик вне запроса
+func (c *CachedStorage) generation(postID string) string {
+	key := "gen:" + postID
+	if gen, ok := c.backend.Get(key); ok {
+		return string(gen)
+	}
+	gen := uuid.NewString()
+	c.backend.Set(key, []byte(gen))
+	return gen
+}
+
+// Сброс всех записей поста
+func (c *CachedStorage) invalidate(postID string) {
+	c.stats.invalidations.Add(1)
+	c.backend.Set("gen:"+postID, []byte(uuid.NewString()))
+}
+
+func (c *CachedStorage) treeKey(postID string, limit, offset int32, createdIn storage.TimeRange) string {
+	return storage.SpaceKey(c.space, fmt.Sprintf("tree:%s:%s:%d:%d:%s:%s",
+		postID, c.generation(postID), limit, offset, boundKey(createdIn.After), boundKey(createdIn.Before)))
+}
+
+func boundKey(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return fmt.Sprint(t.UnixNano())
+}
+
+// Чтение записи в value. Каждый вызов получает свою копию, поэтому изменение результата
+// вызывающим не портит кеш
+func (c *CachedStorage) load(key string, value any) bool {
+	data, ok := c.backend.Get(key)
+	if ok && json.Unmarshal(data, value) == nil {
+		c.stats.hits.Add(1)
+		return true
+	}
+	c.stats.misses.Add(1)
+	return false
+}
+
+func (c *CachedStorage) store(key string, value any) {
+	if data, err := json.Marshal(value); err == nil {
+		c.backend.Set(key, data)
+	}
+}
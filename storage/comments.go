@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MaxPinnedComments  = 3            // Максимум закрепленных комментариев у поста
+	MaxSlowModeSeconds = 24 * 60 * 60 // Максимальный интервал медленного режима (сутки)
+)
+
+// Комментарии закрыты правилом поста: вышел срок или набралось максимальное число комментариев
+var ErrCommentsClosed = errors.New("comments are closed for this post")
+
+// Ветка закрыта модератором: ответы под заблокированным комментарием запрещены
+var ErrThreadLocked = errors.New("thread is locked: replies are not allowed")
+
+// Операция недоступна анонимному пользователю, например комментарий в медленном режиме
+var ErrAnonymous = errors.New("authentication required")
+
+// Параметры добавления комментария
+type NewCommentParams struct {
+	PostID   string
+	ParentID *string // nil для корневого комментария
+	AuthorID string  // Пустой для анонимного комментария
+	Text     string
+}
+
+// Медленный режим: пользователь уже комментировал пост меньше интервала назад
+type SlowModeError struct {
+	Seconds    int32         // Интервал медленного режима поста
+	RetryAfter time.Duration // Через сколько можно оставить следующий комментарий
+}
+
+func (e *SlowModeError) Error() string {
+	return fmt.Sprintf("slow mode: one comment per %d seconds, retry in %d seconds",
+		e.Seconds, int(e.RetryAfter.Round(time.Second).Seconds()))
+}
+
+// Проверка медленного режима для пользователя, последний комментарий которого к посту был в lastCommentAt.
+// Анонимного пользователя ограничить нельзя, поэтому в медленном режиме нужен автор
+func CheckSlowMode(seconds int32, authorID string, lastCommentAt, now time.Time) error {
+	if seconds <= 0 {
+		return nil
+	}
+	if authorID == "" {
+		return Errorf(ErrAnonymous, "slow mode: authentication required to comment on this post")
+	}
+	if lastCommentAt.IsZero() {
+		return nil
+	}
+
+	interval := time.Duration(seconds) * time.Second
+	if wait := lastCommentAt.Add(interval).Sub(now); wait > 0 {
+		return &SlowModeError{Seconds: seconds, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Правило автоматического закрытия комментариев поста. Нулевое значение поля - без ограничения
+type CommentsPolicy struct {
+	CloseAfterDays int32 // Закрыть через столько дней после публикации
+	MaxComments    int32 // Закрыть после стольких комментариев
+}
+
+func (p CommentsPolicy) Validate() error {
+	if p.CloseAfterDays < 0 {
+		return Errorf(ErrInvalidArgument, "closeAfterDays can`t be negative")
+	}
+	if p.MaxComments < 0 {
+		return Errorf(ErrInvalidArgument, "maxComments can`t be negative")
+	}
+	return nil
+}
+
+// Время закрытия комментариев поста, опубликованного в publishedAt. nil - без ограничения по времени
+func (p CommentsPolicy) CloseAt(publishedAt time.Time) *time.Time {
+	if p.CloseAfterDays <= 0 {
+		return nil
+	}
+	closeAt := publishedAt.AddDate(0, 0, int(p.CloseAfterDays))
+	return &closeAt
+}
+
+// Максимум комментариев для поля поста. nil - без ограничения
+func (p CommentsPolicy) Limit() *int32 {
+	if p.MaxComments <= 0 {
+		return nil
+	}
+	limit := p.MaxComments
+	return &limit
+}
+
+// Сработало ли правило для поста с commentCount комментариями
+func (p CommentsPolicy) Closed(publishedAt time.Time, commentCount int32, now time.Time) bool {
+	if closeAt := p.CloseAt(publishedAt); closeAt != nil && !now.Before(*closeAt) {
+		return true
+	}
+	return p.MaxComments > 0 && commentCount >= p.MaxComments
+}
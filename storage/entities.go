@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"PostAndComment/graph/model"
+	"unicode"
+)
+
+const maxMentionLength = 64 // Максимальная длина имени в упоминании (как у ID пользователя)
+
+// Упоминания и хештеги текста
+type Entities struct {
+	Mentions []*model.Mention `json:"mentions"`
+	Hashtags []*model.Hashtag `json:"hashtags"`
+}
+
+// Разбор @упоминаний и #хештегов. Смещения считаются в символах (rune) исходного текста.
+// Код в обратных кавычках (`code` и ```блоки```) пропускается
+func ExtractEntities(text string) Entities {
+	entities := Entities{Mentions: []*model.Mention{}, Hashtags: []*model.Hashtag{}}
+	runes := []rune(text)
+	codeFence := 0 // Длина открывающей последовательности ` или 0 вне кода
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if r == '`' {
+			j := i
+			for j < len(runes) && runes[j] == '`' {
+				j++
+			}
+			switch fence := j - i; {
+			case codeFence == 0:
+				codeFence = fence
+			case codeFence == fence:
+				codeFence = 0
+			}
+			i = j - 1
+			continue
+		}
+
+		if codeFence > 0 || (r != '@' && r != '#') {
+			continue
+		}
+		// "a@b.com" и "##" - не упоминание и не хештег
+		if i > 0 && (isEntityRune(runes[i-1]) || runes[i-1] == '@' || runes[i-1] == '#') {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && isEntityRune(runes[j]) {
+			j++
+		}
+		for j > i+1 && runes[j-1] == '-' {
+			j--
+		}
+
+		name := string(runes[i+1 : j])
+		length := j - i
+		switch {
+		case name == "":
+		case r == '@' && j-i-1 <= maxMentionLength:
+			entities.Mentions = append(entities.Mentions, &model.Mention{
+				Username: name, Offset: int32(i), Length: int32(length),
+			})
+		case r == '#' && j-i-1 <= MaxTagLength:
+			entities.Hashtags = append(entities.Hashtags, &model.Hashtag{
+				Tag: NormalizeTag(name), Offset: int32(i), Length: int32(length),
+			})
+		}
+		i = j - 1
+	}
+
+	return entities
+}
+
+// Упомянутые пользователи без повторов, в порядке первого упоминания
+func (e Entities) MentionedUsers() []string {
+	seen := make(map[string]bool, len(e.Mentions))
+	var users []string
+	for _, mention := range e.Mentions {
+		if !seen[mention.Username] {
+			seen[mention.Username] = true
+			users = append(users, mention.Username)
+		}
+	}
+	return users
+}
+
+func isEntityRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Виды ошибок хранилища для проверки через errors.Is. Текст ошибки по-прежнему содержит ID объекта,
+// а вид одинаков во всех реализациях Storage
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrCommentsDisabled = errors.New("comments are disabled for this post")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrConflict         = errors.New("operation conflicts with the current state")
+)
+
+// Ошибка с текстом для клиента и видом для errors.Is
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+// Ошибка вида kind с текстом по формату
+func Errorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+func PostNotFound(postID string) error {
+	return Errorf(ErrNotFound, "post with ID %s not found", postID)
+}
+
+func CommentNotFound(commentID string) error {
+	return Errorf(ErrNotFound, "comment with ID %s not found", commentID)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"PostAndComment/graph/model"
+	"fmt"
+	"sort"
+)
+
+// Пост со всеми комментариями для выгрузки и загрузки между хранилищами. Комментарии - плоским
+// списком со ссылками на родителя, текст без заглушек модерации
+type PostExport struct {
+	Post     *model.Post
+	Policy   CommentsPolicy
+	Comments []*model.Comment
+}
+
+// Проверка загружаемого поста. Комментарии упорядочиваются по времени создания, чтобы
+// родитель загружался раньше ответов и порядок в ветке совпадал с исходным
+func (p *PostExport) Validate() error {
+	if p.Post == nil || p.Post.ID == "" {
+		return fmt.Errorf("post ID can`t be empty")
+	}
+	if err := p.Policy.Validate(); err != nil {
+		return err
+	}
+	if p.Post.Status == "" {
+		p.Post.Status = model.PostStatusPublished
+	}
+	if !p.Post.Status.IsValid() {
+		return fmt.Errorf("post %s: invalid status %s", p.Post.ID, p.Post.Status)
+	}
+
+	tags, err := NormalizeTags(p.Post.Tags)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", p.Post.ID, err)
+	}
+	p.Post.Tags = tags
+
+	sort.SliceStable(p.Comments, func(i, j int) bool {
+		return p.Comments[i].CreatedAt.Before(p.Comments[j].CreatedAt)
+	})
+
+	seen := make(map[string]bool, len(p.Comments))
+	for _, c := range p.Comments {
+		if c.ID == "" {
+			return fmt.Errorf("post %s: comment ID can`t be empty", p.Post.ID)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("post %s: duplicate comment ID %s", p.Post.ID, c.ID)
+		}
+		if c.ParentID != nil && !seen[*c.ParentID] {
+			return fmt.Errorf("comment %s: parent comment %s not found before it", c.ID, *c.ParentID)
+		}
+		if c.Status == "" {
+			c.Status = model.ModerationStatusVisible
+		}
+		if !c.Status.IsValid() {
+			return fmt.Errorf("comment %s: invalid status %s", c.ID, c.Status)
+		}
+		seen[c.ID] = true
+	}
+	return nil
+}
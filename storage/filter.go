@@ -0,0 +1,19 @@
+package storage
+
+import "time"
+
+// Фильтр по времени создания (границы не включаются). nil - граница не задана
+type TimeRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+func (r TimeRange) Contains(t time.Time) bool {
+	if r.After != nil && !t.After(*r.After) {
+		return false
+	}
+	if r.Before != nil && !t.Before(*r.Before) {
+		return false
+	}
+	return true
+}
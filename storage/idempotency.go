@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIdempotencyTTL = 24 * time.Hour // Сколько помнить ответ мутации с ключом
+	MaxIdempotencyKeyLen  = 255
+
+	// Через сколько ключ мутации без ответа считается брошенным (сервер упал посреди мутации)
+	// и может быть занят снова
+	IdempotencyPendingTimeout = time.Minute
+)
+
+// Ключ идемпотентности мутации (clientMutationId). Ключи разных клиентов не пересекаются
+type IdempotencyKey struct {
+	Scope string `json:"scope"` // Клиент, приславший ключ
+	Key   string `json:"key"`
+}
+
+// Мутация, выполненная или выполняемая с ключом идемпотентности
+type IdempotencyRecord struct {
+	Operation string          `json:"operation"`        // Мутация, для которой ключ занят
+	Result    json.RawMessage `json:"result,omitempty"` // Ответ мутации, nil - мутация еще выполняется
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (k IdempotencyKey) Validate() error {
+	if k.Key == "" {
+		return Errorf(ErrInvalidArgument, "clientMutationId can`t be empty")
+	}
+	if len(k.Key) > MaxIdempotencyKeyLen {
+		return Errorf(ErrInvalidArgument, "clientMutationId too long: maximum allowed is %d bytes", MaxIdempotencyKeyLen)
+	}
+	return nil
+}
+
+// Запись больше не действует: ответ хранится ttl, брошенная мутация освобождает ключ
+// через IdempotencyPendingTimeout
+func (r *IdempotencyRecord) Expired(ttl time.Duration, now time.Time) bool {
+	age := now.Sub(r.CreatedAt)
+	if r.Result == nil {
+		return age >= min(ttl, IdempotencyPendingTimeout)
+	}
+	return age >= ttl
+}
+
+// Ответ на повтор мутации operation по действующей записи: запомненный ответ или ошибка,
+// если ключ занят другой мутацией или первая мутация еще выполняется
+func (r *IdempotencyRecord) Replay(key IdempotencyKey, operation string) (*IdempotencyRecord, error) {
+	if r.Operation != operation {
+		return nil, Errorf(ErrInvalidArgument, "clientMutationId %s was already used for %s", key.Key, r.Operation)
+	}
+	if r.Result == nil {
+		return nil, Errorf(ErrConflict, "mutation with clientMutationId %s is still in progress", key.Key)
+	}
+	return r, nil
+}
+
+// Очистка устаревших ключей попутно с занятием новых, не чаще раза в IdempotencyPendingTimeout.
+// Нулевое значение готово к работе
+type IdempotencySweeper struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Пора ли чистить: true не чаще раза в IdempotencyPendingTimeout
+func (s *IdempotencySweeper) Due(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.last) < IdempotencyPendingTimeout {
+		return false
+	}
+	s.last = now
+	return true
+}
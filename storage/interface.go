@@ -1,19 +1,76 @@
 package storage
 
-import "PostAndComment/graph/model"
+import (
+	"PostAndComment/graph/model"
+	"time"
+)
 
+// Методы изменения поста или комментария принимают expectedVersion: если версия объекта другая,
+// изменение не выполняется и возвращается *VersionConflictError. nil - без проверки версии
 type Storage interface {
-	NewPost(text string, commentsEnabled bool) (*model.Post, error) // Создание поста
+	NewPost(params NewPostParams) (*model.Post, error) // Создание поста
 
-	AddComment(postID string, parentID *string, text string) (*model.Comment, error) // Добавление комментария
+	AddComment(params NewCommentParams) (*model.Comment, error) // Добавление комментария
 
-	GetCommentsTree(postID string, limit, offset int32) ([]*model.Comment, error) // Комментарии (с ответами) для указанного поста
+	GetComment(commentID string) (*model.Comment, error) // Комментарий без ответов
 
-	GetPosts(limit, offset int32) ([]*model.Post, error) // Список постов
+	EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) // Правка текста поста с сохранением версии
+
+	EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) // Правка текста комментария с сохранением версии
+
+	GetRevisions(targetID string) ([]*model.Revision, error) // Версии текста поста или комментария по возрастанию номера
+
+	GetCommentsTree(postID string, limit, offset int32, createdIn TimeRange) ([]*model.Comment, error) // Комментарии (с ответами) для указанного поста, закрепленные первыми, фильтр по корневым
+
+	GetCommentsTrees(postIDs []string, limit, offset int32, createdIn TimeRange) (map[string][]*model.Comment, error) // Комментарии для нескольких постов (без несуществующих)
+
+	GetPosts(limit, offset int32, createdIn TimeRange) ([]*model.Post, error) // Список опубликованных постов
 
 	GetPost(postID string) (*model.Post, error) // Пост с комментариями
 
-	SetCommentsEnabled(postID string, enabled bool) (*model.Post, error) //Вкл./выкл. комментарии
+	GetPostsByTag(tag string, limit int32, after *PostCursor) ([]*model.Post, error) // Опубликованные посты с тегом (новые первыми) после курсора
+
+	GetTags(prefix string, limit int32) ([]*model.Tag, error) // Теги с началом prefix по убыванию числа постов
+
+	GetDrafts(authorID string, limit, offset int32) ([]*model.Post, error) // Неопубликованные посты автора (черновики и запланированные), новые первыми
+
+	PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) // Публикация поста сейчас (publishAt nil или в прошлом) или по расписанию
+
+	PublishDuePosts(now time.Time) ([]*model.Post, error) // Публикация запланированных постов, у которых наступил publishAt
+
+	SubscribeToPosts() (<-chan *model.Post, *func(), error) // Подписка на опубликованные посты
+
+	SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) //Вкл./выкл. комментарии
+
+	SetCommentsPolicy(postID string, policy CommentsPolicy, expectedVersion *int32) (*model.Post, error) // Правило автоматического закрытия комментариев
+
+	SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) // Закрепление корневого комментария
+
+	SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) // Блокировка ответов под комментарием (на любой глубине)
+
+	SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) // Медленный режим: один комментарий пользователя к посту в seconds секунд, 0 - выкл.
 
 	SubscribeToComments(postID string) (<-chan *model.Comment, *func(), error) //Подписка на комментарии к посту
+
+	GetNotifications(userID string, limit, offset int32) ([]*model.Notification, error) // Уведомления пользователя, новые первыми
+
+	SubscribeToNotifications(userID string) (<-chan *model.Notification, *func(), error) // Подписка на новые уведомления пользователя
+
+	ReportComment(commentID, reporterID, reason string) (*model.Report, error) // Жалоба на комментарий
+
+	GetModerationQueue(limit, offset int32) (*model.ModerationQueue, error) // Комментарии с открытыми жалобами
+
+	ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) // Решение модератора
+
+	GetModerationLog(commentID string) ([]*model.ModerationDecision, error) // История решений модераторов по комментарию
+
+	ExportPosts(limit, offset int32) ([]*PostExport, error) // Все посты, включая неопубликованные, с комментариями для выгрузки
+
+	ImportPost(post *PostExport) error // Загрузка поста с комментариями с сохранением ID, связей и времени
+
+	ClaimIdempotencyKey(key IdempotencyKey, operation string, ttl time.Duration) (*IdempotencyRecord, error) // Занять ключ перед мутацией: nil - ключ свободен, иначе ответ прежней мутации с этим ключом
+
+	CompleteIdempotencyKey(key IdempotencyKey, result []byte) error // Запомнить ответ мутации, выполненной с занятым ключом
+
+	ReleaseIdempotencyKey(key IdempotencyKey) error // Освободить ключ неудавшейся мутации, чтобы ее можно было повторить
 }
@@ -0,0 +1,522 @@
+package memory
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Когда сбрасывать журнал на диск
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // После каждой записи: подтвержденная операция не теряется
+	FsyncInterval FsyncPolicy = "interval" // Раз в FsyncInterval: при сбое ОС теряются последние операции
+	FsyncNever    FsyncPolicy = "never"    // Сброс на усмотрение ОС
+)
+
+const (
+	DefaultFsyncInterval = time.Second
+	DefaultSnapshotEvery = 10000 // Записей журнала между снимками
+
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// Параметры хранения на диске
+type Options struct {
+	Dir           string      // Каталог снимка и журнала
+	Fsync         FsyncPolicy // По умолчанию FsyncInterval
+	FsyncInterval time.Duration
+	SnapshotEvery int // После стольких записей журнала пишется снимок, а журнал очищается
+}
+
+func ParseFsyncPolicy(policy string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(policy); p {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown fsync policy %q, use always, interval or never", policy)
+	}
+}
+
+// Хранилище в памяти, которое переживает перезапуск. Каждая изменяющая операция после применения
+// записывается в журнал вместе с выданными ей ID и временем, периодически состояние целиком пишется
+// в снимок. При открытии загружается снимок и повторяются записи журнала после него.
+//
+// Чтение идет напрямую в InMemoryStorage. Новый изменяющий метод InMemoryStorage нужно обернуть
+// здесь, иначе его изменения не попадут в журнал. Журнал общий для всех пространств,
+// запись журнала помнит пространство операции
+type DurableStorage struct {
+	*InMemoryStorage
+	*journal
+}
+
+type journal struct {
+	mu       sync.Mutex // Порядок записей в журнале совпадает с порядком применения операций
+	opts     Options
+	wal      *walWriter
+	seq      uint64 // Номер последней записи
+	pending  int    // Записей после последнего снимка
+	err      error  // Ошибка записи: состояние в памяти разошлось с диском, изменения запрещены
+	recorded record // ID и время, выданные текущей операции
+	replay   *record
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// Запись журнала: операция, ее аргументы и выданные ей ID и время
+type record struct {
+	Seq   uint64            `json:"seq"`
+	Space string            `json:"space,omitempty"` // Пусто - DefaultSpace (журналы до появления пространств)
+	Op    string            `json:"op"`
+	Args  []json.RawMessage `json:"args"`
+	IDs   []string          `json:"ids,omitempty"`
+	Times []time.Time       `json:"times,omitempty"`
+}
+
+// Открытие хранилища в каталоге opts.Dir: загрузка снимка и повтор журнала
+func Open(opts Options) (*DurableStorage, error) {
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncInterval
+	}
+	if _, err := ParseFsyncPolicy(string(opts.Fsync)); err != nil {
+		return nil, err
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = DefaultFsyncInterval
+	}
+	if opts.SnapshotEvery <= 0 {
+		opts.SnapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	inner, seq, err := loadSnapshot(filepath.Join(opts.Dir, snapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	d := &DurableStorage{InMemoryStorage: inner, journal: &journal{opts: opts, seq: seq, stop: make(chan struct{})}}
+	inner.newID = d.journal.newID
+	inner.now = d.journal.now
+
+	records, validSize, err := readWAL(filepath.Join(opts.Dir, walFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.Seq <= d.seq {
+			continue // Запись уже в снимке: сбой между записью снимка и очисткой журнала
+		}
+		if err := d.apply(rec); err != nil {
+			return nil, fmt.Errorf("failed to replay log record %d (%s): %w", rec.Seq, rec.Op, err)
+		}
+		d.seq = rec.Seq
+		d.pending++
+	}
+
+	if d.wal, err = openWAL(filepath.Join(opts.Dir, walFile), validSize); err != nil {
+		return nil, err
+	}
+	if opts.Fsync == FsyncInterval {
+		d.done.Add(1)
+		go d.syncLoop()
+	}
+	return d, nil
+}
+
+// Хранилище в пространстве запроса (storage.SpaceFrom) с тем же журналом
+func (d *DurableStorage) WithContext(ctx context.Context) storage.Storage {
+	return &DurableStorage{InMemoryStorage: d.inSpace(storage.SpaceFrom(ctx)), journal: d.journal}
+}
+
+// Запись снимка и очистка журнала
+func (d *DurableStorage) Snapshot() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.snapshot()
+}
+
+// Снимок, сброс журнала на диск и закрытие файлов
+func (d *DurableStorage) Close() error {
+	close(d.stop)
+	d.done.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.err
+	if err == nil {
+		err = d.snapshot()
+	}
+	if closeErr := d.wal.Close(); err == nil {
+		err = closeErr
+	}
+	d.err = fmt.Errorf("storage is closed")
+	return err
+}
+
+func (d *DurableStorage) snapshot() error {
+	if d.err != nil {
+		return d.err
+	}
+	if err := writeSnapshot(filepath.Join(d.opts.Dir, snapshotFile), d.InMemoryStorage, d.seq); err != nil {
+		return err
+	}
+	// Номер снимка уже на диске, поэтому сбой до очистки журнала не повторит записи дважды
+	if err := d.wal.Reset(); err != nil {
+		d.err = fmt.Errorf("failed to reset write-ahead log: %w", err)
+		return d.err
+	}
+	d.pending = 0
+	return nil
+}
+
+func (d *DurableStorage) syncLoop() {
+	defer d.done.Done()
+	ticker := time.NewTicker(d.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			if d.err == nil {
+				if err := d.wal.Sync(); err != nil {
+					d.err = fmt.Errorf("failed to sync write-ahead log: %w", err)
+					log.Printf("Memory storage: %v", d.err)
+				}
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// ID для операции: при восстановлении - из записи журнала, иначе новый с запоминанием
+func (d *journal) newID() string {
+	if d.replay != nil {
+		id := d.replay.IDs[0]
+		d.replay.IDs = d.replay.IDs[1:]
+		return id
+	}
+	id := uuid.New().String()
+	d.recorded.IDs = append(d.recorded.IDs, id)
+	return id
+}
+
+func (d *journal) now() time.Time {
+	if d.replay != nil {
+		t := d.replay.Times[0]
+		d.replay.Times = d.replay.Times[1:]
+		return t
+	}
+	t := time.Now()
+	d.recorded.Times = append(d.recorded.Times, t)
+	return t
+}
+
+// Выполнение изменяющей операции с записью в журнал. Неудачная операция состояние не меняет
+// и в журнал не попадает
+func logged[T any](d *DurableStorage, op string, apply func() (T, error), args ...any) (T, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var zero T
+	if d.err != nil {
+		return zero, d.err
+	}
+
+	d.recorded = record{}
+	result, err := apply()
+	if err != nil {
+		return result, err
+	}
+	if err := d.append(op, args); err != nil {
+		return zero, err
+	}
+	return result, nil
+}
+
+// Запись операции в пространстве хранилища в журнал. Вызывается под d.mu
+func (d *DurableStorage) append(op string, args []any) error {
+	rec := d.recorded
+	rec.Seq, rec.Op = d.seq+1, op
+	if d.spaceID != storage.DefaultSpace {
+		rec.Space = d.spaceID
+	}
+	for _, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s arguments: %w", op, err)
+		}
+		rec.Args = append(rec.Args, data)
+	}
+
+	if err := d.wal.Append(&rec, d.opts.Fsync == FsyncAlways); err != nil {
+		d.err = fmt.Errorf("failed to write %s to write-ahead log: %w", op, err)
+		return d.err
+	}
+	d.seq = rec.Seq
+	d.pending++
+
+	if d.pending >= d.opts.SnapshotEvery {
+		if err := d.snapshot(); err != nil {
+			log.Printf("Memory storage: failed to write snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+// Аргументы операции в журнале
+func decodeArgs(rec *record, args ...any) error {
+	if len(rec.Args) != len(args) {
+		return fmt.Errorf("expected %d arguments, got %d", len(args), len(rec.Args))
+	}
+	for i, arg := range args {
+		if err := json.Unmarshal(rec.Args[i], arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Повтор операции из журнала с выданными ей при записи ID и временем. Ожидаемая версия
+// в журнал не пишется: в журнал попадают только изменения, которые уже прошли проверку версии
+func (d *DurableStorage) apply(rec *record) (err error) {
+	d.replay = rec
+	defer func() { d.replay = nil }()
+
+	space := rec.Space
+	if space == "" {
+		space = storage.DefaultSpace
+	}
+	s := d.inSpace(space)
+	var postID, commentID, userID, text string
+	var flag bool
+	var seconds int32
+	switch rec.Op {
+	case "NewPost":
+		var params storage.NewPostParams
+		if err = decodeArgs(rec, &params); err == nil {
+			_, err = s.NewPost(params)
+		}
+	case "AddComment":
+		var params storage.NewCommentParams
+		if err = decodeArgs(rec, &params); err == nil {
+			_, err = s.AddComment(params)
+		}
+	case "EditPost":
+		if err = decodeArgs(rec, &postID, &userID, &text); err == nil {
+			_, err = s.EditPost(postID, userID, text, nil)
+		}
+	case "EditComment":
+		if err = decodeArgs(rec, &commentID, &userID, &text); err == nil {
+			_, err = s.EditComment(commentID, userID, text, nil)
+		}
+	case "PublishPost":
+		var publishAt *time.Time
+		if err = decodeArgs(rec, &postID, &publishAt); err == nil {
+			_, err = s.PublishPost(postID, publishAt, nil)
+		}
+	case "PublishDuePosts":
+		var now time.Time
+		if err = decodeArgs(rec, &now); err == nil {
+			_, err = s.PublishDuePosts(now)
+		}
+	case "SetCommentsEnabled":
+		if err = decodeArgs(rec, &postID, &flag); err == nil {
+			_, err = s.SetCommentsEnabled(postID, flag, nil)
+		}
+	case "SetCommentsPolicy":
+		var policy storage.CommentsPolicy
+		if err = decodeArgs(rec, &postID, &policy); err == nil {
+			_, err = s.SetCommentsPolicy(postID, policy, nil)
+		}
+	case "SetCommentPinned":
+		if err = decodeArgs(rec, &commentID, &flag); err == nil {
+			_, err = s.SetCommentPinned(commentID, flag, nil)
+		}
+	case "SetThreadLocked":
+		if err = decodeArgs(rec, &commentID, &flag); err == nil {
+			_, err = s.SetThreadLocked(commentID, flag, nil)
+		}
+	case "SetSlowMode":
+		if err = decodeArgs(rec, &postID, &seconds); err == nil {
+			_, err = s.SetSlowMode(postID, seconds, nil)
+		}
+	case "ReportComment":
+		if err = decodeArgs(rec, &commentID, &userID, &text); err == nil {
+			_, err = s.ReportComment(commentID, userID, text)
+		}
+	case "ModerateComment":
+		var action model.ModerationAction
+		var reason *string
+		if err = decodeArgs(rec, &commentID, &userID, &action, &reason); err == nil {
+			_, err = s.ModerateComment(commentID, userID, action, reason, nil)
+		}
+	case "ImportPost":
+		var export storage.PostExport
+		if err = decodeArgs(rec, &export); err == nil {
+			err = s.ImportPost(&export)
+		}
+	case "ClaimIdempotencyKey":
+		var key storage.IdempotencyKey
+		var ttl time.Duration
+		if err = decodeArgs(rec, &key, &text, &ttl); err == nil {
+			_, err = s.ClaimIdempotencyKey(key, text, ttl)
+		}
+	case "CompleteIdempotencyKey":
+		var key storage.IdempotencyKey
+		var result []byte
+		if err = decodeArgs(rec, &key, &result); err == nil {
+			err = s.CompleteIdempotencyKey(key, result)
+		}
+	case "ReleaseIdempotencyKey":
+		var key storage.IdempotencyKey
+		if err = decodeArgs(rec, &key); err == nil {
+			err = s.ReleaseIdempotencyKey(key)
+		}
+	default:
+		err = fmt.Errorf("unknown operation")
+	}
+	return err
+}
+
+func (d *DurableStorage) NewPost(params storage.NewPostParams) (*model.Post, error) {
+	return logged(d, "NewPost", func() (*model.Post, error) { return d.InMemoryStorage.NewPost(params) }, params)
+}
+
+func (d *DurableStorage) AddComment(params storage.NewCommentParams) (*model.Comment, error) {
+	return logged(d, "AddComment", func() (*model.Comment, error) { return d.InMemoryStorage.AddComment(params) }, params)
+}
+
+func (d *DurableStorage) EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) {
+	return logged(d, "EditPost", func() (*model.Post, error) {
+		return d.InMemoryStorage.EditPost(postID, editorID, text, expectedVersion)
+	}, postID, editorID, text)
+}
+
+func (d *DurableStorage) EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) {
+	return logged(d, "EditComment", func() (*model.Comment, error) {
+		return d.InMemoryStorage.EditComment(commentID, editorID, text, expectedVersion)
+	}, commentID, editorID, text)
+}
+
+func (d *DurableStorage) PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) {
+	return logged(d, "PublishPost", func() (*model.Post, error) {
+		return d.InMemoryStorage.PublishPost(postID, publishAt, expectedVersion)
+	}, postID, publishAt)
+}
+
+// Планировщик вызывает публикацию постоянно, поэтому пустой результат в журнал не пишется
+func (d *DurableStorage) PublishDuePosts(now time.Time) ([]*model.Post, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.recorded = record{}
+	posts, err := d.InMemoryStorage.PublishDuePosts(now)
+	if err != nil || len(posts) == 0 {
+		return posts, err
+	}
+	if err := d.append("PublishDuePosts", []any{now}); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (d *DurableStorage) SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) {
+	return logged(d, "SetCommentsEnabled", func() (*model.Post, error) {
+		return d.InMemoryStorage.SetCommentsEnabled(postID, enabled, expectedVersion)
+	}, postID, enabled)
+}
+
+func (d *DurableStorage) SetCommentsPolicy(postID string, policy storage.CommentsPolicy, expectedVersion *int32) (*model.Post, error) {
+	return logged(d, "SetCommentsPolicy", func() (*model.Post, error) {
+		return d.InMemoryStorage.SetCommentsPolicy(postID, policy, expectedVersion)
+	}, postID, policy)
+}
+
+func (d *DurableStorage) SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) {
+	return logged(d, "SetCommentPinned", func() (*model.Comment, error) {
+		return d.InMemoryStorage.SetCommentPinned(commentID, pinned, expectedVersion)
+	}, commentID, pinned)
+}
+
+func (d *DurableStorage) SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) {
+	return logged(d, "SetThreadLocked", func() (*model.Comment, error) {
+		return d.InMemoryStorage.SetThreadLocked(commentID, locked, expectedVersion)
+	}, commentID, locked)
+}
+
+func (d *DurableStorage) SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) {
+	return logged(d, "SetSlowMode", func() (*model.Post, error) {
+		return d.InMemoryStorage.SetSlowMode(postID, seconds, expectedVersion)
+	}, postID, seconds)
+}
+
+func (d *DurableStorage) ReportComment(commentID, reporterID, reason string) (*model.Report, error) {
+	return logged(d, "ReportComment", func() (*model.Report, error) {
+		return d.InMemoryStorage.ReportComment(commentID, reporterID, reason)
+	}, commentID, reporterID, reason)
+}
+
+func (d *DurableStorage) ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) {
+	return logged(d, "ModerateComment", func() (*model.Comment, error) {
+		return d.InMemoryStorage.ModerateComment(commentID, moderatorID, action, reason, expectedVersion)
+	}, commentID, moderatorID, action, reason)
+}
+
+func (d *DurableStorage) ImportPost(export *storage.PostExport) error {
+	_, err := logged(d, "ImportPost", func() (struct{}, error) {
+		return struct{}{}, d.InMemoryStorage.ImportPost(export)
+	}, export)
+	return err
+}
+
+// В журнал попадает только занятие свободного ключа: повтор с готовым ответом состояние не меняет
+func (d *DurableStorage) ClaimIdempotencyKey(key storage.IdempotencyKey, operation string, ttl time.Duration) (*storage.IdempotencyRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.recorded = record{}
+	rec, err := d.InMemoryStorage.ClaimIdempotencyKey(key, operation, ttl)
+	if err != nil || rec != nil {
+		return rec, err
+	}
+	if err := d.append("ClaimIdempotencyKey", []any{key, operation, ttl}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (d *DurableStorage) CompleteIdempotencyKey(key storage.IdempotencyKey, result []byte) error {
+	_, err := logged(d, "CompleteIdempotencyKey", func() (struct{}, error) {
+		return struct{}{}, d.InMemoryStorage.CompleteIdempotencyKey(key, result)
+	}, key, result)
+	return err
+}
+
+func (d *DurableStorage) ReleaseIdempotencyKey(key storage.IdempotencyKey) error {
+	_, err := logged(d, "ReleaseIdempotencyKey", func() (struct{}, error) {
+		return struct{}{}, d.InMemoryStorage.ReleaseIdempotencyKey(key)
+	}, key)
+	return err
+}
@@ -2,54 +2,308 @@ package memory
 
 import (
 	"PostAndComment/graph/model"
-	"fmt"
+	"PostAndComment/storage"
+	"PostAndComment/storage/broker"
+	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Хранилище в памяти. У каждого пространства (сообщества) свои коллекции, общие только
+// блокировка и источники ID и времени. Хранилище привязано к одному пространству, к другому
+// пространству запроса его привязывает WithContext
 type InMemoryStorage struct {
-	mu                  sync.RWMutex
-	posts               []*model.Post          //Список постов
-	postsCommentsEnable map[string]bool        //Признак включенных комментариев + Проверка существования поста
-	postSearch          map[string]*model.Post //Быстрый поиск постов по ID
+	*shared
+	*spaceData
+}
+
+// Общее для всех пространств
+type shared struct {
+	mu     sync.RWMutex
+	spaces map[string]*spaceData // Пространства по ID, создаются при первом обращении
+
+	// Источники ID и времени для изменяющих операций. Журнал (DurableStorage) подменяет их,
+	// чтобы повтор операции при восстановлении дал то же состояние
+	newID func() string
+	now   func() time.Time
+}
+
+// Данные одного пространства
+type spaceData struct {
+	spaceID string
+
+	posts               []*model.Post                         //Опубликованные посты в порядке публикации
+	drafts              []*model.Post                         //Черновики и запланированные посты в порядке создания
+	postsCommentsEnable map[string]bool                       //Признак включенных комментариев + Проверка существования поста
+	postSearch          map[string]*model.Post                //Быстрый поиск постов по ID
+	postsByTag          map[string][]*model.Post              //Опубликованные посты с тегом в порядке публикации
+	postSubscribers     *broker.Broker[struct{}, *model.Post] //Подписчики на публикацию постов
 
-	commentsAvaliability map[string]bool //Наличие комментария (Для проверки существования)
+	commentsPolicies map[string]storage.CommentsPolicy //Правила автоматического закрытия комментариев
+
+	commentSearch map[string]*model.Comment //Быстрый поиск комментария по ID + Проверка существования
 
 	commentsByPostAndParent map[string]map[string][]*model.Comment //Быстрый поиск комментария
-	subscribers             map[string][]chan *model.Comment       //Подписчики на комментарии к посту
+	pinned                  map[string][]*model.Comment            //Закрепленные комментарии поста в порядке закрепления
+	subscribers             *broker.Broker[string, *model.Comment] //Подписчики на комментарии к посту
+	lastCommentAt           map[string]map[string]time.Time        //Время последнего комментария пользователя к посту (медленный режим)
+
+	notifications           map[string][]*model.Notification            //Уведомления пользователей в порядке создания
+	notificationSubscribers *broker.Broker[string, *model.Notification] //Подписчики на уведомления пользователя
+
+	revisions map[string][]*model.Revision //Версии текста постов и комментариев
+
+	reports   map[string][]*model.Report             //Жалобы на комментарии
+	reportLog []*model.Report                        //Жалобы в порядке поступления (для очереди модерации)
+	decisions map[string][]*model.ModerationDecision //Журнал решений модераторов
+
+	idempotency      map[storage.IdempotencyKey]*storage.IdempotencyRecord //Ключи идемпотентности мутаций
+	idempotencySwept time.Time                                             //Время последней очистки устаревших ключей
 }
 
 func New() *InMemoryStorage {
-	return &InMemoryStorage{
+	s := &shared{
+		spaces: make(map[string]*spaceData),
+		newID:  func() string { return uuid.New().String() },
+		now:    time.Now,
+	}
+	data := newSpaceData(storage.DefaultSpace)
+	s.spaces[storage.DefaultSpace] = data
+	return &InMemoryStorage{shared: s, spaceData: data}
+}
+
+func newSpaceData(spaceID string) *spaceData {
+	return &spaceData{
+		spaceID:                 spaceID,
 		posts:                   make([]*model.Post, 0),
 		postSearch:              make(map[string]*model.Post),
+		postsByTag:              make(map[string][]*model.Post),
 		postsCommentsEnable:     make(map[string]bool),
-		commentsAvaliability:    make(map[string]bool),
+		commentsPolicies:        make(map[string]storage.CommentsPolicy),
+		commentSearch:           make(map[string]*model.Comment),
+		lastCommentAt:           make(map[string]map[string]time.Time),
 		commentsByPostAndParent: make(map[string]map[string][]*model.Comment),
-		subscribers:             make(map[string][]chan *model.Comment),
+		pinned:                  make(map[string][]*model.Comment),
+		postSubscribers:         broker.New[struct{}, *model.Post](),
+		subscribers:             broker.New[string, *model.Comment](),
+		notifications:           make(map[string][]*model.Notification),
+		notificationSubscribers: broker.New[string, *model.Notification](),
+		revisions:               make(map[string][]*model.Revision),
+		reports:                 make(map[string][]*model.Report),
+		decisions:               make(map[string][]*model.ModerationDecision),
+		idempotency:             make(map[storage.IdempotencyKey]*storage.IdempotencyRecord),
+	}
+}
+
+// Хранилище в пространстве запроса (storage.SpaceFrom)
+func (s *InMemoryStorage) WithContext(ctx context.Context) storage.Storage {
+	return s.inSpace(storage.SpaceFrom(ctx))
+}
+
+// Хранилище в пространстве spaceID, пустое пространство создается
+func (s *InMemoryStorage) inSpace(spaceID string) *InMemoryStorage {
+	s.mu.RLock()
+	data := s.spaces[spaceID]
+	s.mu.RUnlock()
+	if data == nil {
+		s.mu.Lock()
+		if data = s.spaces[spaceID]; data == nil {
+			data = newSpaceData(spaceID)
+			s.spaces[spaceID] = data
+		}
+		s.mu.Unlock()
 	}
+	return &InMemoryStorage{shared: s.shared, spaceData: data}
+}
+
+// Пространства по возрастанию ID, чтобы обход был одинаковым при повторе журнала. Вызывается под s.mu
+func (s *InMemoryStorage) allSpaces() []*InMemoryStorage {
+	ids := make([]string, 0, len(s.spaces))
+	for id := range s.spaces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make([]*InMemoryStorage, len(ids))
+	for i, id := range ids {
+		result[i] = &InMemoryStorage{shared: s.shared, spaceData: s.spaces[id]}
+	}
+	return result
 }
 
 const rootKey = "root" //Ключ родительского комментария для комментариев непосредственно к посту
 
 // Создание поста
-func (s *InMemoryStorage) NewPost(text string, commentsEnabled bool) (*model.Post, error) {
+func (s *InMemoryStorage) NewPost(params storage.NewPostParams) (*model.Post, error) {
+	tags, err := storage.NormalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.CommentsPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	id := s.newID()
+	entities := storage.ExtractEntities(params.Text)
 	post := &model.Post{
-		ID:              uuid.New().String(),
-		Text:            text,
-		CommentsEnabled: commentsEnabled,
-		CreatedAt:       time.Now().Format(time.RFC3339),
+		ID:              id,
+		AuthorID:        nullableID(params.AuthorID),
+		Title:           params.Title,
+		Slug:            storage.MakeSlug(params.Title, params.Text, id),
+		Text:            params.Text,
+		Tags:            tags,
+		Mentions:        entities.Mentions,
+		Hashtags:        entities.Hashtags,
+		CommentsEnabled: params.CommentsEnabled,
+		MaxComments:     params.CommentsPolicy.Limit(),
+		Status:          params.Status(),
+		CreatedAt:       s.now(),
+		Version:         storage.InitialVersion,
+	}
+	if post.Status == model.PostStatusScheduled {
+		publishAt := *params.PublishAt
+		post.PublishAt = &publishAt
 	}
 
-	s.posts = append(s.posts, post)
-	s.postsCommentsEnable[post.ID] = commentsEnabled
+	s.postsCommentsEnable[post.ID] = params.CommentsEnabled
+	s.commentsPolicies[post.ID] = params.CommentsPolicy
 	s.postSearch[post.ID] = post
-	return post, nil
+	if post.Status == model.PostStatusPublished {
+		s.publish(post, post.CreatedAt)
+	} else {
+		s.drafts = append(s.drafts, post)
+	}
+	return clonePost(post), nil
+}
+
+// Публикация поста сейчас или по расписанию
+func (s *InMemoryStorage) PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.postSearch[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	if err := storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+	if post.Status == model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is already published", postID)
+	}
+	post.Version++
+
+	now := s.now()
+	if publishAt != nil && publishAt.After(now) {
+		at := *publishAt
+		post.Status = model.PostStatusScheduled
+		post.PublishAt = &at
+		return clonePost(post), nil
+	}
+
+	s.removeDraft(post)
+	s.publish(post, now)
+	return clonePost(post), nil
+}
+
+// Публикация запланированных постов с наступившим publishAt в порядке расписания
+func (s *InMemoryStorage) PublishDuePosts(now time.Time) ([]*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Планировщик один на все пространства
+	var published []*model.Post
+	for _, space := range s.allSpaces() {
+		var due []*model.Post
+		for _, post := range space.drafts {
+			if post.Status == model.PostStatusScheduled && !post.PublishAt.After(now) {
+				due = append(due, post)
+			}
+		}
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].PublishAt.Before(*due[j].PublishAt)
+		})
+
+		for _, post := range due {
+			post.Version++
+			space.removeDraft(post)
+			space.publish(post, now)
+			published = append(published, clonePost(post))
+		}
+	}
+	return published, nil
+}
+
+// Неопубликованные посты автора, новые первыми
+func (s *InMemoryStorage) GetDrafts(authorID string, limit, offset int32) ([]*model.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*model.Post, 0, limit)
+	skipped := int32(0)
+	for i := len(s.drafts) - 1; i >= 0 && int32(len(result)) < limit; i-- {
+		post := s.drafts[i]
+		if post.AuthorID == nil || *post.AuthorID != authorID {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		result = append(result, clonePost(post))
+	}
+
+	return result, nil
+}
+
+// Подписка на публикацию постов
+func (s *InMemoryStorage) SubscribeToPosts() (<-chan *model.Post, *func(), error) {
+	ch, unsubscribe := s.postSubscribers.Subscribe(struct{}{})
+	return ch, &unsubscribe, nil
+}
+
+// Пост попадает в ленту и теги со временем публикации now, упомянутые и подписчики получают уведомления.
+// Вызывается под блокировкой
+func (s *InMemoryStorage) publish(post *model.Post, now time.Time) {
+	post.Status = model.PostStatusPublished
+	post.PublishAt = nil
+	post.CreatedAt = now
+	post.CommentsCloseAt = s.commentsPolicies[post.ID].CloseAt(post.CreatedAt)
+
+	s.posts = append(s.posts, post)
+	for _, tag := range post.Tags {
+		s.postsByTag[tag] = append(s.postsByTag[tag], post)
+	}
+	s.notifyMentioned(storage.Entities{Mentions: post.Mentions}.MentionedUsers(), post.ID, nil)
+
+	s.postSubscribers.Publish(struct{}{}, clonePost(post))
+}
+
+// Копия хранимого поста для выдачи: хранимый пост меняется под блокировкой, а копию читают без нее
+func clonePost(post *model.Post) *model.Post {
+	result := *post
+	result.Tags = slices.Clone(post.Tags)
+	result.Mentions = slices.Clone(post.Mentions)
+	result.Hashtags = slices.Clone(post.Hashtags)
+	return &result
+}
+
+// Удаление поста из списка неопубликованных. Вызывается под блокировкой
+func (s *InMemoryStorage) removeDraft(post *model.Post) {
+	for i, p := range s.drafts {
+		if p == post {
+			s.drafts = append(s.drafts[:i:i], s.drafts[i+1:]...)
+			return
+		}
+	}
 }
 
 // Запрос поста по ID
@@ -59,41 +313,74 @@ func (s *InMemoryStorage) GetPost(postID string) (*model.Post, error) {
 
 	post, ok := s.postSearch[postID]
 	if !ok {
-		return nil, fmt.Errorf("post with ID %s not found", postID)
+		return nil, storage.PostNotFound(postID)
 	}
 
-	return post, nil
+	return clonePost(post), nil
 }
 
 // Добавление комментария
-func (s *InMemoryStorage) AddComment(postID string, parentID *string, text string) (*model.Comment, error) {
+func (s *InMemoryStorage) AddComment(params storage.NewCommentParams) (*model.Comment, error) {
+	postID, parentID, text := params.PostID, params.ParentID, params.Text
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Проверка существования поста и доступности его комментирования
 	comentsEnable, ok := s.postsCommentsEnable[postID]
 	if !ok {
-		return nil, fmt.Errorf("post with ID %s not found", postID)
+		return nil, storage.PostNotFound(postID)
 	}
 	if !comentsEnable {
-		return nil, fmt.Errorf("comments are disabled for this post")
+		return nil, storage.ErrCommentsDisabled
+	}
+	post := s.postSearch[postID]
+	if post.Status != model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is not published", postID)
+	}
+	if s.commentsPolicies[postID].Closed(post.CreatedAt, post.CommentCount, s.now()) {
+		return nil, storage.ErrCommentsClosed
 	}
 
-	// Проверка существования родительского комментария
+	// Проверка существования родительского комментария в этом же посте и блокировки ветки на любом уровне выше
 	parentKey := rootKey
 	if parentID != nil {
-		if _, ok := s.commentsAvaliability[*parentID]; !ok {
-			return nil, fmt.Errorf("parent comment with ID %s not found", *parentID)
+		parent, ok := s.commentSearch[*parentID]
+		if !ok || parent.PostID != postID {
+			return nil, storage.Errorf(storage.ErrNotFound, "parent comment with ID %s not found in post %s", *parentID, postID)
+		}
+		for ancestor := parent; ancestor != nil; ancestor = s.parentOf(ancestor) {
+			if ancestor.IsLocked {
+				return nil, storage.ErrThreadLocked
+			}
 		}
 		parentKey = *parentID
 	}
 
+	now := s.now()
+	if err := storage.CheckSlowMode(post.SlowModeSeconds, params.AuthorID, s.lastCommentAt[postID][params.AuthorID], now); err != nil {
+		return nil, err
+	}
+
+	entities := storage.ExtractEntities(text)
 	comment := &model.Comment{
-		ID:        uuid.New().String(),
+		ID:        s.newID(),
 		PostID:    postID,
 		ParentID:  parentID,
+		AuthorID:  nullableID(params.AuthorID),
 		Text:      text,
-		CreatedAt: time.Now().Format(time.RFC3339),
+		Mentions:  entities.Mentions,
+		Hashtags:  entities.Hashtags,
+		CreatedAt: now,
+		Status:    model.ModerationStatusVisible,
+		Version:   storage.InitialVersion,
+	}
+
+	if params.AuthorID != "" {
+		if _, ok := s.lastCommentAt[postID]; !ok {
+			s.lastCommentAt[postID] = make(map[string]time.Time)
+		}
+		s.lastCommentAt[postID][params.AuthorID] = now
 	}
 
 	//Добавляем комментарий в мапу
@@ -103,144 +390,791 @@ func (s *InMemoryStorage) AddComment(postID string, parentID *string, text strin
 	s.commentsByPostAndParent[postID][parentKey] = append(
 		s.commentsByPostAndParent[postID][parentKey], comment)
 
-	s.commentsAvaliability[comment.ID] = true //Обновили признак существования комментария
+	s.commentSearch[comment.ID] = comment //Обновили признак существования комментария
+	s.updateCounters(comment, 1)
+	s.notifyMentioned(entities.MentionedUsers(), postID, &comment.ID)
 
-	if subscribers, ok := s.subscribers[postID]; ok { //Рассылка комментария подписчикам
-		for _, ch := range subscribers {
-			select {
-			case ch <- comment:
-				{
-					fmt.Printf("New comment for %s\n", postID)
-				}
-			default:
-			}
-		}
-	}
+	s.subscribers.Publish(postID, comment) //Рассылка комментария подписчикам
 
 	return comment, nil
 }
 
-// Список из limit постов начиная с offset
-func (s *InMemoryStorage) GetPosts(limit, offset int32) ([]*model.Post, error) {
+// Список из limit опубликованных постов начиная с offset
+func (s *InMemoryStorage) GetPosts(limit, offset int32, createdIn storage.TimeRange) ([]*model.Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	totalPosts := len(s.posts)
-	if int(offset) >= totalPosts {
-		return []*model.Post{}, nil
-	}
+	result := make([]*model.Post, 0, limit)
+	skipped := int32(0)
 
-	maxPosts := totalPosts - int(offset)
-	if int(limit) > maxPosts {
-		limit = int32(maxPosts)
+	// Выдаем новые посты первыми (проходим список с конца)
+	for i := len(s.posts) - 1; i >= 0 && int32(len(result)) < limit; i-- {
+		post := s.posts[i]
+		if !createdIn.Contains(post.CreatedAt) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		result = append(result, clonePost(post))
 	}
 
+	return result, nil
+}
+
+// Посты с тегом после курсора, новые первыми
+func (s *InMemoryStorage) GetPostsByTag(tag string, limit int32, after *storage.PostCursor) ([]*model.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tagged := s.postsByTag[storage.NormalizeTag(tag)]
 	result := make([]*model.Post, 0, limit)
 
-	// Выдаем новые посты первыми (проходим список с конца)
-	for i := int(offset); i < int(offset)+int(limit); i++ {
-		postIndex := totalPosts - 1 - i
-		result = append(result, s.posts[postIndex])
+	for i := len(tagged) - 1; i >= 0 && int32(len(result)) < limit; i-- {
+		if after != nil && !after.Precedes(tagged[i]) {
+			continue
+		}
+		result = append(result, clonePost(tagged[i]))
 	}
 
 	return result, nil
 }
 
+// Теги, начинающиеся с prefix: сначала самые популярные
+func (s *InMemoryStorage) GetTags(prefix string, limit int32) ([]*model.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix = storage.NormalizeTag(prefix)
+	tags := make([]*model.Tag, 0)
+	for name, posts := range s.postsByTag {
+		if strings.HasPrefix(name, prefix) {
+			tags = append(tags, &model.Tag{Name: name, PostCount: int32(len(posts))})
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	if int(limit) < len(tags) {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
 // Подписка на уведомления про новые комментарии к посту
 func (s *InMemoryStorage) SubscribeToComments(postID string) (<-chan *model.Comment, *func(), error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.postsCommentsEnable[postID]; !ok {
+		return nil, nil, storage.PostNotFound(postID)
+	}
+
+	ch, unsubscribe := s.subscribers.Subscribe(postID)
+	return ch, &unsubscribe, nil
+}
+
+// Уведомления пользователя, новые первыми
+func (s *InMemoryStorage) GetNotifications(userID string, limit, offset int32) ([]*model.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifications := s.notifications[userID]
+	result := make([]*model.Notification, 0, limit)
+	for i := len(notifications) - 1 - int(offset); i >= 0 && int32(len(result)) < limit; i-- {
+		result = append(result, notifications[i])
+	}
+
+	return result, nil
+}
+
+// Подписка на новые уведомления пользователя
+func (s *InMemoryStorage) SubscribeToNotifications(userID string) (<-chan *model.Notification, *func(), error) {
+	ch, unsubscribe := s.notificationSubscribers.Subscribe(userID)
+	return ch, &unsubscribe, nil
+}
+
+// Уведомления упомянутым пользователям. Вызывается под блокировкой
+func (s *InMemoryStorage) notifyMentioned(users []string, postID string, commentID *string) {
+	for _, userID := range users {
+		notification := &model.Notification{
+			ID:        s.newID(),
+			UserID:    userID,
+			Kind:      model.NotificationKindMention,
+			PostID:    postID,
+			CommentID: commentID,
+			CreatedAt: s.now(),
+		}
+		s.notifications[userID] = append(s.notifications[userID], notification)
+
+		s.notificationSubscribers.Publish(userID, notification)
+	}
+}
+
+// Включение/выключение комментариев к посту
+func (s *InMemoryStorage) SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.postsCommentsEnable[postID]; !ok {
-		return nil, nil, fmt.Errorf("post with ID %s not found", postID)
+	post, ok := s.postSearch[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	if err := storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+
+	post.Version++
+	post.CommentsEnabled = enabled
+	s.postsCommentsEnable[postID] = enabled
+	return clonePost(post), nil
+}
+
+// Правило автоматического закрытия комментариев к посту
+func (s *InMemoryStorage) SetCommentsPolicy(postID string, policy storage.CommentsPolicy, expectedVersion *int32) (*model.Post, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
 	}
 
-	ch := make(chan *model.Comment, 1)
-	s.subscribers[postID] = append(s.subscribers[postID], ch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	rmSubscription := func() {
-		s.mu.Lock()
-		defer s.mu.Unlock()
+	post, ok := s.postSearch[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	if err := storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+
+	post.Version++
+	s.commentsPolicies[postID] = policy
+	post.MaxComments = policy.Limit()
+	post.CommentsCloseAt = nil
+	if post.Status == model.PostStatusPublished {
+		post.CommentsCloseAt = policy.CloseAt(post.CreatedAt)
+	}
+	return clonePost(post), nil
+}
+
+// Закрепление и открепление корневого комментария
+func (s *InMemoryStorage) SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+	if comment.ParentID != nil {
+		return nil, storage.Errorf(storage.ErrInvalidArgument, "only root comments can be pinned")
+	}
+	if err := storage.CheckVersion("comment", commentID, expectedVersion, comment.Version); err != nil {
+		return nil, err
+	}
 
-		subs := s.subscribers[postID]
-		for i, subscriber := range subs {
-			if subscriber == ch {
-				s.subscribers[postID] = append(subs[:i], subs[i+1:]...)
+	postPinned := s.pinned[comment.PostID]
+	switch {
+	case pinned == comment.IsPinned:
+	case pinned:
+		if len(postPinned) >= storage.MaxPinnedComments {
+			return nil, storage.Errorf(storage.ErrConflict, "too many pinned comments: maximum allowed is %d", storage.MaxPinnedComments)
+		}
+		s.pinned[comment.PostID] = append(postPinned, comment)
+	default:
+		for i, c := range postPinned {
+			if c == comment {
+				s.pinned[comment.PostID] = append(postPinned[:i:i], postPinned[i+1:]...)
 				break
 			}
 		}
-		close(ch)
 	}
+	comment.IsPinned = pinned
+	comment.Version++
 
-	return ch, &rmSubscription, nil
+	result := *comment
+	storage.ApplyModeration(&result)
+	return &result, nil
 }
 
-// Включение/выключение комментариев к посту
-func (s *InMemoryStorage) SetCommentsEnabled(postID string, enabled bool) (*model.Post, error) {
+// Блокировка и разблокировка ответов под комментарием
+func (s *InMemoryStorage) SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, post := range s.posts {
-		if post.ID == postID {
-			post.CommentsEnabled = enabled
-			s.postsCommentsEnable[postID] = enabled
-			return post, nil
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+	if err := storage.CheckVersion("comment", commentID, expectedVersion, comment.Version); err != nil {
+		return nil, err
+	}
+	comment.IsLocked = locked
+	comment.Version++
+
+	result := *comment
+	storage.ApplyModeration(&result)
+	return &result, nil
+}
+
+// Медленный режим комментариев к посту
+func (s *InMemoryStorage) SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.postSearch[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	if err := storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+	post.SlowModeSeconds = seconds
+	post.Version++
+	return clonePost(post), nil
+}
+
+// Комментарий по ID (без ответов)
+func (s *InMemoryStorage) GetComment(commentID string) (*model.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+
+	result := *comment
+	storage.ApplyModeration(&result)
+	return &result, nil
+}
+
+// Правка текста поста
+func (s *InMemoryStorage) EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.postSearch[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	if err := storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	s.revisions[postID] = storage.AppendRevision(s.revisions[postID], post.Text, post.AuthorID, post.CreatedAt, text, editorID, now)
+
+	entities := storage.ExtractEntities(text)
+	post.Text = text
+	post.Mentions = entities.Mentions
+	post.Hashtags = entities.Hashtags
+	post.EditedAt = &now
+	post.Version++
+	return clonePost(post), nil
+}
+
+// Правка текста комментария
+func (s *InMemoryStorage) EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+	if err := storage.CheckVersion("comment", commentID, expectedVersion, comment.Version); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	s.revisions[commentID] = storage.AppendRevision(s.revisions[commentID], comment.Text, comment.AuthorID, comment.CreatedAt, text, editorID, now)
+
+	entities := storage.ExtractEntities(text)
+	comment.Text = text
+	comment.Mentions = entities.Mentions
+	comment.Hashtags = entities.Hashtags
+	comment.EditedAt = &now
+	comment.Version++
+
+	result := *comment
+	storage.ApplyModeration(&result)
+	return &result, nil
+}
+
+// Версии текста поста или комментария. Пустой список, если текст не правили
+func (s *InMemoryStorage) GetRevisions(targetID string) ([]*model.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.postSearch[targetID]; !ok {
+		if _, ok := s.commentSearch[targetID]; !ok {
+			return nil, storage.Errorf(storage.ErrNotFound, "post or comment with ID %s not found", targetID)
 		}
 	}
 
-	return nil, fmt.Errorf("post with ID %s not found", postID)
+	revisions := make([]*model.Revision, len(s.revisions[targetID]))
+	copy(revisions, s.revisions[targetID])
+	return revisions, nil
 }
 
 // Запрос комментариев к посту и ответов к ним
-func (s *InMemoryStorage) GetCommentsTree(postID string, limit, offset int32) ([]*model.Comment, error) {
+func (s *InMemoryStorage) GetCommentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	if _, ok := s.postsCommentsEnable[postID]; !ok {
-		return nil, fmt.Errorf("post with ID %s not found", postID)
+		return nil, storage.PostNotFound(postID)
 	}
 
+	return s.commentsTree(postID, limit, offset, createdIn), nil
+}
+
+// Комментарии для нескольких постов. Несуществующие посты в результат не попадают
+func (s *InMemoryStorage) GetCommentsTrees(postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]*model.Comment, len(postIDs))
+	for _, postID := range postIDs {
+		if _, ok := s.postsCommentsEnable[postID]; ok {
+			result[postID] = s.commentsTree(postID, limit, offset, createdIn)
+		}
+	}
+
+	return result, nil
+}
+
+// Страница корневых комментариев поста с ответами. Вызывается под блокировкой
+func (s *InMemoryStorage) commentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) []*model.Comment {
 	postComments := s.commentsByPostAndParent[postID]
 	if postComments == nil {
-		return []*model.Comment{}, nil
+		return []*model.Comment{}
 	}
 
-	// Получаем корневые комментарии
-	rootComments := postComments[rootKey]
-
-	if int(offset) >= len(rootComments) {
-		return []*model.Comment{}, nil
+	// Закрепленные комментарии идут первыми на любой странице, последний закрепленный - выше
+	postPinned := s.pinned[postID]
+	currentRootComments := make([]*model.Comment, 0, len(postPinned)+int(limit))
+	for i := len(postPinned) - 1; i >= 0; i-- {
+		if createdIn.Contains(postPinned[i].CreatedAt) {
+			currentRootComments = append(currentRootComments, postPinned[i])
+		}
 	}
 
-	end := int(offset + limit)
-	if end > len(rootComments) {
-		end = len(rootComments)
+	// Остальные корневые комментарии из нужного интервала с пагинацией
+	rootComments := make([]*model.Comment, 0, len(postComments[rootKey]))
+	for _, root := range postComments[rootKey] {
+		if !root.IsPinned && createdIn.Contains(root.CreatedAt) {
+			rootComments = append(rootComments, root)
+		}
 	}
 
-	currentRootComments := rootComments[offset:end]
+	if int(offset) < len(rootComments) {
+		end := int(offset + limit)
+		if end > len(rootComments) {
+			end = len(rootComments)
+		}
+		currentRootComments = append(currentRootComments, rootComments[offset:end]...)
+	}
 
-	// Рекурсивный обход ответов на корневые комментарии
-	var attachChildren func(comment *model.Comment)
-	attachChildren = func(comment *model.Comment) {
+	// Рекурсивный обход ответов на корневые комментарии.
+	// Комментарии копируются, чтобы заглушки модерации не попали в хранилище
+	var copyWithChildren func(comment *model.Comment) *model.Comment
+	copyWithChildren = func(comment *model.Comment) *model.Comment {
+		result := *comment
+		storage.ApplyModeration(&result)
 		if children, ok := postComments[comment.ID]; ok {
-			comment.Replies = children
-			for _, child := range children {
-				attachChildren(child)
+			result.Replies = make([]*model.Comment, len(children))
+			for i, child := range children {
+				result.Replies[i] = copyWithChildren(child)
 			}
 		}
+		return &result
 	}
 
 	result := make([]*model.Comment, len(currentRootComments))
 	for i, root := range currentRootComments {
-		result[i] = &model.Comment{
-			ID:        root.ID,
-			PostID:    root.PostID,
-			ParentID:  root.ParentID,
-			Text:      root.Text,
-			CreatedAt: root.CreatedAt,
+		result[i] = copyWithChildren(root)
+	}
+
+	return result
+}
+
+// Жалоба на комментарий
+func (s *InMemoryStorage) ReportComment(commentID, reporterID, reason string) (*model.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+
+	openReports := 0
+	for _, report := range s.reports[commentID] {
+		if report.Resolved {
+			continue
+		}
+		if report.ReporterID == reporterID {
+			return nil, storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already reported by this user", commentID)
+		}
+		openReports++
+	}
+
+	report := &model.Report{
+		ID:         s.newID(),
+		TargetID:   commentID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		CreatedAt:  s.now(),
+	}
+	s.reports[commentID] = append(s.reports[commentID], report)
+	s.reportLog = append(s.reportLog, report)
+
+	// Набралось много жалоб - скрываем комментарий до решения модератора
+	if openReports+1 >= storage.ReportsToHold && comment.Status == model.ModerationStatusVisible {
+		s.setStatus(comment, model.ModerationStatusHeld)
+	}
+
+	return report, nil
+}
+
+// Очередь модерации: комментарии с открытыми жалобами в порядке первой жалобы
+func (s *InMemoryStorage) GetModerationQueue(limit, offset int32) (*model.ModerationQueue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queue := &model.ModerationQueue{Items: []*model.ModerationItem{}}
+	var items []*model.ModerationItem
+	seen := make(map[string]bool)
+
+	for _, report := range s.reportLog {
+		if report.Resolved || seen[report.TargetID] {
+			continue
+		}
+		seen[report.TargetID] = true
+
+		// Копии: хранимые комментарий и жалобы меняются под блокировкой. Модератор видит исходный текст,
+		// как и в других хранилищах
+		comment := *s.commentSearch[report.TargetID]
+		comment.Replies = nil
+		item := &model.ModerationItem{Comment: &comment, Reports: []*model.Report{}}
+		for _, r := range s.reports[report.TargetID] {
+			if !r.Resolved {
+				reportCopy := *r
+				item.Reports = append(item.Reports, &reportCopy)
+			}
+		}
+		item.ReportCount = int32(len(item.Reports))
+		items = append(items, item)
+
+		if comment.Status == model.ModerationStatusHeld {
+			queue.HeldCount++
+		}
+	}
+
+	queue.Total = int32(len(items))
+	queue.ReportedCount = int32(len(items))
+
+	if int(offset) >= len(items) {
+		return queue, nil
+	}
+
+	end := int(offset + limit)
+	if end > len(items) {
+		end = len(items)
+	}
+	queue.Items = items[offset:end]
+
+	return queue, nil
+}
+
+// Решение модератора: меняет статус комментария, закрывает жалобы и пишет запись в журнал
+func (s *InMemoryStorage) ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.commentSearch[commentID]
+	if !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+	if err := storage.CheckVersion("comment", commentID, expectedVersion, comment.Version); err != nil {
+		return nil, err
+	}
+
+	s.setStatus(comment, storage.StatusForAction(action))
+	for _, report := range s.reports[commentID] {
+		report.Resolved = true
+	}
+
+	s.decisions[commentID] = append(s.decisions[commentID], &model.ModerationDecision{
+		ID:          s.newID(),
+		TargetID:    commentID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		Reason:      reason,
+		CreatedAt:   s.now(),
+	})
+
+	result := *comment
+	storage.ApplyModeration(&result)
+	return &result, nil
+}
+
+// История решений модераторов по комментарию
+func (s *InMemoryStorage) GetModerationLog(commentID string) ([]*model.ModerationDecision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.commentSearch[commentID]; !ok {
+		return nil, storage.CommentNotFound(commentID)
+	}
+
+	return append([]*model.ModerationDecision{}, s.decisions[commentID]...), nil
+}
+
+// Смена статуса модерации с пересчетом счетчиков: скрытые комментарии не считаются
+func (s *InMemoryStorage) setStatus(comment *model.Comment, status model.ModerationStatus) {
+	wasCounted := storage.IsCounted(comment.Status)
+	comment.Status = status
+	comment.Version++
+
+	switch isCounted := storage.IsCounted(status); {
+	case isCounted && !wasCounted:
+		s.updateCounters(comment, 1)
+	case !isCounted && wasCounted:
+		s.updateCounters(comment, -1)
+	}
+}
+
+// Изменение счетчиков поста и предков комментария на delta
+func (s *InMemoryStorage) updateCounters(comment *model.Comment, delta int32) {
+	if post, ok := s.postSearch[comment.PostID]; ok {
+		post.CommentCount += delta
+		if comment.ParentID == nil {
+			post.RootCommentCount += delta
 		}
-		attachChildren(result[i])
 	}
 
+	if comment.ParentID == nil {
+		return
+	}
+
+	parent := s.commentSearch[*comment.ParentID]
+	parent.ReplyCount += delta
+	for parent != nil {
+		parent.DescendantCount += delta
+		if parent.ParentID == nil {
+			break
+		}
+		parent = s.commentSearch[*parent.ParentID]
+	}
+}
+
+// Родительский комментарий или nil для корневого. Вызывается под блокировкой
+func (s *InMemoryStorage) parentOf(comment *model.Comment) *model.Comment {
+	if comment.ParentID == nil {
+		return nil
+	}
+	return s.commentSearch[*comment.ParentID]
+}
+
+func nullableID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// Посты с комментариями для выгрузки: сначала опубликованные, затем неопубликованные
+func (s *InMemoryStorage) ExportPosts(limit, offset int32) ([]*storage.PostExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]*model.Post, 0, len(s.posts)+len(s.drafts))
+	all = append(append(all, s.posts...), s.drafts...)
+	if int(offset) >= len(all) {
+		return []*storage.PostExport{}, nil
+	}
+	all = all[offset:min(len(all), int(offset+limit))]
+
+	result := make([]*storage.PostExport, 0, len(all))
+	for _, post := range all {
+		export := &storage.PostExport{Post: clonePost(post), Policy: s.commentsPolicies[post.ID], Comments: []*model.Comment{}}
+
+		for _, comments := range s.commentsByPostAndParent[post.ID] {
+			for _, c := range comments {
+				comment := *c
+				comment.Replies = nil
+				export.Comments = append(export.Comments, &comment)
+			}
+		}
+		sort.Slice(export.Comments, func(i, j int) bool {
+			return export.Comments[i].CreatedAt.Before(export.Comments[j].CreatedAt)
+		})
+		result = append(result, export)
+	}
 	return result, nil
 }
+
+// Загрузка поста с комментариями. Счетчики пересчитываются, упомянутые не получают уведомлений
+func (s *InMemoryStorage) ImportPost(export *storage.PostExport) error {
+	if err := export.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// ID уникальны на всем сервере, как и в базах, где они первичные ключи
+	for _, space := range s.spaces {
+		if _, ok := space.postSearch[export.Post.ID]; ok {
+			return storage.Errorf(storage.ErrAlreadyExists, "post with ID %s already exists", export.Post.ID)
+		}
+		for _, c := range export.Comments {
+			if _, ok := space.commentSearch[c.ID]; ok {
+				return storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already exists", c.ID)
+			}
+		}
+	}
+
+	post := *export.Post
+	post.Tags = slices.Clone(post.Tags)
+	entities := storage.ExtractEntities(post.Text)
+	post.Mentions, post.Hashtags = entities.Mentions, entities.Hashtags
+	post.CommentCount, post.RootCommentCount = 0, 0
+	post.MaxComments = export.Policy.Limit()
+	post.CommentsCloseAt = nil
+	post.Version = max(post.Version, storage.InitialVersion)
+	if post.Slug == "" {
+		post.Slug = storage.MakeSlug(post.Title, post.Text, post.ID)
+	}
+
+	s.postsCommentsEnable[post.ID] = post.CommentsEnabled
+	s.commentsPolicies[post.ID] = export.Policy
+	s.postSearch[post.ID] = &post
+	if post.Status == model.PostStatusPublished {
+		post.PublishAt = nil
+		post.CommentsCloseAt = export.Policy.CloseAt(post.CreatedAt)
+		s.posts = insertByTime(s.posts, &post)
+		for _, tag := range post.Tags {
+			s.postsByTag[tag] = insertByTime(s.postsByTag[tag], &post)
+		}
+	} else {
+		s.drafts = append(s.drafts, &post)
+	}
+
+	if len(export.Comments) > 0 {
+		s.commentsByPostAndParent[post.ID] = make(map[string][]*model.Comment)
+		s.lastCommentAt[post.ID] = make(map[string]time.Time)
+	}
+	for _, c := range export.Comments {
+		entities := storage.ExtractEntities(c.Text)
+		comment := &model.Comment{
+			ID:        c.ID,
+			PostID:    post.ID,
+			ParentID:  c.ParentID,
+			AuthorID:  c.AuthorID,
+			Text:      c.Text,
+			Mentions:  entities.Mentions,
+			Hashtags:  entities.Hashtags,
+			Status:    c.Status,
+			IsPinned:  c.IsPinned && c.ParentID == nil,
+			IsLocked:  c.IsLocked,
+			CreatedAt: c.CreatedAt,
+			EditedAt:  c.EditedAt,
+			Version:   max(c.Version, storage.InitialVersion),
+		}
+
+		parentKey := rootKey
+		if comment.ParentID != nil {
+			parentKey = *comment.ParentID
+		}
+		s.commentsByPostAndParent[post.ID][parentKey] = append(s.commentsByPostAndParent[post.ID][parentKey], comment)
+		s.commentSearch[comment.ID] = comment
+		if comment.IsPinned {
+			s.pinned[post.ID] = append(s.pinned[post.ID], comment)
+		}
+		if storage.IsCounted(comment.Status) {
+			s.updateCounters(comment, 1)
+		}
+		if comment.AuthorID != nil {
+			s.lastCommentAt[post.ID][*comment.AuthorID] = comment.CreatedAt
+		}
+	}
+	return nil
+}
+
+// Вставка поста в список, упорядоченный по времени публикации
+func insertByTime(posts []*model.Post, post *model.Post) []*model.Post {
+	cursor := storage.CursorFor(post)
+	i := sort.Search(len(posts), func(i int) bool { return !cursor.Precedes(posts[i]) })
+	return append(posts[:i], append([]*model.Post{post}, posts[i:]...)...)
+}
+
+// Занять ключ идемпотентности или вернуть ответ прежней мутации с этим ключом
+func (s *InMemoryStorage) ClaimIdempotencyKey(key storage.IdempotencyKey, operation string, ttl time.Duration) (*storage.IdempotencyRecord, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweepIdempotency(ttl, now)
+
+	if rec, ok := s.idempotency[key]; ok && !rec.Expired(ttl, now) {
+		replay, err := rec.Replay(key, operation)
+		if err != nil {
+			return nil, err
+		}
+		copied := *replay
+		return &copied, nil
+	}
+	s.idempotency[key] = &storage.IdempotencyRecord{Operation: operation, CreatedAt: now}
+	return nil, nil
+}
+
+// Удаление устаревших ключей не чаще раза в IdempotencyPendingTimeout. Вызывается под блокировкой
+func (s *InMemoryStorage) sweepIdempotency(ttl time.Duration, now time.Time) {
+	if now.Sub(s.idempotencySwept) < storage.IdempotencyPendingTimeout {
+		return
+	}
+	s.idempotencySwept = now
+	for key, rec := range s.idempotency {
+		if rec.Expired(ttl, now) {
+			delete(s.idempotency, key)
+		}
+	}
+}
+
+// Запомнить ответ мутации с занятым ключом
+func (s *InMemoryStorage) CompleteIdempotencyKey(key storage.IdempotencyKey, result []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.idempotency[key]
+	if !ok || rec.Result != nil {
+		return storage.Errorf(storage.ErrConflict, "clientMutationId %s is not claimed", key.Key)
+	}
+	rec.Result = append([]byte{}, result...)
+	return nil
+}
+
+// Освободить ключ неудавшейся мутации. Ключ с запомненным ответом не освобождается
+func (s *InMemoryStorage) ReleaseIdempotencyKey(key storage.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.idempotency[key]; ok && rec.Result == nil {
+		delete(s.idempotency, key)
+	}
+	return nil
+}
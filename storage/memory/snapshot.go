@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Снимок состояния хранилища. Пространство по умолчанию лежит на верхнем уровне, как в снимках
+// до появления пространств, остальные - в Spaces
+type snapshotState struct {
+	Seq uint64 `json:"seq"` // Последняя запись журнала в снимке
+	spaceSnapshot
+	Spaces map[string]*spaceSnapshot `json:"spaces,omitempty"`
+}
+
+// Снимок одного пространства. Индексы (поиск по ID, посты по тегам) не сохраняются,
+// а строятся заново при загрузке
+type spaceSnapshot struct {
+	Posts         []*model.Post                          `json:"posts"`
+	Drafts        []*model.Post                          `json:"drafts"`
+	Policies      map[string]storage.CommentsPolicy      `json:"policies"`
+	Comments      map[string]map[string][]*model.Comment `json:"comments"`
+	Pinned        map[string][]string                    `json:"pinned"`
+	LastCommentAt map[string]map[string]time.Time        `json:"lastCommentAt"`
+	Notifications map[string][]*model.Notification       `json:"notifications"`
+	Reports       []*model.Report                        `json:"reports"`
+	Decisions     map[string][]*model.ModerationDecision `json:"decisions"`
+	Revisions     map[string][]*model.Revision           `json:"revisions"`
+	Idempotency   []idempotencyEntry                     `json:"idempotency,omitempty"`
+}
+
+// Ключ идемпотентности в снимке: ключ-структура не кодируется ключом JSON-объекта
+type idempotencyEntry struct {
+	Key    storage.IdempotencyKey     `json:"key"`
+	Record *storage.IdempotencyRecord `json:"record"`
+}
+
+// Запись снимка всех пространств через временный файл: на диске всегда остается целый снимок, старый или новый
+func writeSnapshot(path string, s *InMemoryStorage, seq uint64) error {
+	s.mu.RLock()
+	state := snapshotState{Seq: seq, Spaces: make(map[string]*spaceSnapshot)}
+	for id, space := range s.spaces {
+		if id == storage.DefaultSpace {
+			state.spaceSnapshot = space.snapshot()
+		} else {
+			snapshot := space.snapshot()
+			state.Spaces[id] = &snapshot
+		}
+	}
+	data, err := json.Marshal(state)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// Состояние пространства для снимка. Вызывается под блокировкой хранилища
+func (s *spaceData) snapshot() spaceSnapshot {
+	pinned := make(map[string][]string, len(s.pinned))
+	for postID, comments := range s.pinned {
+		for _, c := range comments {
+			pinned[postID] = append(pinned[postID], c.ID)
+		}
+	}
+	idempotency := make([]idempotencyEntry, 0, len(s.idempotency))
+	for key, rec := range s.idempotency {
+		idempotency = append(idempotency, idempotencyEntry{Key: key, Record: rec})
+	}
+	return spaceSnapshot{
+		Posts:         s.posts,
+		Drafts:        s.drafts,
+		Policies:      s.commentsPolicies,
+		Comments:      s.commentsByPostAndParent,
+		Pinned:        pinned,
+		LastCommentAt: s.lastCommentAt,
+		Notifications: s.notifications,
+		Reports:       s.reportLog,
+		Decisions:     s.decisions,
+		Revisions:     s.revisions,
+		Idempotency:   idempotency,
+	}
+}
+
+// Загрузка снимка. Без файла снимка - пустое хранилище
+func loadSnapshot(path string) (*InMemoryStorage, uint64, error) {
+	s := New()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var state snapshotState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	s.spaceData.load(&state.spaceSnapshot)
+	for id, snapshot := range state.Spaces {
+		s.inSpace(id).load(snapshot)
+	}
+	return s, state.Seq, nil
+}
+
+// Загрузка пространства из снимка в пустое пространство
+func (s *spaceData) load(state *spaceSnapshot) {
+	for _, post := range state.Posts {
+		s.posts = append(s.posts, post)
+		for _, tag := range post.Tags {
+			s.postsByTag[tag] = append(s.postsByTag[tag], post)
+		}
+	}
+	s.drafts = state.Drafts
+	for _, post := range append(append([]*model.Post{}, state.Posts...), state.Drafts...) {
+		// В снимках до появления версий ее нет
+		post.Version = max(post.Version, storage.InitialVersion)
+		s.postSearch[post.ID] = post
+		s.postsCommentsEnable[post.ID] = post.CommentsEnabled
+	}
+
+	for postID, byParent := range state.Comments {
+		s.commentsByPostAndParent[postID] = byParent
+		for _, comments := range byParent {
+			for _, c := range comments {
+				c.Version = max(c.Version, storage.InitialVersion)
+				s.commentSearch[c.ID] = c
+			}
+		}
+	}
+	for postID, ids := range state.Pinned {
+		for _, id := range ids {
+			s.pinned[postID] = append(s.pinned[postID], s.commentSearch[id])
+		}
+	}
+
+	for _, report := range state.Reports {
+		s.reportLog = append(s.reportLog, report)
+		s.reports[report.TargetID] = append(s.reports[report.TargetID], report)
+	}
+
+	maps.Copy(s.commentsPolicies, state.Policies)
+	maps.Copy(s.lastCommentAt, state.LastCommentAt)
+	maps.Copy(s.notifications, state.Notifications)
+	maps.Copy(s.decisions, state.Decisions)
+	maps.Copy(s.revisions, state.Revisions)
+	for _, entry := range state.Idempotency {
+		s.idempotency[entry.Key] = entry.Record
+	}
+}
+
+// Сброс на диск записи каталога, чтобы переименование пережило сбой
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
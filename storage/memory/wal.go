@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+)
+
+// Формат журнала: записи подряд, у каждой заголовок из длины и CRC32 содержимого (big endian),
+// затем JSON записи. Оборванная или испорченная запись в конце - след сбоя во время записи,
+// она и все после нее отбрасываются
+const walHeaderSize = 8
+
+type walWriter struct {
+	file  *os.File
+	dirty bool // Есть записи, не сброшенные на диск
+}
+
+// Открытие журнала на дозапись. Файл обрезается до size - конца последней целой записи
+func openWAL(path string, size int64) (*walWriter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &walWriter{file: file}, nil
+}
+
+func (w *walWriter) Append(rec *record, sync bool) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	w.dirty = true
+	if sync {
+		return w.Sync()
+	}
+	return nil
+}
+
+func (w *walWriter) Sync() error {
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// Очистка журнала после снимка
+func (w *walWriter) Reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+func (w *walWriter) Close() error {
+	if err := w.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Чтение целых записей журнала. validSize - длина файла без оборванного хвоста
+func readWAL(path string) (records []*record, validSize int64, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	in := bufio.NewReader(file)
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(in, header); err != nil {
+			if err != io.EOF {
+				log.Printf("Memory storage: discarding torn write-ahead log record at offset %d", validSize)
+			}
+			return records, validSize, nil
+		}
+
+		// Длину из испорченного заголовка сверяем с файлом, чтобы не выделять лишнюю память.
+		// Пустых записей не бывает: нулевой заголовок - хвост файла, заполненный нулями при сбое
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		if size == 0 || validSize+walHeaderSize+size > info.Size() {
+			log.Printf("Memory storage: discarding torn write-ahead log record at offset %d", validSize)
+			return records, validSize, nil
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(in, payload); err != nil {
+			log.Printf("Memory storage: discarding torn write-ahead log record at offset %d", validSize)
+			return records, validSize, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			log.Printf("Memory storage: discarding corrupted write-ahead log record at offset %d", validSize)
+			return records, validSize, nil
+		}
+
+		var rec record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode write-ahead log record at offset %d: %w", validSize, err)
+		}
+		records = append(records, &rec)
+		validSize += int64(walHeaderSize + len(payload))
+	}
+}
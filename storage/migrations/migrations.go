@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Диалект SQL, для которого выбирается текст миграции
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// Версия схемы с текстом для каждого диалекта. Пустой текст - в диалекте менять нечего,
+// версия только отмечается примененной
+type Migration struct {
+	Version  int
+	Name     string
+	Postgres string
+	SQLite   string
+}
+
+// Миграции по возрастанию версии. Примененную миграцию не меняем, изменения схемы - новой версией
+var All = []Migration{
+	{Version: 1, Name: "initial schema", Postgres: postgresInitial, SQLite: sqliteInitial},
+	{Version: 2, Name: "comment counters", Postgres: postgresCounters},
+	{Version: 3, Name: "idempotency keys", Postgres: postgresIdempotency, SQLite: sqliteIdempotency},
+	{Version: 4, Name: "versions", Postgres: postgresVersions, SQLite: sqliteVersions},
+	{Version: 5, Name: "spaces", Postgres: postgresSpaces, SQLite: sqliteSpaces},
+}
+
+func (m Migration) query(dialect Dialect) string {
+	if dialect == SQLite {
+		return m.SQLite
+	}
+	return m.Postgres
+}
+
+// Применение недостающих миграций, каждой в своей транзакции. Одновременный запуск
+// нескольких серверов безопасен: транзакция берет блокировку и перепроверяет версию
+func Apply(db *sql.DB, dialect Dialect) error {
+	if dialect != Postgres && dialect != SQLite {
+		return fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INT PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, m := range All {
+		applied, err := apply(db, dialect, m)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if applied {
+			log.Printf("Applied migration %d: %s", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func apply(db *sql.DB, dialect Dialect, m Migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// В SQLite транзакция записи и так единственная, в Postgres ждем параллельный сервер
+	if dialect == Postgres {
+		if _, err := tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+			return false, err
+		}
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version).Scan(&exists)
+	if err != nil || exists {
+		return false, err
+	}
+
+	if query := m.query(dialect); query != "" {
+		if _, err := tx.Exec(query); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
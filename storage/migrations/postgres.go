@@ -0,0 +1,196 @@
+package migrations
+
+// Исходная схема Postgres. Базы, созданные до появления миграций, уже содержат часть таблиц
+// и колонок, поэтому все изменения идемпотентны (IF NOT EXISTS)
+const postgresInitial = `
+        CREATE TABLE IF NOT EXISTS posts (
+            id VARCHAR(36) PRIMARY KEY,
+            author_id VARCHAR(64),
+            title TEXT NOT NULL DEFAULT '',
+            slug VARCHAR(128) NOT NULL DEFAULT '',
+            text TEXT NOT NULL,
+            entities JSONB NOT NULL DEFAULT '{}',
+            comments_enabled BOOLEAN NOT NULL DEFAULT true,
+            status VARCHAR(16) NOT NULL DEFAULT 'PUBLISHED',
+            publish_at TIMESTAMP WITH TIME ZONE,
+            comments_close_days INT NOT NULL DEFAULT 0,
+            max_comments INT NOT NULL DEFAULT 0,
+            slow_mode_seconds INT NOT NULL DEFAULT 0,
+            comment_count INT NOT NULL DEFAULT 0,
+            root_comment_count INT NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            edited_at TIMESTAMP WITH TIME ZONE
+        );
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(128) NOT NULL DEFAULT '';
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS entities JSONB NOT NULL DEFAULT '{}';
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_id VARCHAR(64);
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'PUBLISHED';
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS comments_close_days INT NOT NULL DEFAULT 0;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS max_comments INT NOT NULL DEFAULT 0;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS slow_mode_seconds INT NOT NULL DEFAULT 0;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP WITH TIME ZONE;
+        UPDATE posts SET slug = 'post-' || LEFT(id, 8) WHERE slug = '';
+
+        CREATE TABLE IF NOT EXISTS post_tags (
+            post_id VARCHAR(36) NOT NULL,
+            tag VARCHAR(32) NOT NULL,
+            PRIMARY KEY (post_id, tag),
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE IF NOT EXISTS comments (
+            id VARCHAR(36) PRIMARY KEY,
+            post_id VARCHAR(36) NOT NULL,
+            parent_id VARCHAR(36),
+            author_id VARCHAR(64),
+            text TEXT NOT NULL,
+            entities JSONB NOT NULL DEFAULT '{}',
+            status VARCHAR(16) NOT NULL DEFAULT 'VISIBLE',
+            reply_count INT NOT NULL DEFAULT 0,
+            descendant_count INT NOT NULL DEFAULT 0,
+            pinned_at TIMESTAMP WITH TIME ZONE,
+            locked BOOLEAN NOT NULL DEFAULT false,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            edited_at TIMESTAMP WITH TIME ZONE,
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+            FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'VISIBLE';
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS entities JSONB NOT NULL DEFAULT '{}';
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMP WITH TIME ZONE;
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS author_id VARCHAR(64);
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT false;
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP WITH TIME ZONE;
+
+        -- Версии текста постов и комментариев (target_id - ID поста или комментария)
+        CREATE TABLE IF NOT EXISTS revisions (
+            target_id VARCHAR(36) NOT NULL,
+            number INT NOT NULL,
+            text TEXT NOT NULL,
+            editor_id VARCHAR(64),
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            PRIMARY KEY (target_id, number)
+        );
+
+        CREATE TABLE IF NOT EXISTS notifications (
+            id VARCHAR(36) PRIMARY KEY,
+            user_id VARCHAR(64) NOT NULL,
+            kind VARCHAR(16) NOT NULL,
+            post_id VARCHAR(36) NOT NULL,
+            comment_id VARCHAR(36),
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE IF NOT EXISTS reports (
+            id VARCHAR(36) PRIMARY KEY,
+            comment_id VARCHAR(36) NOT NULL,
+            reporter_id VARCHAR(64) NOT NULL,
+            reason TEXT NOT NULL,
+            resolved BOOLEAN NOT NULL DEFAULT false,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE IF NOT EXISTS moderation_decisions (
+            id VARCHAR(36) PRIMARY KEY,
+            comment_id VARCHAR(36) NOT NULL,
+            moderator_id VARCHAR(64) NOT NULL,
+            action VARCHAR(16) NOT NULL,
+            reason TEXT,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
+        CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
+        CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments(created_at);
+        CREATE INDEX IF NOT EXISTS idx_comments_post_author ON comments(post_id, author_id, created_at);
+        CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);
+        CREATE INDEX IF NOT EXISTS idx_posts_slug ON posts(slug);
+        CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE status = 'SCHEDULED';
+        CREATE INDEX IF NOT EXISTS idx_posts_drafts ON posts(author_id, created_at) WHERE status <> 'PUBLISHED';
+        CREATE INDEX IF NOT EXISTS idx_post_tags_tag ON post_tags(tag varchar_pattern_ops);
+        CREATE INDEX IF NOT EXISTS idx_reports_open ON reports(comment_id) WHERE NOT resolved;
+        CREATE INDEX IF NOT EXISTS idx_moderation_decisions_comment_id ON moderation_decisions(comment_id);
+        CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+
+        -- Бакеты ограничения запросов, общие для реплик. В SQLite их нет: лимиты там in-process
+        CREATE TABLE IF NOT EXISTS rate_limits (
+            key VARCHAR(128) PRIMARY KEY,
+            tokens DOUBLE PRECISION NOT NULL,
+            updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+        );
+`
+
+// Счетчики комментариев для БД, созданной до их появления: добавляем колонки и пересчитываем значения
+const postgresCounters = `
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count INT NOT NULL DEFAULT 0;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS root_comment_count INT NOT NULL DEFAULT 0;
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS reply_count INT NOT NULL DEFAULT 0;
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS descendant_count INT NOT NULL DEFAULT 0;
+
+        UPDATE posts p SET
+            comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.status = 'VISIBLE'),
+            root_comment_count = (SELECT COUNT(*) FROM comments c
+                                  WHERE c.post_id = p.id AND c.parent_id IS NULL AND c.status = 'VISIBLE');
+
+        UPDATE comments p SET
+            reply_count = (SELECT COUNT(*) FROM comments c WHERE c.parent_id = p.id AND c.status = 'VISIBLE');
+
+        WITH RECURSIVE tree AS (
+            SELECT id AS ancestor_id, id FROM comments
+            UNION ALL
+            SELECT t.ancestor_id, c.id FROM comments c JOIN tree t ON c.parent_id = t.id
+        )
+        UPDATE comments p SET
+            descendant_count = (SELECT COUNT(*) FROM tree t JOIN comments c ON c.id = t.id
+                                WHERE t.ancestor_id = p.id AND t.id <> p.id AND c.status = 'VISIBLE');
+`
+
+// Ключи идемпотентности мутаций: ответ первого вызова, пока ключ не устарел
+const postgresIdempotency = `
+        CREATE TABLE IF NOT EXISTS idempotency_keys (
+            scope VARCHAR(128) NOT NULL,
+            key VARCHAR(255) NOT NULL,
+            operation VARCHAR(64) NOT NULL,
+            result TEXT,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            PRIMARY KEY (scope, key)
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+`
+
+// Версии постов и комментариев для оптимистичной блокировки: существующие записи начинают с 1
+const postgresVersions = `
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+`
+
+// Пространства (сообщества): существующие данные попадают в пространство default. Комментарии
+// и уведомления хранят пространство сами, чтобы поиск по ID не соединял таблицы с постами.
+// Индексы по времени, slug и черновикам начинаются с пространства: запросы всегда внутри одного.
+// Ключи идемпотентности уникальны в пределах пространства
+const postgresSpaces = `
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS space_id VARCHAR(64) NOT NULL DEFAULT 'default';
+        ALTER TABLE comments ADD COLUMN IF NOT EXISTS space_id VARCHAR(64) NOT NULL DEFAULT 'default';
+        ALTER TABLE notifications ADD COLUMN IF NOT EXISTS space_id VARCHAR(64) NOT NULL DEFAULT 'default';
+        ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS space_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+        ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+        ALTER TABLE idempotency_keys ADD PRIMARY KEY (space_id, scope, key);
+
+        DROP INDEX IF EXISTS idx_posts_created_at;
+        DROP INDEX IF EXISTS idx_posts_slug;
+        DROP INDEX IF EXISTS idx_posts_drafts;
+        DROP INDEX IF EXISTS idx_notifications_user_id;
+        CREATE INDEX IF NOT EXISTS idx_posts_space_created_at ON posts(space_id, created_at);
+        CREATE INDEX IF NOT EXISTS idx_posts_space_slug ON posts(space_id, slug);
+        CREATE INDEX IF NOT EXISTS idx_posts_space_drafts ON posts(space_id, author_id, created_at) WHERE status <> 'PUBLISHED';
+        CREATE INDEX IF NOT EXISTS idx_comments_space_id ON comments(space_id, id);
+        CREATE INDEX IF NOT EXISTS idx_notifications_space_user ON notifications(space_id, user_id, created_at);
+`
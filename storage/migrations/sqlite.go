@@ -0,0 +1,151 @@
+package migrations
+
+// Исходная схема SQLite. Время хранится целым числом микросекунд Unix, чтобы сравнения
+// и сортировка шли по числу, упоминания и хештеги - JSON-текстом, логические значения - 0/1
+const sqliteInitial = `
+        CREATE TABLE posts (
+            id TEXT PRIMARY KEY,
+            author_id TEXT,
+            title TEXT NOT NULL DEFAULT '',
+            slug TEXT NOT NULL DEFAULT '',
+            text TEXT NOT NULL,
+            entities TEXT NOT NULL DEFAULT '{}',
+            comments_enabled INTEGER NOT NULL DEFAULT 1,
+            status TEXT NOT NULL DEFAULT 'PUBLISHED',
+            publish_at INTEGER,
+            comments_close_days INTEGER NOT NULL DEFAULT 0,
+            max_comments INTEGER NOT NULL DEFAULT 0,
+            slow_mode_seconds INTEGER NOT NULL DEFAULT 0,
+            comment_count INTEGER NOT NULL DEFAULT 0,
+            root_comment_count INTEGER NOT NULL DEFAULT 0,
+            created_at INTEGER NOT NULL,
+            edited_at INTEGER
+        );
+
+        CREATE TABLE post_tags (
+            post_id TEXT NOT NULL,
+            tag TEXT NOT NULL,
+            PRIMARY KEY (post_id, tag),
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE comments (
+            id TEXT PRIMARY KEY,
+            post_id TEXT NOT NULL,
+            parent_id TEXT,
+            author_id TEXT,
+            text TEXT NOT NULL,
+            entities TEXT NOT NULL DEFAULT '{}',
+            status TEXT NOT NULL DEFAULT 'VISIBLE',
+            reply_count INTEGER NOT NULL DEFAULT 0,
+            descendant_count INTEGER NOT NULL DEFAULT 0,
+            pinned_at INTEGER,
+            locked INTEGER NOT NULL DEFAULT 0,
+            created_at INTEGER NOT NULL,
+            edited_at INTEGER,
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+            FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE revisions (
+            target_id TEXT NOT NULL,
+            number INTEGER NOT NULL,
+            text TEXT NOT NULL,
+            editor_id TEXT,
+            created_at INTEGER NOT NULL,
+            PRIMARY KEY (target_id, number)
+        );
+
+        CREATE TABLE notifications (
+            id TEXT PRIMARY KEY,
+            user_id TEXT NOT NULL,
+            kind TEXT NOT NULL,
+            post_id TEXT NOT NULL,
+            comment_id TEXT,
+            created_at INTEGER NOT NULL,
+            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE reports (
+            id TEXT PRIMARY KEY,
+            comment_id TEXT NOT NULL,
+            reporter_id TEXT NOT NULL,
+            reason TEXT NOT NULL,
+            resolved INTEGER NOT NULL DEFAULT 0,
+            created_at INTEGER NOT NULL,
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE TABLE moderation_decisions (
+            id TEXT PRIMARY KEY,
+            comment_id TEXT NOT NULL,
+            moderator_id TEXT NOT NULL,
+            action TEXT NOT NULL,
+            reason TEXT,
+            created_at INTEGER NOT NULL,
+            FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+        );
+
+        CREATE INDEX idx_comments_post_id ON comments(post_id, created_at);
+        CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+        CREATE INDEX idx_comments_post_author ON comments(post_id, author_id, created_at);
+        CREATE INDEX idx_posts_created_at ON posts(created_at);
+        CREATE INDEX idx_posts_slug ON posts(slug);
+        CREATE INDEX idx_posts_publish_at ON posts(publish_at) WHERE status = 'SCHEDULED';
+        CREATE INDEX idx_posts_drafts ON posts(author_id, created_at) WHERE status <> 'PUBLISHED';
+        CREATE INDEX idx_post_tags_tag ON post_tags(tag);
+        CREATE INDEX idx_reports_open ON reports(comment_id) WHERE NOT resolved;
+        CREATE INDEX idx_moderation_decisions_comment_id ON moderation_decisions(comment_id);
+        CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at);
+`
+
+// Ключи идемпотентности мутаций, ответ - JSON-текстом
+const sqliteIdempotency = `
+        CREATE TABLE idempotency_keys (
+            scope TEXT NOT NULL,
+            key TEXT NOT NULL,
+            operation TEXT NOT NULL,
+            result TEXT,
+            created_at INTEGER NOT NULL,
+            PRIMARY KEY (scope, key)
+        );
+
+        CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+`
+
+// Версии постов и комментариев для оптимистичной блокировки
+const sqliteVersions = `
+        ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+        ALTER TABLE comments ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+`
+
+// Пространства (сообщества), как в Postgres. Первичный ключ в SQLite не меняется, поэтому таблица
+// ключей идемпотентности создается заново: ключи живут недолго, и потеря их при обновлении не страшна
+const sqliteSpaces = `
+        ALTER TABLE posts ADD COLUMN space_id TEXT NOT NULL DEFAULT 'default';
+        ALTER TABLE comments ADD COLUMN space_id TEXT NOT NULL DEFAULT 'default';
+        ALTER TABLE notifications ADD COLUMN space_id TEXT NOT NULL DEFAULT 'default';
+
+        DROP TABLE idempotency_keys;
+        CREATE TABLE idempotency_keys (
+            space_id TEXT NOT NULL,
+            scope TEXT NOT NULL,
+            key TEXT NOT NULL,
+            operation TEXT NOT NULL,
+            result TEXT,
+            created_at INTEGER NOT NULL,
+            PRIMARY KEY (space_id, scope, key)
+        );
+        CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+        DROP INDEX idx_posts_created_at;
+        DROP INDEX idx_posts_slug;
+        DROP INDEX idx_posts_drafts;
+        DROP INDEX idx_notifications_user_id;
+        CREATE INDEX idx_posts_space_created_at ON posts(space_id, created_at);
+        CREATE INDEX idx_posts_space_slug ON posts(space_id, slug);
+        CREATE INDEX idx_posts_space_drafts ON posts(space_id, author_id, created_at) WHERE status <> 'PUBLISHED';
+        CREATE INDEX idx_comments_space_id ON comments(space_id, id);
+        CREATE INDEX idx_notifications_space_user ON notifications(space_id, user_id, created_at);
+`
@@ -0,0 +1,39 @@
+package storage
+
+import "PostAndComment/graph/model"
+
+// Кол-во открытых жалоб, после которого комментарий скрывается до решения модератора
+const ReportsToHold = 3
+
+// Текст, который видят читатели вместо скрытого модерацией комментария
+var moderationPlaceholders = map[model.ModerationStatus]string{
+	model.ModerationStatusHeld:     "[comment is awaiting moderation]",
+	model.ModerationStatusHidden:   "[comment hidden by moderator]",
+	model.ModerationStatusRejected: "[comment removed by moderator]",
+}
+
+// Заменяет текст скрытого комментария заглушкой (упоминания и хештеги скрытого текста тоже убираются)
+func ApplyModeration(comment *model.Comment) {
+	if placeholder, ok := moderationPlaceholders[comment.Status]; ok {
+		comment.Text = placeholder
+		comment.Mentions = []*model.Mention{}
+		comment.Hashtags = []*model.Hashtag{}
+	}
+}
+
+// Учитывается ли комментарий в счетчиках комментариев и ответов
+func IsCounted(status model.ModerationStatus) bool {
+	return status == model.ModerationStatusVisible
+}
+
+// Статус комментария после решения модератора
+func StatusForAction(action model.ModerationAction) model.ModerationStatus {
+	switch action {
+	case model.ModerationActionReject:
+		return model.ModerationStatusRejected
+	case model.ModerationActionHide:
+		return model.ModerationStatusHidden
+	default:
+		return model.ModerationStatusVisible
+	}
+}
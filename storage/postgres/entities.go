@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Упоминания и хештеги в колонке entities (JSONB)
+type entitiesJSON struct {
+	storage.Entities
+}
+
+func (e *entitiesJSON) Scan(src any) error {
+	e.Entities = storage.Entities{}
+
+	// NULL бывает у LEFT JOIN без комментария
+	switch data := src.(type) {
+	case nil:
+	case []byte:
+		if err := json.Unmarshal(data, &e.Entities); err != nil {
+			return fmt.Errorf("failed to decode entities: %w", err)
+		}
+	case string:
+		if err := json.Unmarshal([]byte(data), &e.Entities); err != nil {
+			return fmt.Errorf("failed to decode entities: %w", err)
+		}
+	default:
+		return fmt.Errorf("unexpected entities type %T", src)
+	}
+
+	if e.Mentions == nil {
+		e.Mentions = []*model.Mention{}
+	}
+	if e.Hashtags == nil {
+		e.Hashtags = []*model.Hashtag{}
+	}
+	return nil
+}
+
+// Строкой, а не []byte: так значение одинаково кодируется и в запросах, и в COPY
+func (e entitiesJSON) Value() (driver.Value, error) {
+	data, err := json.Marshal(e.Entities)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
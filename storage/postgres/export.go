@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Посты пространства с комментариями для выгрузки в порядке создания
+func (s *PostgresStorage) ExportPosts(limit, offset int32) ([]*storage.PostExport, error) {
+	rows, err := s.db.Query(s.ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.space_id = $3
+		ORDER BY p.created_at, p.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*storage.PostExport{}
+	byID := make(map[string]*storage.PostExport)
+	var ids []string
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		export := &storage.PostExport{Post: post, Comments: []*model.Comment{}}
+		result = append(result, export)
+		byID[post.ID] = export
+		ids = append(ids, post.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	// Исходные правила и комментарии страницы уходят одним пайплайном.
+	// Поля поста содержат вычисленное правило (срок от публикации), для загрузки нужно исходное
+	b := &pgx.Batch{}
+	b.Queue(`
+		SELECT id, comments_close_days, max_comments FROM posts WHERE id = ANY($1)
+	`, ids)
+	b.Queue(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.post_id = ANY($1)
+		ORDER BY c.created_at, c.id
+	`, ids)
+	br := s.db.SendBatch(s.ctx, b)
+	defer br.Close()
+
+	policies, err := br.Query()
+	if err != nil {
+		return nil, err
+	}
+	for policies.Next() {
+		var id string
+		var policy storage.CommentsPolicy
+		if err := policies.Scan(&id, &policy.CloseAfterDays, &policy.MaxComments); err != nil {
+			policies.Close()
+			return nil, err
+		}
+		byID[id].Policy = policy
+	}
+	policies.Close()
+	if err := policies.Err(); err != nil {
+		return nil, err
+	}
+
+	comments, err := br.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer comments.Close()
+	for comments.Next() {
+		c, err := scanComment(comments)
+		if err != nil {
+			return nil, err
+		}
+		byID[c.PostID].Comments = append(byID[c.PostID].Comments, c)
+	}
+	return result, comments.Err()
+}
+
+// Загрузка поста с комментариями в одной транзакции. Счетчики пересчитываются,
+// упомянутые не получают уведомлений
+func (s *PostgresStorage) ImportPost(export *storage.PostExport) error {
+	s.session.MarkWrite()
+
+	if err := export.Validate(); err != nil {
+		return err
+	}
+
+	post := export.Post
+	slug := post.Slug
+	if slug == "" {
+		slug = storage.MakeSlug(post.Title, post.Text, post.ID)
+	}
+	var publishAt *time.Time
+	if post.Status != model.PostStatusPublished && post.PublishAt != nil {
+		at := post.PublishAt.Truncate(time.Microsecond)
+		publishAt = &at
+	}
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	_, err = tx.Exec(s.ctx, `
+		INSERT INTO posts (id, space_id, author_id, title, slug, text, entities, comments_enabled, status, publish_at,
+		                   comments_close_days, max_comments, slow_mode_seconds, created_at, edited_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		post.ID, s.space, post.AuthorID, post.Title, slug, post.Text, entitiesJSON{storage.ExtractEntities(post.Text)},
+		post.CommentsEnabled, post.Status, publishAt, export.Policy.CloseAfterDays, export.Policy.MaxComments,
+		post.SlowModeSeconds, post.CreatedAt.Truncate(time.Microsecond), truncateTime(post.EditedAt),
+		max(post.Version, storage.InitialVersion))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.Errorf(storage.ErrAlreadyExists, "post with ID %s already exists", post.ID)
+		}
+		return fmt.Errorf("failed to insert post: %w", err)
+	}
+
+	if len(post.Tags) > 0 {
+		_, err = tx.Exec(s.ctx, `
+			INSERT INTO post_tags (post_id, tag)
+			SELECT $1, unnest($2::varchar[])`,
+			post.ID, post.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to insert tags: %w", err)
+		}
+	}
+
+	if len(export.Comments) > 0 {
+		if err = copyComments(s.ctx, tx, s.space, post.ID, export.Comments); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Комментарии загружаемого поста одним COPY, счетчики пересчитываются по загруженному дереву
+func copyComments(ctx context.Context, tx pgx.Tx, space, postID string, comments []*model.Comment) error {
+	// COPY не сообщает, какая строка нарушила ключ, поэтому занятые ID ищем заранее
+	ids := make([]string, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	var existing string
+	err := tx.QueryRow(ctx, `SELECT id FROM comments WHERE id = ANY($1) LIMIT 1`, ids).Scan(&existing)
+	if err == nil {
+		return storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already exists", existing)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to check comment IDs: %w", err)
+	}
+
+	rows := make([][]any, 0, len(comments))
+	for _, c := range comments {
+		createdAt := c.CreatedAt.Truncate(time.Microsecond)
+		var pinnedAt *time.Time
+		if c.IsPinned && c.ParentID == nil {
+			pinnedAt = &createdAt
+		}
+		entities, err := entitiesJSON{storage.ExtractEntities(c.Text)}.Value()
+		if err != nil {
+			return err
+		}
+
+		rows = append(rows, []any{c.ID, space, postID, c.ParentID, c.AuthorID, c.Text, entities, string(c.Status),
+			pinnedAt, c.IsLocked, createdAt, truncateTime(c.EditedAt), max(c.Version, storage.InitialVersion)})
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"comments"}, []string{
+		"id", "space_id", "post_id", "parent_id", "author_id", "text", "entities", "status", "pinned_at", "locked",
+		"created_at", "edited_at", "version",
+	}, pgx.CopyFromRows(rows))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.Errorf(storage.ErrAlreadyExists, "comment of post %s already exists", postID)
+		}
+		return fmt.Errorf("failed to copy comments: %w", err)
+	}
+
+	b := &pgx.Batch{}
+	queueRecount(b, postID)
+	return sendBatch(ctx, tx, b, "recount comments")
+}
+
+func truncateTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := t.Truncate(time.Microsecond)
+	return &truncated
+}
+
+// Пересчет счетчиков поста и его комментариев с нуля, как в миграции счетчиков
+func queueRecount(b *pgx.Batch, postID string) {
+	b.Queue(`
+		UPDATE posts p SET
+			comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.status = $2),
+			root_comment_count = (SELECT COUNT(*) FROM comments c
+			                      WHERE c.post_id = p.id AND c.parent_id IS NULL AND c.status = $2)
+		WHERE p.id = $1
+	`, postID, model.ModerationStatusVisible)
+
+	b.Queue(`
+		WITH RECURSIVE tree AS (
+			SELECT id AS ancestor_id, id FROM comments WHERE post_id = $1
+			UNION ALL
+			SELECT t.ancestor_id, c.id FROM comments c JOIN tree t ON c.parent_id = t.id
+		)
+		UPDATE comments p SET
+			reply_count = (SELECT COUNT(*) FROM comments c WHERE c.parent_id = p.id AND c.status = $2),
+			descendant_count = (SELECT COUNT(*) FROM tree t JOIN comments c ON c.id = t.id
+			                    WHERE t.ancestor_id = p.id AND t.id <> p.id AND c.status = $2)
+		WHERE p.post_id = $1
+	`, postID, model.ModerationStatusVisible)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"PostAndComment/storage"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Занять ключ идемпотентности или вернуть ответ прежней мутации с этим ключом. Устаревшая запись
+// занимается заново тем же запросом, поэтому два одновременных вызова не займут ключ оба
+func (s *PostgresStorage) ClaimIdempotencyKey(key storage.IdempotencyKey, operation string, ttl time.Duration) (*storage.IdempotencyRecord, error) {
+	s.session.MarkWrite()
+
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	s.sweepIdempotency(ttl, now)
+
+	var claimed bool
+	err := s.db.QueryRow(s.ctx, `
+		INSERT INTO idempotency_keys (space_id, scope, key, operation, created_at)
+		VALUES ($7, $1, $2, $3, $4)
+		ON CONFLICT (space_id, scope, key) DO UPDATE
+		SET operation = EXCLUDED.operation, result = NULL, created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at <= $5
+		   OR (idempotency_keys.result IS NULL AND idempotency_keys.created_at <= $6)
+		RETURNING true
+	`, key.Scope, key.Key, operation, now, now.Add(-ttl),
+		now.Add(-min(ttl, storage.IdempotencyPendingTimeout)), s.space).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	// Ключ занят действующей записью
+	var rec storage.IdempotencyRecord
+	var result *string
+	err = s.db.QueryRow(s.ctx, `
+		SELECT operation, result, created_at FROM idempotency_keys WHERE space_id = $3 AND scope = $1 AND key = $2
+	`, key.Scope, key.Key, s.space).Scan(&rec.Operation, &result, &rec.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Запись удалили между запросами: повтор мутации займет ключ
+		return nil, storage.Errorf(storage.ErrConflict, "mutation with clientMutationId %s is still in progress", key.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		rec.Result = []byte(*result)
+	}
+	return rec.Replay(key, operation)
+}
+
+// Удаление устаревших ключей всех клиентов во всех пространствах, не чаще раза в IdempotencyPendingTimeout
+func (s *PostgresStorage) sweepIdempotency(ttl time.Duration, now time.Time) {
+	if !s.sweeper.Due(now) {
+		return
+	}
+	_, err := s.db.Exec(s.ctx, `DELETE FROM idempotency_keys WHERE created_at <= $1`, now.Add(-ttl))
+	if err != nil {
+		log.Printf("Failed to delete expired idempotency keys: %v", err)
+	}
+}
+
+// Запомнить ответ мутации с занятым ключом
+func (s *PostgresStorage) CompleteIdempotencyKey(key storage.IdempotencyKey, result []byte) error {
+	s.session.MarkWrite()
+
+	tag, err := s.db.Exec(s.ctx, `
+		UPDATE idempotency_keys SET result = $3 WHERE space_id = $4 AND scope = $1 AND key = $2 AND result IS NULL
+	`, key.Scope, key.Key, string(result), s.space)
+	if err != nil {
+		return fmt.Errorf("failed to save mutation result: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.Errorf(storage.ErrConflict, "clientMutationId %s is not claimed", key.Key)
+	}
+	return nil
+}
+
+// Освободить ключ неудавшейся мутации. Ключ с запомненным ответом не освобождается
+func (s *PostgresStorage) ReleaseIdempotencyKey(key storage.IdempotencyKey) error {
+	s.session.MarkWrite()
+
+	_, err := s.db.Exec(s.ctx, `
+		DELETE FROM idempotency_keys WHERE space_id = $3 AND scope = $1 AND key = $2 AND result IS NULL
+	`, key.Scope, key.Key, s.space)
+	return err
+}
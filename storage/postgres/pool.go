@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Размеры пула по умолчанию; pool_max_conns и другие pool_* в строке подключения их переопределяют
+const (
+	DefaultMaxConns        = 25
+	DefaultMinConns        = 5
+	DefaultMaxConnLifetime = 5 * time.Minute
+)
+
+// Настройки пула из строки подключения (DSN или URL). Каждый запрос подготавливается один раз
+// на соединение и дальше выполняется по кешу подготовленных выражений. За PgBouncer в режиме
+// транзакций кеш нужно отключить параметром default_query_exec_mode=exec
+func ParseConfig(connString string) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+
+	// Значения из строки подключения важнее умолчаний
+	if !hasParam(connString, "default_query_exec_mode") {
+		config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	}
+	if !hasParam(connString, "pool_max_conns") {
+		config.MaxConns = DefaultMaxConns
+	}
+	if !hasParam(connString, "pool_min_conns") {
+		config.MinConns = DefaultMinConns
+	}
+	if !hasParam(connString, "pool_max_conn_lifetime") {
+		config.MaxConnLifetime = DefaultMaxConnLifetime
+	}
+	return config, nil
+}
+
+// Пул соединений по строке подключения. Соединения открываются лениво, доступность базы
+// проверяет Ping
+func Connect(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+	config, err := ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(ctx, config)
+}
+
+// Задан ли параметр в строке подключения: и в DSN, и в URL он записывается как name=value
+func hasParam(connString, name string) bool {
+	return strings.Contains(connString, name+"=")
+}
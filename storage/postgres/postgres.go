@@ -3,84 +3,159 @@ package postgres
 import (
 	"PostAndComment/graph/model"
 	"PostAndComment/storage"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresStorage struct {
-	db *sql.DB
+	db       *pgxpool.Pool
+	replicas *Replicas        // nil - все запросы в основную базу
+	session  *storage.Session // Сессия запроса, к которому привязано хранилище (WithContext)
+	ctx      context.Context  // Контекст запроса: его отмена прерывает запросы к базе
+	space    string           // Пространство запроса: все запросы видят только его строки
+
+	sweeper *storage.IdempotencySweeper // Общая для всех запросов очистка устаревших ключей идемпотентности
 }
 
-func New(db *sql.DB) storage.Storage {
-	return &PostgresStorage{db: db}
+func New(db *pgxpool.Pool) storage.Storage {
+	return &PostgresStorage{db: db, ctx: context.Background(), space: storage.DefaultSpace, sweeper: &storage.IdempotencySweeper{}}
 }
 
-func (s *PostgresStorage) GetCommentsTree(postID string, limit, offset int32) ([]*model.Comment, error) {
-	// Проверка существования поста
-	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1)", postID).Scan(&exists)
+// Хранилище с основной базой db и репликами для чтения. Реплики читают GetPosts, GetPost и деревья
+// комментариев; запрос, который уже что-то записал, читает из основной базы (см. WithContext)
+func NewReplicated(db *pgxpool.Pool, replicas *Replicas) storage.Storage {
+	return &PostgresStorage{
+		db: db, replicas: replicas, ctx: context.Background(), space: storage.DefaultSpace, sweeper: &storage.IdempotencySweeper{},
+	}
+}
+
+// Хранилище для одного запроса: записи отмечаются в сессии запроса, и после первой записи
+// чтение этого запроса идет в основную базу. Запросы идут в пространство запроса (storage.SpaceFrom)
+func (s *PostgresStorage) WithContext(ctx context.Context) storage.Storage {
+	return &PostgresStorage{
+		db: s.db, replicas: s.replicas, session: storage.SessionFrom(ctx), ctx: ctx, space: storage.SpaceFrom(ctx),
+		sweeper: s.sweeper,
+	}
+}
+
+func (s *PostgresStorage) GetCommentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Comment, error) {
+	trees, err := s.GetCommentsTrees([]string{postID}, limit, offset, createdIn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check post existence: %w", err)
+		return nil, err
 	}
-	if !exists {
-		return nil, fmt.Errorf("post with ID %s not found", postID)
+
+	comments, ok := trees[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
 	}
+	return comments, nil
+}
+
+// Комментарии для нескольких постов одним запросом. Несуществующие посты в результат не попадают
+func (s *PostgresStorage) GetCommentsTrees(postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	read := func(db *pgxpool.Pool) (map[string][]*model.Comment, error) {
+		return getCommentsTrees(s.ctx, db, s.space, postIDs, limit, offset, createdIn)
+	}
+	// Поста без дерева может еще не быть на реплике
+	return readReplica(s, read, func(trees map[string][]*model.Comment) bool {
+		for _, postID := range postIDs {
+			if _, ok := trees[postID]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
 
-	rows, err := s.db.Query(`
-        SELECT id, post_id, parent_id, text, created_at
-        FROM comments
-        WHERE post_id = $1
-        ORDER BY created_at
-    `, postID)
+func getCommentsTrees(ctx context.Context, db *pgxpool.Pool, space string, postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	// LEFT JOIN от постов: пост без комментариев тоже попадет в выборку (с NULL вместо комментария)
+	rows, err := db.Query(ctx, `
+        SELECT p.id, c.id, c.parent_id, c.author_id, c.text, c.entities, c.status, c.reply_count, c.descendant_count,
+               c.pinned_at, c.locked, c.created_at, c.edited_at, c.version
+        FROM posts p
+        LEFT JOIN comments c ON c.post_id = p.id
+        WHERE p.id = ANY($1) AND p.space_id = $2
+        ORDER BY c.created_at, c.id
+    `, postIDs, space)
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Группируем комментарии по parent_id
-	allComments := make(map[string]*model.Comment)
+	// Группируем комментарии по постам и parent_id
 	repliesnMap := make(map[string][]*model.Comment)
-	var rootComments []*model.Comment
+	rootComments := make(map[string][]*model.Comment)
+	pinnedComments := make(map[string][]*model.Comment)
+	pinnedAt := make(map[string]time.Time)
 
 	for rows.Next() {
-		var c model.Comment
-		var parent sql.NullString
-		var createdAt time.Time
+		var postID string
+		var id, parent, authorID, text, status sql.NullString
+		var replyCount, descendantCount, version sql.NullInt32
+		var pinned, createdAt, editedAt sql.NullTime
+		var locked sql.NullBool
+		var entities entitiesJSON
 
-		if err := rows.Scan(&c.ID, &c.PostID, &parent, &c.Text, &createdAt); err != nil {
+		if err := rows.Scan(&postID, &id, &parent, &authorID, &text, &entities, &status, &replyCount, &descendantCount,
+			&pinned, &locked, &createdAt, &editedAt, &version); err != nil {
 			return nil, err
 		}
-		c.CreatedAt = createdAt.Format(time.RFC3339)
-		if parent.Valid {
-			c.ParentID = &parent.String
+		if _, ok := rootComments[postID]; !ok {
+			rootComments[postID] = []*model.Comment{}
+		}
+		if !id.Valid {
+			continue
 		}
 
-		allComments[c.ID] = &c
-
+		c := &model.Comment{
+			ID:              id.String,
+			PostID:          postID,
+			AuthorID:        nullStringPtr(authorID),
+			Text:            text.String,
+			Mentions:        entities.Mentions,
+			Hashtags:        entities.Hashtags,
+			Status:          model.ModerationStatus(status.String),
+			ReplyCount:      replyCount.Int32,
+			DescendantCount: descendantCount.Int32,
+			IsPinned:        pinned.Valid,
+			IsLocked:        locked.Bool,
+			CreatedAt:       createdAt.Time,
+			Version:         version.Int32,
+		}
 		if parent.Valid {
-			repliesnMap[parent.String] = append(repliesnMap[parent.String], &c)
-		} else {
-			rootComments = append(rootComments, &c)
+			c.ParentID = &parent.String
 		}
-	}
+		if editedAt.Valid {
+			c.EditedAt = &editedAt.Time
+		}
+		storage.ApplyModeration(c)
 
-	// Выбиаем limit комментариев начиная с offset
-	if int(offset) >= len(rootComments) {
-		return []*model.Comment{}, nil
+		switch {
+		case parent.Valid:
+			repliesnMap[parent.String] = append(repliesnMap[parent.String], c)
+		case !createdIn.Contains(c.CreatedAt):
+			// Фильтр по времени применяется только к корневым комментариям, ответы возвращаются целиком
+		case c.IsPinned:
+			pinnedComments[postID] = append(pinnedComments[postID], c)
+			pinnedAt[c.ID] = pinned.Time
+		default:
+			rootComments[postID] = append(rootComments[postID], c)
+		}
 	}
-
-	end := int(offset + limit)
-	if end > len(rootComments) {
-		end = len(rootComments)
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	paginatedRoots := rootComments[offset:end]
-
 	// Рекурсивно находим ответы к комментриям
 	var attachChildren func(comment *model.Comment)
 	attachChildren = func(comment *model.Comment) {
@@ -92,97 +167,440 @@ func (s *PostgresStorage) GetCommentsTree(postID string, limit, offset int32) ([
 		}
 	}
 
-	for _, root := range paginatedRoots {
-		attachChildren(root)
+	result := make(map[string][]*model.Comment, len(rootComments))
+	for postID, roots := range rootComments {
+		// Закрепленные комментарии идут первыми на любой странице, последний закрепленный - выше
+		pinned := pinnedComments[postID]
+		sort.Slice(pinned, func(i, j int) bool {
+			return pinnedAt[pinned[i].ID].After(pinnedAt[pinned[j].ID])
+		})
+		paginatedRoots := append([]*model.Comment{}, pinned...)
+
+		// Выбиаем limit комментариев начиная с offset
+		if int(offset) < len(roots) {
+			end := int(offset + limit)
+			if end > len(roots) {
+				end = len(roots)
+			}
+			paginatedRoots = append(paginatedRoots, roots[offset:end]...)
+		}
+
+		for _, root := range paginatedRoots {
+			attachChildren(root)
+		}
+		result[postID] = paginatedRoots
+	}
+
+	return result, nil
+}
+
+// Колонки поста в порядке scanPost. Теги собираются подзапросом, чтобы не делать отдельный запрос на пост
+const postColumns = `p.id, p.author_id, p.title, p.slug, p.text, p.entities, p.comments_enabled, p.status, p.publish_at,
+	p.comments_close_days, p.max_comments, p.slow_mode_seconds, p.comment_count, p.root_comment_count, p.created_at,
+	p.edited_at, p.version, ARRAY(SELECT t.tag FROM post_tags t WHERE t.post_id = p.id ORDER BY t.tag)`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row scanner) (*model.Post, error) {
+	var post model.Post
+	var authorID sql.NullString
+	var entities entitiesJSON
+	var publishAt, editedAt sql.NullTime
+	var policy storage.CommentsPolicy
+	err := row.Scan(&post.ID, &authorID, &post.Title, &post.Slug, &post.Text, &entities, &post.CommentsEnabled,
+		&post.Status, &publishAt, &policy.CloseAfterDays, &policy.MaxComments, &post.SlowModeSeconds,
+		&post.CommentCount, &post.RootCommentCount, &post.CreatedAt, &editedAt, &post.Version, &post.Tags)
+	if err != nil {
+		return nil, err
+	}
+	post.AuthorID = nullStringPtr(authorID)
+	if publishAt.Valid {
+		post.PublishAt = &publishAt.Time
+	}
+	if editedAt.Valid {
+		post.EditedAt = &editedAt.Time
+	}
+	applyCommentsPolicy(&post, policy)
+	post.Mentions, post.Hashtags = entities.Mentions, entities.Hashtags
+	if post.Tags == nil {
+		post.Tags = []string{}
 	}
+	return &post, nil
+}
 
-	return paginatedRoots, nil
+// Колонки комментария (без ответов) в порядке scanComment
+const commentColumns = `c.id, c.post_id, c.parent_id, c.author_id, c.text, c.entities, c.status, c.reply_count, c.descendant_count,
+	c.pinned_at IS NOT NULL, c.locked, c.created_at, c.edited_at, c.version`
+
+func scanComment(row scanner) (*model.Comment, error) {
+	var c model.Comment
+	var parent, authorID sql.NullString
+	var entities entitiesJSON
+	var editedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.PostID, &parent, &authorID, &c.Text, &entities, &c.Status, &c.ReplyCount, &c.DescendantCount,
+		&c.IsPinned, &c.IsLocked, &c.CreatedAt, &editedAt, &c.Version)
+	if err != nil {
+		return nil, err
+	}
+	c.ParentID = nullStringPtr(parent)
+	c.AuthorID = nullStringPtr(authorID)
+	c.Mentions, c.Hashtags = entities.Mentions, entities.Hashtags
+	if editedAt.Valid {
+		c.EditedAt = &editedAt.Time
+	}
+	return &c, nil
 }
 
 // Создание поста
-func (s *PostgresStorage) NewPost(text string, commentsEnabled bool) (*model.Post, error) {
+func (s *PostgresStorage) NewPost(params storage.NewPostParams) (*model.Post, error) {
+	s.session.MarkWrite()
+
+	tags, err := storage.NormalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.CommentsPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
 	id := uuid.New().String()
+	slug := storage.MakeSlug(params.Title, params.Text, id)
+	entities := storage.ExtractEntities(params.Text)
 
-	createdTime := time.Now().Format(time.RFC3339)
+	// Postgres хранит время с точностью до микросекунд, отбрасываем остальное заранее,
+	// чтобы возвращаемое значение совпадало с сохраненным
+	createdTime := time.Now().Truncate(time.Microsecond)
+	status := params.Status()
+	var publishAt *time.Time
+	if status == model.PostStatusScheduled {
+		at := params.PublishAt.Truncate(time.Microsecond)
+		publishAt = &at
+	}
 
-	_, err := s.db.Exec(`
-		INSERT INTO posts (id, text, comments_enabled, created_at)
-		VALUES ($1, $2, $3, $4)`,
-		id, text, commentsEnabled, createdTime)
+	tx, err := s.db.Begin(s.ctx)
 	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	// Пост, теги и уведомления уходят в базу одной пачкой
+	b := &pgx.Batch{}
+	b.Queue(`
+		INSERT INTO posts (id, space_id, author_id, title, slug, text, entities, comments_enabled, status, publish_at,
+		                   comments_close_days, max_comments, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		id, s.space, params.AuthorID, params.Title, slug, params.Text, entitiesJSON{entities}, params.CommentsEnabled,
+		status, publishAt, params.CommentsPolicy.CloseAfterDays, params.CommentsPolicy.MaxComments, createdTime)
+
+	if len(tags) > 0 {
+		b.Queue(`
+			INSERT INTO post_tags (post_id, tag)
+			SELECT $1, unnest($2::varchar[])`,
+			id, tags)
+	}
+
+	// Упомянутые в черновике узнают о нем только после публикации
+	if status == model.PostStatusPublished {
+		queueNotifications(b, entities.MentionedUsers(), id, nil, createdTime)
+	}
+
+	if err = sendBatch(s.ctx, tx, b, "insert post"); err != nil {
 		return nil, err
 	}
 
-	return &model.Post{
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	var authorID *string
+	if params.AuthorID != "" {
+		authorID = &params.AuthorID
+	}
+
+	post := &model.Post{
 		ID:              id,
-		Text:            text,
-		CommentsEnabled: commentsEnabled,
+		AuthorID:        authorID,
+		Title:           params.Title,
+		Slug:            slug,
+		Text:            params.Text,
+		Tags:            tags,
+		Mentions:        entities.Mentions,
+		Hashtags:        entities.Hashtags,
+		CommentsEnabled: params.CommentsEnabled,
+		Status:          status,
+		PublishAt:       publishAt,
 		CreatedAt:       createdTime,
-	}, nil
+		Version:         storage.InitialVersion,
+	}
+	applyCommentsPolicy(post, params.CommentsPolicy)
+	return post, nil
+}
+
+// Публикация поста сейчас или по расписанию
+func (s *PostgresStorage) PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	var status model.PostStatus
+	var version int32
+	err = tx.QueryRow(s.ctx, "SELECT status, version FROM posts WHERE id = $1 AND space_id = $2 FOR UPDATE", postID, s.space).
+		Scan(&status, &version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.PostNotFound(postID)
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+	if err = storage.CheckVersion("post", postID, expectedVersion, version); err != nil {
+		return nil, err
+	}
+	if status == model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is already published", postID)
+	}
+
+	now := time.Now()
+	if publishAt != nil && publishAt.After(now) {
+		_, err = tx.Exec(s.ctx, "UPDATE posts SET status = $1, publish_at = $2, version = version + 1 WHERE id = $3",
+			model.PostStatusScheduled, publishAt.Truncate(time.Microsecond), postID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule post: %w", err)
+		}
+	} else if _, err = publishPosts(s.ctx, tx, now, "p.id = $2", postID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return getPost(s.ctx, s.db, s.space, postID)
+}
+
+// Публикация запланированных постов с наступившим publishAt во всех пространствах: это задача
+// планировщика, а не запроса. Параллельные вызовы с нескольких серверов не публикуют пост дважды:
+// UPDATE перепроверяет статус после снятия блокировки строки
+func (s *PostgresStorage) PublishDuePosts(now time.Time) ([]*model.Post, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	posts, err := publishPosts(s.ctx, tx, now, "p.status = 'SCHEDULED' AND p.publish_at <= $2", now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return posts, nil
+}
+
+// Перевод постов, подходящих под условие where (параметр $2 - arg), в опубликованные
+// с временем публикации now в created_at и уведомлениями упомянутым пользователям
+func publishPosts(ctx context.Context, tx pgx.Tx, now time.Time, where string, arg any) ([]*model.Post, error) {
+	publishedAt := now.Truncate(time.Microsecond)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE posts p
+		SET status = 'PUBLISHED', publish_at = NULL, created_at = $1, version = p.version + 1
+		WHERE `+where+`
+		RETURNING `+postColumns+`
+	`, publishedAt, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish posts: %w", err)
+	}
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	b := &pgx.Batch{}
+	for _, post := range posts {
+		queueNotifications(b, storage.Entities{Mentions: post.Mentions}.MentionedUsers(), post.ID, nil, publishedAt)
+	}
+	if err := sendBatch(ctx, tx, b, "insert notifications"); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Неопубликованные посты автора, новые первыми
+func (s *PostgresStorage) GetDrafts(authorID string, limit, offset int32) ([]*model.Post, error) {
+	rows, err := s.db.Query(s.ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.space_id = $4 AND p.author_id = $1 AND p.status <> 'PUBLISHED'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`, authorID, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
 }
 
 // Доабвление комментария
-func (s *PostgresStorage) AddComment(postID string, parentID *string, text string) (*model.Comment, error) {
-	tx, err := s.db.Begin()
+func (s *PostgresStorage) AddComment(params storage.NewCommentParams) (*model.Comment, error) {
+	s.session.MarkWrite()
+
+	postID, parentID, text := params.PostID, params.ParentID, params.Text
+
+	tx, err := s.db.Begin(s.ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer tx.Rollback()
+	defer tx.Rollback(s.ctx)
 
 	// Транзакция проверки существования поста и комментрия
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND comments_enabled)", postID).Scan(&exists)
-	if err != nil {
+	// Блокируем пост, чтобы параллельные комментарии не обошли лимит правила закрытия и медленный режим
+	var commentsEnabled bool
+	var status model.PostStatus
+	var publishedAt time.Time
+	var commentCount, slowModeSeconds int32
+	var policy storage.CommentsPolicy
+	err = tx.QueryRow(s.ctx, `
+		SELECT comments_enabled, status, created_at, comment_count, comments_close_days, max_comments, slow_mode_seconds
+		FROM posts WHERE id = $1 AND space_id = $2 FOR UPDATE
+	`, postID, s.space).Scan(&commentsEnabled, &status, &publishedAt, &commentCount, &policy.CloseAfterDays, &policy.MaxComments,
+		&slowModeSeconds)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("failed to check post existence: %w", err)
 	}
-	if !exists {
-		return nil, fmt.Errorf("post with ID %s not found or comments are disabled", postID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.PostNotFound(postID)
+	}
+	if !commentsEnabled {
+		return nil, storage.ErrCommentsDisabled
+	}
+	if status != model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is not published", postID)
+	}
+	if policy.Closed(publishedAt, commentCount, time.Now()) {
+		return nil, storage.ErrCommentsClosed
 	}
 
 	if parentID != nil {
-		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM comments WHERE id = $1)", *parentID).Scan(&exists)
+		// Родитель в этом же посте и его предки: ветка закрыта, если заблокирован любой из них
+		var found, locked bool
+		err = tx.QueryRow(s.ctx, `
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, locked FROM comments WHERE id = $1 AND space_id = $2 AND post_id = $3
+				UNION ALL
+				SELECT c.id, c.parent_id, c.locked FROM comments c JOIN ancestors a ON c.id = a.parent_id
+			)
+			SELECT COUNT(*) > 0, COALESCE(bool_or(locked), false) FROM ancestors
+		`, *parentID, s.space, postID).Scan(&found, &locked)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check parent comment: %w", err)
 		}
-		if !exists {
-			return nil, fmt.Errorf("parent comment with ID %s not found", *parentID)
+		if !found {
+			return nil, storage.Errorf(storage.ErrNotFound, "parent comment with ID %s not found in post %s", *parentID, postID)
+		}
+		if locked {
+			return nil, storage.ErrThreadLocked
+		}
+	}
+
+	now := time.Now()
+	var lastCommentAt sql.NullTime
+	if slowModeSeconds > 0 && params.AuthorID != "" {
+		err = tx.QueryRow(s.ctx, "SELECT MAX(created_at) FROM comments WHERE post_id = $1 AND author_id = $2",
+			postID, params.AuthorID).Scan(&lastCommentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check slow mode: %w", err)
 		}
 	}
+	if err = storage.CheckSlowMode(slowModeSeconds, params.AuthorID, lastCommentAt.Time, now); err != nil {
+		return nil, err
+	}
 
 	id := uuid.New().String()
-	createdAt := time.Now().Format(time.RFC3339)
-	//Добовляем комментарий
-	_, err = tx.Exec(`
-        INSERT INTO comments (id, post_id, parent_id, text, created_at)
-        VALUES ($1, $2, $3, $4, $5)
-    `, id, postID, parentID, text, createdAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to insert comment: %w", err)
+	createdAt := now.Truncate(time.Microsecond)
+	entities := storage.ExtractEntities(text)
+	//Добовляем комментарий, счетчики и уведомления одной пачкой
+	b := &pgx.Batch{}
+	b.Queue(`
+        INSERT INTO comments (id, space_id, post_id, parent_id, author_id, text, entities, created_at)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+    `, id, s.space, postID, parentID, params.AuthorID, text, entitiesJSON{entities}, createdAt)
+	queueCounters(b, postID, parentID, 1)
+	queueNotifications(b, entities.MentionedUsers(), postID, &id, createdAt)
+
+	if err = sendBatch(s.ctx, tx, b, "insert comment"); err != nil {
+		return nil, err
 	}
 
-	if err = tx.Commit(); err != nil {
+	if err = tx.Commit(s.ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	var authorID *string
+	if params.AuthorID != "" {
+		authorID = &params.AuthorID
+	}
+
 	newComment := &model.Comment{
 		ID:        id,
 		PostID:    postID,
 		ParentID:  parentID,
+		AuthorID:  authorID,
 		Text:      text,
+		Mentions:  entities.Mentions,
+		Hashtags:  entities.Hashtags,
 		CreatedAt: createdAt,
+		Status:    model.ModerationStatusVisible,
+		Version:   storage.InitialVersion,
 	}
 	return newComment, nil
 }
 
 // Список из limit постов начиная с offset
-func (s *PostgresStorage) GetPosts(limit, offset int32) ([]*model.Post, error) {
+func (s *PostgresStorage) GetPosts(limit, offset int32, createdIn storage.TimeRange) ([]*model.Post, error) {
+	return readReplica(s, func(db *pgxpool.Pool) ([]*model.Post, error) {
+		return getPosts(s.ctx, db, s.space, limit, offset, createdIn)
+	}, nil)
+}
 
-	rows, err := s.db.Query(`
-		SELECT id, text, comments_enabled, created_at
-		FROM posts
-		ORDER BY created_at DESC
+func getPosts(ctx context.Context, db *pgxpool.Pool, space string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Post, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.space_id = $5 AND p.status = 'PUBLISHED'
+		  AND ($3::timestamptz IS NULL OR p.created_at > $3)
+		  AND ($4::timestamptz IS NULL OR p.created_at < $4)
+		ORDER BY p.created_at DESC, p.id DESC
 		LIMIT $1 OFFSET $2
-	`, limit, offset)
+	`, limit, offset, createdIn.After, createdIn.Before, space)
 
 	if err != nil {
 		return nil, err
@@ -190,131 +608,984 @@ func (s *PostgresStorage) GetPosts(limit, offset int32) ([]*model.Post, error) {
 
 	defer rows.Close()
 
-	var posts []*model.Post
+	posts := make([]*model.Post, 0)
 	for rows.Next() {
-		var post model.Post
-		var createdAt time.Time
-		if err := rows.Scan(&post.ID, &post.Text, &post.CommentsEnabled, &createdAt); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		post.CreatedAt = createdAt.Format(time.RFC3339)
-		posts = append(posts, &post)
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 	return posts, nil
 }
 
 // Запрос поста по ID
 func (s *PostgresStorage) GetPost(postID string) (*model.Post, error) {
-	var post model.Post
-	var createdAt time.Time
-	err := s.db.QueryRow(`
-		SELECT id, text, comments_enabled, created_at
-		FROM posts
-		WHERE id = $1
-	`, postID).Scan(&post.ID, &post.Text, &post.CommentsEnabled, &createdAt)
+	return readReplica(s, func(db *pgxpool.Pool) (*model.Post, error) {
+		return getPost(s.ctx, db, s.space, postID)
+	}, nil)
+}
+
+func getPost(ctx context.Context, db *pgxpool.Pool, space, postID string) (*model.Post, error) {
+	post, err := scanPost(db.QueryRow(ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.id = $1 AND p.space_id = $2
+	`, postID, space))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("post with ID %s not found", postID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.PostNotFound(postID)
 		}
 		return nil, err
 	}
-	post.CreatedAt = createdAt.Format(time.RFC3339)
-	return &post, nil
+	return post, nil
 }
 
-func (s *PostgresStorage) SetCommentsEnabled(postID string, enabled bool) (*model.Post, error) {
-	result, err := s.db.Exec("UPDATE posts SET comments_enabled = $1 WHERE id = $2", enabled, postID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update post: %w", err)
+// Посты с тегом после курсора, новые первыми
+func (s *PostgresStorage) GetPostsByTag(tag string, limit int32, after *storage.PostCursor) ([]*model.Post, error) {
+	var afterTime *time.Time
+	var afterID string
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, after.ID
 	}
 
-	rowsAf, err := result.RowsAffected() //Проверяем изменения
+	rows, err := s.db.Query(s.ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		JOIN post_tags pt ON pt.post_id = p.id AND pt.tag = $1
+		WHERE p.space_id = $5 AND p.status = 'PUBLISHED'
+		  AND ($3::timestamptz IS NULL OR (p.created_at, p.id) < ($3, $4::varchar))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`, storage.NormalizeTag(tag), limit, afterTime, afterID, s.space)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
 	}
+	return posts, rows.Err()
+}
+
+// Экранирование спецсимволов LIKE в префиксе
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
-	if rowsAf == 0 {
-		return nil, fmt.Errorf("post with ID %s not found", postID)
+// Теги, начинающиеся с prefix: сначала самые популярные
+func (s *PostgresStorage) GetTags(prefix string, limit int32) ([]*model.Tag, error) {
+	rows, err := s.db.Query(s.ctx, `
+		SELECT t.tag, COUNT(*)
+		FROM post_tags t
+		JOIN posts p ON p.id = t.post_id AND p.status = 'PUBLISHED' AND p.space_id = $3
+		WHERE t.tag LIKE $1
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag
+		LIMIT $2
+	`, likeEscaper.Replace(storage.NormalizeTag(prefix))+"%", limit, s.space)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return s.GetPost(postID)
+	tags := []*model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
 }
 
-// Подписка на комментарии к посту
-func (s *PostgresStorage) SubscribeToComments(postID string) (<-chan *model.Comment, *func(), error) {
-	// Проверяем существование поста
-	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1)", postID).Scan(&exists)
+func (s *PostgresStorage) SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) {
+	s.session.MarkWrite()
+
+	result, err := s.db.Exec(s.ctx, `
+		UPDATE posts SET comments_enabled = $1, version = version + 1
+		WHERE id = $2 AND space_id = $4 AND ($3::int IS NULL OR version = $3)`, enabled, postID, expectedVersion, s.space)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
-	if !exists {
-		return nil, nil, fmt.Errorf("post with ID %s not found", postID)
+
+	if result.RowsAffected() == 0 { //Проверяем изменения
+		return nil, s.versionMismatch("posts", postID, expectedVersion)
 	}
 
-	ch := make(chan *model.Comment, 5) // Канал с оповещениями
-	done := make(chan struct{})        // Канал для закрытия горутины
+	return getPost(s.ctx, s.db, s.space, postID)
+}
 
-	lastCheck := time.Now()
-	// Проверяем каждые 4 секунды новые комментарии к посту
-	go func() {
-		ticker := time.NewTicker(4 * time.Second)
-		defer ticker.Stop()
-		defer close(ch)
+// Правило автоматического закрытия комментариев к посту
+func (s *PostgresStorage) SetCommentsPolicy(postID string, policy storage.CommentsPolicy, expectedVersion *int32) (*model.Post, error) {
+	s.session.MarkWrite()
 
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				rows, err := s.db.Query(`
-                    SELECT id, post_id, parent_id, text, created_at
-                    FROM comments
-                    WHERE post_id = $1 AND created_at > $2
-                    ORDER BY created_at
-                `, postID, lastCheck.Format(time.RFC3339))
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
 
-				if err != nil {
-					continue
-				}
+	result, err := s.db.Exec(s.ctx, `
+		UPDATE posts SET comments_close_days = $1, max_comments = $2, version = version + 1
+		WHERE id = $3 AND space_id = $5 AND ($4::int IS NULL OR version = $4)`,
+		policy.CloseAfterDays, policy.MaxComments, postID, expectedVersion, s.space)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
 
-				func() { // Обернул в функцию, чтобы гарантированно закрылся rows
-					defer rows.Close()
-					for rows.Next() {
-						var c model.Comment
-						var parent sql.NullString
-						var createdAt time.Time
-
-						if err := rows.Scan(&c.ID, // Копируем найденный комментарий
-							&c.PostID,
-							&parent,
-							&c.Text,
-							&createdAt); err != nil {
-							continue
-						}
+	if result.RowsAffected() == 0 {
+		return nil, s.versionMismatch("posts", postID, expectedVersion)
+	}
 
-						if parent.Valid { // Обработка ответов
-							c.ParentID = &parent.String
-						} else {
-							c.ParentID = nil
-						}
-						c.CreatedAt = createdAt.Format(time.RFC3339)
+	return getPost(s.ctx, s.db, s.space, postID)
+}
 
-						select {
-						case ch <- &c:
-							lastCheck = createdAt // Обновляем время проверки
-						case <-done:
-							return
-						}
-					}
-				}()
-			}
+// Блокировка и разблокировка ответов под комментарием
+func (s *PostgresStorage) SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) {
+	s.session.MarkWrite()
+
+	c, err := scanComment(s.db.QueryRow(s.ctx, `
+		UPDATE comments c SET locked = $1, version = c.version + 1
+		WHERE c.id = $2 AND c.space_id = $4 AND ($3::int IS NULL OR c.version = $3)
+		RETURNING `+commentColumns, locked, commentID, expectedVersion, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, s.versionMismatch("comments", commentID, expectedVersion)
 		}
-	}()
+		return nil, fmt.Errorf("failed to lock thread: %w", err)
+	}
 
-	unsubscribe := func() { // Возвращаем функцию отписки
-		close(done)
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Медленный режим комментариев к посту
+func (s *PostgresStorage) SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) {
+	s.session.MarkWrite()
+
+	result, err := s.db.Exec(s.ctx, `
+		UPDATE posts SET slow_mode_seconds = $1, version = version + 1
+		WHERE id = $2 AND space_id = $4 AND ($3::int IS NULL OR version = $3)`, seconds, postID, expectedVersion, s.space)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
 
-	return ch, &unsubscribe, nil
+	if result.RowsAffected() == 0 {
+		return nil, s.versionMismatch("posts", postID, expectedVersion)
+	}
+
+	return getPost(s.ctx, s.db, s.space, postID)
+}
+
+// Комментарий по ID (без ответов)
+func (s *PostgresStorage) GetComment(commentID string) (*model.Comment, error) {
+	c, err := scanComment(s.db.QueryRow(s.ctx, `
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, err
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Правка текста поста
+func (s *PostgresStorage) EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	// Блокируем пост, чтобы параллельные правки получили разные номера версий
+	post, err := scanPost(tx.QueryRow(s.ctx, `
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.id = $1 AND p.space_id = $2
+		FOR UPDATE
+	`, postID, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.PostNotFound(postID)
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+	if err = storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now().Truncate(time.Microsecond)
+	if err = saveRevision(s.ctx, tx, postID, post.Text, post.AuthorID, post.CreatedAt, text, editorID, editedAt); err != nil {
+		return nil, err
+	}
+
+	entities := storage.ExtractEntities(text)
+	post, err = scanPost(tx.QueryRow(s.ctx, `
+		UPDATE posts p SET text = $1, entities = $2, edited_at = $3, version = p.version + 1
+		WHERE p.id = $4
+		RETURNING `+postColumns, text, entitiesJSON{entities}, editedAt, postID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return post, nil
+}
+
+// Правка текста комментария
+func (s *PostgresStorage) EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	c, err := scanComment(tx.QueryRow(s.ctx, `
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+		FOR UPDATE
+	`, commentID, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now().Truncate(time.Microsecond)
+	if err = saveRevision(s.ctx, tx, commentID, c.Text, c.AuthorID, c.CreatedAt, text, editorID, editedAt); err != nil {
+		return nil, err
+	}
+
+	entities := storage.ExtractEntities(text)
+	c, err = scanComment(tx.QueryRow(s.ctx, `
+		UPDATE comments c SET text = $1, entities = $2, edited_at = $3, version = c.version + 1
+		WHERE c.id = $4
+		RETURNING `+commentColumns, text, entitiesJSON{entities}, editedAt, commentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Сохранение версий при правке. Строка поста или комментария должна быть заблокирована вызывающим
+func saveRevision(ctx context.Context, tx pgx.Tx, targetID, original string, authorID *string, createdAt time.Time,
+	text, editorID string, editedAt time.Time) error {
+	var count int
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM revisions WHERE target_id = $1", targetID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count revisions: %w", err)
+	}
+
+	// При первой правке сохраняем исходный текст первой версией
+	if count == 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO revisions (target_id, number, text, editor_id, created_at)
+			VALUES ($1, 1, $2, $3, $4)
+		`, targetID, original, authorID, createdAt)
+		if err != nil {
+			return fmt.Errorf("failed to save revision: %w", err)
+		}
+		count = 1
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO revisions (target_id, number, text, editor_id, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, targetID, count+1, text, editorID, editedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+	return nil
+}
+
+// Версии текста поста или комментария
+func (s *PostgresStorage) GetRevisions(targetID string) ([]*model.Revision, error) {
+	// Проверка существования и выборка ревизий уходят одним пайплайном
+	b := &pgx.Batch{}
+	b.Queue(`
+		SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND space_id = $2)
+		    OR EXISTS(SELECT 1 FROM comments WHERE id = $1 AND space_id = $2)
+	`, targetID, s.space)
+	b.Queue(`
+		SELECT number, text, editor_id, created_at
+		FROM revisions
+		WHERE target_id = $1
+		ORDER BY number
+	`, targetID)
+	br := s.db.SendBatch(s.ctx, b)
+	defer br.Close()
+
+	var exists bool
+	if err := br.QueryRow().Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.Errorf(storage.ErrNotFound, "post or comment with ID %s not found", targetID)
+	}
+
+	rows, err := br.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.Revision{}
+	for rows.Next() {
+		var r model.Revision
+		var editorID sql.NullString
+		if err := rows.Scan(&r.Number, &r.Text, &editorID, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.EditorID = nullStringPtr(editorID)
+		revisions = append(revisions, &r)
+	}
+	return revisions, rows.Err()
+}
+
+// Закрепление и открепление корневого комментария
+func (s *PostgresStorage) SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	c, err := scanComment(tx.QueryRow(s.ctx, `
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if c.ParentID != nil {
+		return nil, storage.Errorf(storage.ErrInvalidArgument, "only root comments can be pinned")
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	if pinned && !c.IsPinned {
+		// Блокируем пост, чтобы параллельные закрепления не превысили лимит
+		if _, err = tx.Exec(s.ctx, "SELECT 1 FROM posts WHERE id = $1 FOR UPDATE", c.PostID); err != nil {
+			return nil, fmt.Errorf("failed to lock post: %w", err)
+		}
+
+		var count int
+		err = tx.QueryRow(s.ctx, "SELECT COUNT(*) FROM comments WHERE post_id = $1 AND pinned_at IS NOT NULL", c.PostID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pinned comments: %w", err)
+		}
+		if count >= storage.MaxPinnedComments {
+			return nil, storage.Errorf(storage.ErrConflict, "too many pinned comments: maximum allowed is %d", storage.MaxPinnedComments)
+		}
+	}
+
+	// Комментарий читали без блокировки, поэтому версия проверяется еще раз в самом UPDATE
+	c, err = scanComment(tx.QueryRow(s.ctx, `
+		UPDATE comments c
+		SET pinned_at = CASE WHEN $2 THEN COALESCE(c.pinned_at, NOW()) END, version = c.version + 1
+		WHERE c.id = $1 AND ($3::int IS NULL OR c.version = $3)
+		RETURNING `+commentColumns, commentID, pinned, expectedVersion))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, s.versionMismatch("comments", commentID, expectedVersion)
+		}
+		return nil, fmt.Errorf("failed to pin comment: %w", err)
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Подписка на комментарии к посту
+func (s *PostgresStorage) SubscribeToComments(postID string) (<-chan *model.Comment, *func(), error) {
+	// Проверяем существование поста
+	var exists bool
+	err := s.db.QueryRow(s.ctx, "SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND space_id = $2)", postID, s.space).Scan(&exists)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, storage.PostNotFound(postID)
+	}
+
+	ch := make(chan *model.Comment, 5) // Канал с оповещениями
+	done := make(chan struct{})        // Канал для закрытия горутины
+
+	lastCheck := time.Now()
+	// Проверяем каждые 4 секунды новые комментарии к посту
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+		defer close(ch)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rows, err := s.db.Query(s.ctx, `
+                    SELECT `+commentColumns+`
+                    FROM comments c
+                    WHERE c.post_id = $1 AND c.created_at > $2
+                    ORDER BY c.created_at, c.id
+                `, postID, lastCheck)
+
+				if err != nil {
+					continue
+				}
+
+				func() { // Обернул в функцию, чтобы гарантированно закрылся rows
+					defer rows.Close()
+					for rows.Next() {
+						c, err := scanComment(rows) // Копируем найденный комментарий
+						if err != nil {
+							continue
+						}
+						storage.ApplyModeration(c)
+
+						select {
+						case ch <- c:
+							lastCheck = c.CreatedAt // Обновляем время проверки
+						case <-done:
+							return
+						}
+					}
+				}()
+			}
+		}
+	}()
+
+	unsubscribe := func() { // Возвращаем функцию отписки
+		close(done)
+	}
+
+	return ch, &unsubscribe, nil
+}
+
+// Подписка на публикацию постов: опубликованные посты опрашиваются раз в 4 секунды по времени публикации
+func (s *PostgresStorage) SubscribeToPosts() (<-chan *model.Post, *func(), error) {
+	ch := make(chan *model.Post, 5)
+	done := make(chan struct{})
+
+	lastCheck := time.Now()
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+		defer close(ch)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rows, err := s.db.Query(s.ctx, `
+                    SELECT `+postColumns+`
+                    FROM posts p
+                    WHERE p.space_id = $2 AND p.status = 'PUBLISHED' AND p.created_at > $1
+                    ORDER BY p.created_at, p.id
+                `, lastCheck, s.space)
+				if err != nil {
+					continue
+				}
+
+				func() {
+					defer rows.Close()
+					for rows.Next() {
+						post, err := scanPost(rows)
+						if err != nil {
+							continue
+						}
+
+						select {
+						case ch <- post:
+							lastCheck = post.CreatedAt
+						case <-done:
+							return
+						}
+					}
+				}()
+			}
+		}
+	}()
+
+	unsubscribe := func() {
+		close(done)
+	}
+
+	return ch, &unsubscribe, nil
+}
+
+// Уведомления пользователя, новые первыми
+func (s *PostgresStorage) GetNotifications(userID string, limit, offset int32) ([]*model.Notification, error) {
+	rows, err := s.db.Query(s.ctx, `
+		SELECT id, user_id, kind, post_id, comment_id, created_at
+		FROM notifications
+		WHERE space_id = $4 AND user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// Подписка на уведомления пользователя: как и комментарии, новые записи опрашиваются раз в 4 секунды
+func (s *PostgresStorage) SubscribeToNotifications(userID string) (<-chan *model.Notification, *func(), error) {
+	ch := make(chan *model.Notification, 5)
+	done := make(chan struct{})
+
+	lastCheck := time.Now()
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+		defer close(ch)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rows, err := s.db.Query(s.ctx, `
+                    SELECT id, user_id, kind, post_id, comment_id, created_at
+                    FROM notifications
+                    WHERE space_id = $3 AND user_id = $1 AND created_at > $2
+                    ORDER BY created_at, id
+                `, userID, lastCheck, s.space)
+				if err != nil {
+					continue
+				}
+
+				func() {
+					defer rows.Close()
+					for rows.Next() {
+						n, err := scanNotification(rows)
+						if err != nil {
+							continue
+						}
+
+						select {
+						case ch <- n:
+							lastCheck = n.CreatedAt
+						case <-done:
+							return
+						}
+					}
+				}()
+			}
+		}
+	}()
+
+	unsubscribe := func() {
+		close(done)
+	}
+
+	return ch, &unsubscribe, nil
+}
+
+func scanNotification(row scanner) (*model.Notification, error) {
+	var n model.Notification
+	var commentID sql.NullString
+	if err := row.Scan(&n.ID, &n.UserID, &n.Kind, &n.PostID, &commentID, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	n.CommentID = nullStringPtr(commentID)
+	return &n, nil
+}
+
+// Жалоба на комментарий
+func (s *PostgresStorage) ReportComment(commentID, reporterID, reason string) (*model.Report, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	// Блокируем комментарий, чтобы параллельные жалобы не обошли порог скрытия
+	var status model.ModerationStatus
+	var postID string
+	var parentID sql.NullString
+	err = tx.QueryRow(s.ctx, `
+		SELECT status, post_id, parent_id FROM comments WHERE id = $1 AND space_id = $2 FOR UPDATE
+	`, commentID, s.space).Scan(&status, &postID, &parentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to check comment: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow(s.ctx, `
+		SELECT EXISTS(SELECT 1 FROM reports WHERE comment_id = $1 AND reporter_id = $2 AND NOT resolved)
+	`, commentID, reporterID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check reports: %w", err)
+	}
+	if exists {
+		return nil, storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already reported by this user", commentID)
+	}
+
+	createdAt := time.Now().Truncate(time.Microsecond)
+	report := &model.Report{
+		ID:         uuid.New().String(),
+		TargetID:   commentID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		CreatedAt:  createdAt,
+	}
+	_, err = tx.Exec(s.ctx, `
+		INSERT INTO reports (id, comment_id, reporter_id, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, report.ID, commentID, reporterID, reason, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert report: %w", err)
+	}
+
+	// Набралось много жалоб - скрываем комментарий до решения модератора
+	if status == model.ModerationStatusVisible {
+		var openReports int
+		err = tx.QueryRow(s.ctx, "SELECT COUNT(*) FROM reports WHERE comment_id = $1 AND NOT resolved", commentID).Scan(&openReports)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count reports: %w", err)
+		}
+		if openReports >= storage.ReportsToHold {
+			b := &pgx.Batch{}
+			b.Queue("UPDATE comments SET status = $1, version = version + 1 WHERE id = $2", model.ModerationStatusHeld, commentID)
+			queueCounters(b, postID, nullStringPtr(parentID), -1)
+			if err = sendBatch(s.ctx, tx, b, "hold comment"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
+
+// Очередь модерации: комментарии с открытыми жалобами в порядке первой жалобы
+func (s *PostgresStorage) GetModerationQueue(limit, offset int32) (*model.ModerationQueue, error) {
+	queue := &model.ModerationQueue{Items: []*model.ModerationItem{}}
+
+	// Счётчики и страница очереди уходят одним пайплайном
+	b := &pgx.Batch{}
+	b.Queue(`
+		SELECT COUNT(DISTINCT r.comment_id),
+		       COUNT(DISTINCT r.comment_id) FILTER (WHERE c.status = $1)
+		FROM reports r
+		JOIN comments c ON c.id = r.comment_id
+		WHERE NOT r.resolved AND c.space_id = $2
+	`, model.ModerationStatusHeld, s.space)
+	b.Queue(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		JOIN reports r ON r.comment_id = c.id AND NOT r.resolved
+		WHERE c.space_id = $3
+		GROUP BY c.id
+		ORDER BY MIN(r.created_at), c.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset, s.space)
+
+	itemsByComment := make(map[string]*model.ModerationItem)
+	var commentIDs []string
+	err := func() error {
+		br := s.db.SendBatch(s.ctx, b)
+		defer br.Close()
+
+		if err := br.QueryRow().Scan(&queue.ReportedCount, &queue.HeldCount); err != nil {
+			return fmt.Errorf("failed to count moderation queue: %w", err)
+		}
+		queue.Total = queue.ReportedCount
+
+		rows, err := br.Query()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			c, err := scanComment(rows)
+			if err != nil {
+				return err
+			}
+
+			item := &model.ModerationItem{Comment: c, Reports: []*model.Report{}}
+			queue.Items = append(queue.Items, item)
+			itemsByComment[c.ID] = item
+			commentIDs = append(commentIDs, c.ID)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commentIDs) == 0 {
+		return queue, nil
+	}
+
+	// Открытые жалобы для комментариев страницы одним запросом
+	reportRows, err := s.db.Query(s.ctx, `
+		SELECT id, comment_id, reporter_id, reason, resolved, created_at
+		FROM reports
+		WHERE comment_id = ANY($1) AND NOT resolved
+		ORDER BY created_at
+	`, commentIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer reportRows.Close()
+
+	for reportRows.Next() {
+		var r model.Report
+
+		if err := reportRows.Scan(&r.ID, &r.TargetID, &r.ReporterID, &r.Reason, &r.Resolved, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		item := itemsByComment[r.TargetID]
+		item.Reports = append(item.Reports, &r)
+		item.ReportCount++
+	}
+
+	return queue, reportRows.Err()
+}
+
+// Решение модератора: меняет статус комментария, закрывает жалобы и пишет запись в журнал
+func (s *PostgresStorage) ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) {
+	s.session.MarkWrite()
+
+	tx, err := s.db.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	c, err := scanComment(tx.QueryRow(s.ctx, `
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+		FOR UPDATE
+	`, commentID, s.space))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	// Скрытые комментарии не учитываются в счетчиках
+	wasCounted := storage.IsCounted(c.Status)
+	c.Status = storage.StatusForAction(action)
+	isCounted := storage.IsCounted(c.Status)
+
+	// Статус, счетчики, жалобы и запись в журнал одной пачкой
+	b := &pgx.Batch{}
+	b.Queue("UPDATE comments SET status = $1, version = version + 1 WHERE id = $2", c.Status, commentID)
+	c.Version++
+	if isCounted != wasCounted {
+		delta := 1
+		if !isCounted {
+			delta = -1
+		}
+		queueCounters(b, c.PostID, c.ParentID, delta)
+	}
+	b.Queue("UPDATE reports SET resolved = true WHERE comment_id = $1 AND NOT resolved", commentID)
+	b.Queue(`
+		INSERT INTO moderation_decisions (id, comment_id, moderator_id, action, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uuid.New().String(), commentID, moderatorID, action, reason, time.Now())
+	if err = sendBatch(s.ctx, tx, b, "save moderation decision"); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// История решений модераторов по комментарию
+func (s *PostgresStorage) GetModerationLog(commentID string) ([]*model.ModerationDecision, error) {
+	var exists bool
+	err := s.db.QueryRow(s.ctx, "SELECT EXISTS(SELECT 1 FROM comments WHERE id = $1 AND space_id = $2)", commentID, s.space).
+		Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check comment existence: %w", err)
+	}
+	if !exists {
+		return nil, storage.CommentNotFound(commentID)
+	}
+
+	rows, err := s.db.Query(s.ctx, `
+		SELECT id, comment_id, moderator_id, action, reason, created_at
+		FROM moderation_decisions
+		WHERE comment_id = $1
+		ORDER BY created_at
+	`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []*model.ModerationDecision{}
+	for rows.Next() {
+		var d model.ModerationDecision
+		var reason sql.NullString
+
+		if err := rows.Scan(&d.ID, &d.TargetID, &d.ModeratorID, &d.Action, &reason, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		if reason.Valid {
+			d.Reason = &reason.String
+		}
+		decisions = append(decisions, &d)
+	}
+	return decisions, rows.Err()
+}
+
+// Уведомления упомянутым пользователям в пачке запросов транзакции создания поста или комментария.
+// Уведомление попадает в пространство поста
+func queueNotifications(b *pgx.Batch, users []string, postID string, commentID *string, createdAt time.Time) {
+	for _, userID := range users {
+		b.Queue(`
+			INSERT INTO notifications (id, space_id, user_id, kind, post_id, comment_id, created_at)
+			SELECT $1, p.space_id, $2, $3, p.id, $5, $6 FROM posts p WHERE p.id = $4
+		`, uuid.New().String(), userID, model.NotificationKindMention, postID, commentID, createdAt)
+	}
+}
+
+// Изменение счетчиков поста и предков комментария на delta в пачке запросов
+func queueCounters(b *pgx.Batch, postID string, parentID *string, delta int) {
+	b.Queue(`
+		UPDATE posts
+		SET comment_count = comment_count + $1,
+		    root_comment_count = root_comment_count + CASE WHEN $2 THEN $1 ELSE 0 END
+		WHERE id = $3
+	`, delta, parentID == nil, postID)
+
+	if parentID == nil {
+		return
+	}
+
+	// Родителю меняем и число ответов, и число потомков, остальным предкам - только потомков
+	b.Queue(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id = $2
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		)
+		UPDATE comments
+		SET descendant_count = descendant_count + $1,
+		    reply_count = reply_count + CASE WHEN id = $2 THEN $1 ELSE 0 END
+		WHERE id IN (SELECT id FROM ancestors)
+	`, delta, *parentID)
+}
+
+// Пачка запросов в транзакции за один обмен с базой. action - что делала пачка, для текста ошибки
+func sendBatch(ctx context.Context, tx pgx.Tx, b *pgx.Batch, action string) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := tx.SendBatch(ctx, b).Close(); err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return nil
+}
+
+// Поля правила закрытия комментариев. Срок считается от публикации, поэтому у черновика его нет
+func applyCommentsPolicy(post *model.Post, policy storage.CommentsPolicy) {
+	post.MaxComments = policy.Limit()
+	if post.Status == model.PostStatusPublished {
+		post.CommentsCloseAt = policy.CloseAt(post.CreatedAt)
+	}
+}
+
+// Ошибка условного UPDATE, не изменившего ни одной строки: объекта нет или его версия
+// отличается от ожидаемой. table - posts или comments
+func (s *PostgresStorage) versionMismatch(table, id string, expected *int32) error {
+	kind, notFound := "post", storage.PostNotFound(id)
+	if table == "comments" {
+		kind, notFound = "comment", storage.CommentNotFound(id)
+	}
+
+	var actual int32
+	err := s.db.QueryRow(s.ctx, "SELECT version FROM "+table+" WHERE id = $1 AND space_id = $2", id, s.space).Scan(&actual)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFound
+		}
+		return fmt.Errorf("failed to load %s version: %w", kind, err)
+	}
+	if err := storage.CheckVersion(kind, id, expected, actual); err != nil {
+		return err
+	}
+	return storage.Errorf(storage.ErrConflict, "%s with ID %s was modified concurrently", kind, id)
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
 }
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"PostAndComment/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DefaultHealthInterval = 5 * time.Second // Как часто проверять реплики
+	healthTimeout         = 2 * time.Second
+)
+
+// Реплики для чтения. Реплика, которая не отвечает или отстала больше MaxLag, исключается
+// до следующей успешной проверки; без здоровых реплик чтение идет в основную базу
+type Replicas struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint32 // Для чередования реплик
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+type replica struct {
+	db      *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Реплики dbs с проверкой раз в interval. maxLag 0 - отставание не проверяется.
+// До первой проверки реплики считаются здоровыми
+func NewReplicas(dbs []*pgxpool.Pool, interval, maxLag time.Duration) *Replicas {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	r := &Replicas{maxLag: maxLag, stop: make(chan struct{})}
+	for _, db := range dbs {
+		rep := &replica{db: db}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	r.done.Add(1)
+	go r.healthLoop(interval)
+	return r
+}
+
+// Остановка проверок и закрытие соединений с репликами
+func (r *Replicas) Close() {
+	close(r.stop)
+	r.done.Wait()
+
+	for _, rep := range r.replicas {
+		rep.db.Close()
+	}
+}
+
+// Проверка всех реплик сейчас
+func (r *Replicas) Check() {
+	for i, rep := range r.replicas {
+		err := r.check(rep.db)
+		if healthy := err == nil; rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Replica %d is healthy again", i)
+			} else {
+				log.Printf("Replica %d is unhealthy: %v", i, err)
+			}
+		}
+	}
+}
+
+// Число здоровых реплик
+func (r *Replicas) Healthy() int {
+	count := 0
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *Replicas) healthLoop(interval time.Duration) {
+	defer r.done.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Check()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Replicas) check(db *pgxpool.Pool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	if r.maxLag <= 0 {
+		return db.Ping(ctx)
+	}
+
+	// Без новых транзакций на основной базе время последнего повтора не меняется,
+	// поэтому реплика, которая догнала основную базу, отставшей не считается
+	var lag sql.NullFloat64
+	err := db.QueryRow(ctx, `
+        SELECT CASE
+            WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+        END
+    `).Scan(&lag)
+	if err != nil {
+		return err
+	}
+	if lag.Valid && time.Duration(lag.Float64*float64(time.Second)) > r.maxLag {
+		return fmt.Errorf("replication lag %.1fs exceeds %s", lag.Float64, r.maxLag)
+	}
+	return nil
+}
+
+// Следующая здоровая реплика, nil - здоровых нет
+func (r *Replicas) pick() *replica {
+	n := len(r.replicas)
+	start := int(r.next.Add(1))
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// Чтение с реплики, если хранилище с репликами и запрос еще ничего не записал. Недоступная реплика
+// исключается до следующей проверки, а чтение повторяется в основной базе. Там же повторяется
+// чтение, которое не нашло данных (complete вернул false или ErrNotFound): реплика могла отстать.
+// Отмена или истечение срока запроса не делает реплику недоступной и не повторяется в основной базе.
+// complete nil - любой результат полный
+func readReplica[T any](s *PostgresStorage, read func(db *pgxpool.Pool) (T, error), complete func(T) bool) (T, error) {
+	if s.replicas == nil || s.session.Wrote() {
+		return read(s.db)
+	}
+	rep := s.replicas.pick()
+	if rep == nil {
+		return read(s.db)
+	}
+
+	result, err := read(rep.db)
+	switch {
+	case err == nil && (complete == nil || complete(result)):
+		return result, nil
+	case err == nil || errors.Is(err, storage.ErrNotFound):
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// Запрос отменен или истек его срок - реплика тут ни при чем, а повтор в основной базе не успеет
+		return result, err
+	default:
+		if rep.healthy.Swap(false) {
+			log.Printf("Replica read failed, falling back to primary: %v", err)
+		}
+	}
+	return read(s.db)
+}
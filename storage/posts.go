@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"PostAndComment/graph/model"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MaxTags      = 10 // Максимум тегов у поста
+	MaxTagLength = 32 // Максимальная длина тега в символах
+
+	maxSlugLength = 60 // Длина части slug из заголовка, без суффикса ID
+)
+
+// Параметры создания поста
+type NewPostParams struct {
+	AuthorID        string // Пустой для анонимного поста
+	Title           string
+	Text            string
+	Tags            []string
+	CommentsEnabled bool
+	Draft           bool       // Черновик: не публикуется, пока не вызван PublishPost
+	PublishAt       *time.Time // Отложенная публикация; nil - публикация сразу
+	CommentsPolicy  CommentsPolicy
+}
+
+// Начальное состояние создаваемого поста
+func (p NewPostParams) Status() model.PostStatus {
+	switch {
+	case p.Draft:
+		return model.PostStatusDraft
+	case p.PublishAt != nil:
+		return model.PostStatusScheduled
+	default:
+		return model.PostStatusPublished
+	}
+}
+
+// Позиция в ленте постов (новые первыми) для постраничной выдачи по курсору
+type PostCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func CursorFor(post *model.Post) PostCursor {
+	return PostCursor{CreatedAt: post.CreatedAt, ID: post.ID}
+}
+
+// Идет ли пост в ленте после курсора
+func (c PostCursor) Precedes(post *model.Post) bool {
+	if post.CreatedAt.Equal(c.CreatedAt) {
+		return post.ID < c.ID
+	}
+	return post.CreatedAt.Before(c.CreatedAt)
+}
+
+// Приведение тега к каноническому виду: без "#" в начале и в нижнем регистре
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// Проверка и нормализация тегов поста. Повторы удаляются, результат отсортирован
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+
+	for _, raw := range tags {
+		tag := NormalizeTag(raw)
+		if tag == "" {
+			return nil, Errorf(ErrInvalidArgument, "tag must contain at least one character")
+		}
+		if len([]rune(tag)) > MaxTagLength {
+			return nil, Errorf(ErrInvalidArgument, "tag %q too long: maximum allowed is %d characters", tag, MaxTagLength)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+				return nil, Errorf(ErrInvalidArgument, "tag %q is invalid: only letters, digits, '-' and '_' are allowed", tag)
+			}
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+
+	if len(result) > MaxTags {
+		return nil, Errorf(ErrInvalidArgument, "too many tags: maximum allowed is %d", MaxTags)
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// Slug для ссылок: слова заголовка (или текста, если заголовка нет) через "-" и начало ID для уникальности
+func MakeSlug(title, text, id string) string {
+	source := title
+	if strings.TrimSpace(source) == "" {
+		source = text
+	}
+
+	var b strings.Builder
+	length := 0
+	dash := false
+	for _, r := range strings.ToLower(source) {
+		if length >= maxSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && length > 0 {
+				b.WriteRune('-')
+				length++
+			}
+			b.WriteRune(r)
+			length++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	suffix := id
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	if b.Len() == 0 {
+		return "post-" + suffix
+	}
+	return b.String() + "-" + suffix
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"PostAndComment/graph/model"
+	"time"
+)
+
+// Версии после правки. Исходный текст сохраняется только при первой правке, чтобы не хранить
+// лишнюю копию для текстов, которые никогда не меняли
+func AppendRevision(revisions []*model.Revision, original string, authorID *string, createdAt time.Time,
+	text, editorID string, editedAt time.Time) []*model.Revision {
+	if len(revisions) == 0 {
+		revisions = append(revisions, &model.Revision{Number: 1, Text: original, EditorID: authorID, CreatedAt: createdAt})
+	}
+
+	var editor *string
+	if editorID != "" {
+		editor = &editorID
+	}
+	return append(revisions, &model.Revision{
+		Number:    int32(len(revisions) + 1),
+		Text:      text,
+		EditorID:  editor,
+		CreatedAt: editedAt,
+	})
+}
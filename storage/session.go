@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Хранилище, которое выбирает соединение по запросу: например, читает с реплики, пока запрос
+// ничего не записал. Привязанное хранилище используется только в рамках своего запроса
+type ContextStorage interface {
+	Storage
+	WithContext(ctx context.Context) Storage
+}
+
+// Хранилище для запроса ctx. Хранилища без привязки к запросу возвращаются как есть
+func ForContext(ctx context.Context, s Storage) Storage {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.WithContext(ctx)
+	}
+	return s
+}
+
+type sessionCtx struct{}
+
+// Состояние одного запроса: после первой записи чтение идет из основной базы, чтобы запрос
+// видел свои изменения (read-your-writes)
+type Session struct {
+	wrote atomic.Bool
+}
+
+func NewSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionCtx{}, &Session{})
+}
+
+// Сессия запроса, nil - запрос без сессии
+func SessionFrom(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionCtx{}).(*Session)
+	return session
+}
+
+func (s *Session) MarkWrite() {
+	if s != nil {
+		s.wrote.Store(true)
+	}
+}
+
+func (s *Session) Wrote() bool {
+	return s != nil && s.wrote.Load()
+}
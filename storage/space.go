@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// Пространство (сообщество) по умолчанию: в нем работают запросы без пространства в контексте,
+// в нем же оказываются данные, созданные до появления пространств
+const DefaultSpace = "default"
+
+// ID пространства: латиница в нижнем регистре, цифры, дефис и подчеркивание, до 64 символов
+var spaceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+func ValidateSpaceID(id string) error {
+	if !spaceIDPattern.MatchString(id) {
+		return Errorf(ErrInvalidArgument, "invalid space ID %q: use lowercase letters, digits, '-' and '_', up to 64 characters", id)
+	}
+	return nil
+}
+
+type spaceCtx struct{}
+
+// Контекст запроса к пространству spaceID. Хранилища, привязанные к такому контексту
+// (ForContext), видят и меняют только данные этого пространства
+func WithSpace(ctx context.Context, spaceID string) context.Context {
+	return context.WithValue(ctx, spaceCtx{}, spaceID)
+}
+
+// Пространство запроса, DefaultSpace - если не задано
+func SpaceFrom(ctx context.Context) string {
+	if id, ok := ctx.Value(spaceCtx{}).(string); ok && id != "" {
+		return id
+	}
+	return DefaultSpace
+}
+
+// Ключ, уникальный в пределах всех пространств: для общих кешей и журналов
+func SpaceKey(spaceID, key string) string {
+	return fmt.Sprintf("%s/%s", spaceID, key)
+}
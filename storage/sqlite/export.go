@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"errors"
+	"fmt"
+	"time"
+
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Посты пространства с комментариями для выгрузки в порядке создания
+func (s *SQLiteStorage) ExportPosts(limit, offset int32) ([]*storage.PostExport, error) {
+	rows, err := s.db.Query(`
+		SELECT `+postColumns+`, p.comments_close_days, p.max_comments
+		FROM posts p
+		WHERE p.space_id = $3
+		ORDER BY p.created_at, p.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*storage.PostExport{}
+	byID := make(map[string]*storage.PostExport)
+	var ids []string
+	for rows.Next() {
+		// Поля поста содержат вычисленное правило (срок от публикации), для загрузки нужно исходное
+		var policy storage.CommentsPolicy
+		post, err := scanPost(exportRow{rows, []any{&policy.CloseAfterDays, &policy.MaxComments}})
+		if err != nil {
+			return nil, err
+		}
+		export := &storage.PostExport{Post: post, Policy: policy, Comments: []*model.Comment{}}
+		result = append(result, export)
+		byID[post.ID] = export
+		ids = append(ids, post.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	comments, err := s.db.Query(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.post_id IN (SELECT value FROM json_each($1))
+		ORDER BY c.created_at, c.id
+	`, jsonArray(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer comments.Close()
+	for comments.Next() {
+		c, err := scanComment(comments)
+		if err != nil {
+			return nil, err
+		}
+		byID[c.PostID].Comments = append(byID[c.PostID].Comments, c)
+	}
+	return result, comments.Err()
+}
+
+// Строка с дополнительными колонками после колонок поста
+type exportRow struct {
+	row   scanner
+	extra []any
+}
+
+func (r exportRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.extra...)...)
+}
+
+// Загрузка поста с комментариями в одной транзакции. Счетчики пересчитываются,
+// упомянутые не получают уведомлений
+func (s *SQLiteStorage) ImportPost(export *storage.PostExport) error {
+	if err := export.Validate(); err != nil {
+		return err
+	}
+
+	post := export.Post
+	slug := post.Slug
+	if slug == "" {
+		slug = storage.MakeSlug(post.Title, post.Text, post.ID)
+	}
+	var publishAt *time.Time
+	if post.Status != model.PostStatusPublished {
+		publishAt = post.PublishAt
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO posts (id, space_id, author_id, title, slug, text, entities, comments_enabled, status, publish_at,
+		                   comments_close_days, max_comments, slow_mode_seconds, created_at, edited_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		post.ID, s.space, post.AuthorID, post.Title, slug, post.Text, entitiesJSON{storage.ExtractEntities(post.Text)},
+		post.CommentsEnabled, post.Status, microsPtr(publishAt), export.Policy.CloseAfterDays, export.Policy.MaxComments,
+		post.SlowModeSeconds, micros(post.CreatedAt), microsPtr(post.EditedAt), max(post.Version, storage.InitialVersion))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.Errorf(storage.ErrAlreadyExists, "post with ID %s already exists", post.ID)
+		}
+		return fmt.Errorf("failed to insert post: %w", err)
+	}
+
+	if err = insertTags(tx, post.ID, post.Tags); err != nil {
+		return err
+	}
+
+	for _, c := range export.Comments {
+		var pinnedAt *time.Time
+		if c.IsPinned && c.ParentID == nil {
+			pinnedAt = &c.CreatedAt
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO comments (id, space_id, post_id, parent_id, author_id, text, entities, status, pinned_at, locked,
+			                      created_at, edited_at, version)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			c.ID, s.space, post.ID, c.ParentID, c.AuthorID, c.Text, entitiesJSON{storage.ExtractEntities(c.Text)}, c.Status,
+			microsPtr(pinnedAt), c.IsLocked, micros(c.CreatedAt), microsPtr(c.EditedAt), max(c.Version, storage.InitialVersion))
+		if err != nil {
+			if isUniqueViolation(err) {
+				return storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already exists", c.ID)
+			}
+			return fmt.Errorf("failed to insert comment: %w", err)
+		}
+
+		if storage.IsCounted(c.Status) {
+			if err = updateCounters(tx, post.ID, c.ParentID, 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || code == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"PostAndComment/storage"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Занять ключ идемпотентности или вернуть ответ прежней мутации с этим ключом. Устаревшая запись
+// занимается заново тем же запросом
+func (s *SQLiteStorage) ClaimIdempotencyKey(key storage.IdempotencyKey, operation string, ttl time.Duration) (*storage.IdempotencyRecord, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.sweepIdempotency(ttl, now)
+
+	var claimed bool
+	err := s.db.QueryRow(`
+		INSERT INTO idempotency_keys (space_id, scope, key, operation, created_at)
+		VALUES ($7, $1, $2, $3, $4)
+		ON CONFLICT (space_id, scope, key) DO UPDATE
+		SET operation = excluded.operation, result = NULL, created_at = excluded.created_at
+		WHERE idempotency_keys.created_at <= $5
+		   OR (idempotency_keys.result IS NULL AND idempotency_keys.created_at <= $6)
+		RETURNING 1
+	`, key.Scope, key.Key, operation, micros(now), micros(now.Add(-ttl)),
+		micros(now.Add(-min(ttl, storage.IdempotencyPendingTimeout))), s.space).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	// Ключ занят действующей записью
+	var rec storage.IdempotencyRecord
+	var result sql.NullString
+	var createdAt timestamp
+	err = s.db.QueryRow(`
+		SELECT operation, result, created_at FROM idempotency_keys WHERE space_id = $3 AND scope = $1 AND key = $2
+	`, key.Scope, key.Key, s.space).Scan(&rec.Operation, &result, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.Errorf(storage.ErrConflict, "mutation with clientMutationId %s is still in progress", key.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec.CreatedAt = createdAt.Time
+	if result.Valid {
+		rec.Result = []byte(result.String)
+	}
+	return rec.Replay(key, operation)
+}
+
+// Удаление устаревших ключей всех клиентов во всех пространствах, не чаще раза в IdempotencyPendingTimeout
+func (s *SQLiteStorage) sweepIdempotency(ttl time.Duration, now time.Time) {
+	if !s.sweeper.Due(now) {
+		return
+	}
+	if _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at <= $1`, micros(now.Add(-ttl))); err != nil {
+		log.Printf("Failed to delete expired idempotency keys: %v", err)
+	}
+}
+
+// Запомнить ответ мутации с занятым ключом
+func (s *SQLiteStorage) CompleteIdempotencyKey(key storage.IdempotencyKey, result []byte) error {
+	res, err := s.db.Exec(`
+		UPDATE idempotency_keys SET result = $3 WHERE space_id = $4 AND scope = $1 AND key = $2 AND result IS NULL
+	`, key.Scope, key.Key, string(result), s.space)
+	if err != nil {
+		return fmt.Errorf("failed to save mutation result: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return storage.Errorf(storage.ErrConflict, "clientMutationId %s is not claimed", key.Key)
+	}
+	return nil
+}
+
+// Освободить ключ неудавшейся мутации. Ключ с запомненным ответом не освобождается
+func (s *SQLiteStorage) ReleaseIdempotencyKey(key storage.IdempotencyKey) error {
+	_, err := s.db.Exec(`
+		DELETE FROM idempotency_keys WHERE space_id = $3 AND scope = $1 AND key = $2 AND result IS NULL
+	`, key.Scope, key.Key, s.space)
+	return err
+}
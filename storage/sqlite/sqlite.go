@@ -0,0 +1,1469 @@
+package sqlite
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/storage/broker"
+	"PostAndComment/storage/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// Хранилище во встроенной базе SQLite. Транзакции берут блокировку записи сразу (_txlock=immediate),
+// поэтому изменяющие операции выполняются по одной и не требуют блокировки строк.
+// Подписки работают через брокер в памяти процесса: база рассчитана на один сервер
+type SQLiteStorage struct {
+	db    *sql.DB
+	space string // Пространство, к которому привязано хранилище (WithContext)
+
+	comments      *broker.Broker[string, *model.Comment]      // По ID поста
+	posts         *broker.Broker[string, *model.Post]         // По пространству
+	notifications *broker.Broker[string, *model.Notification] // По пространству и пользователю (storage.SpaceKey)
+
+	sweeper *storage.IdempotencySweeper // Очистка устаревших ключей идемпотентности
+}
+
+func New(db *sql.DB) storage.Storage {
+	return &SQLiteStorage{
+		db:            db,
+		space:         storage.DefaultSpace,
+		comments:      broker.New[string, *model.Comment](),
+		posts:         broker.New[string, *model.Post](),
+		notifications: broker.New[string, *model.Notification](),
+		sweeper:       &storage.IdempotencySweeper{},
+	}
+}
+
+// Хранилище в пространстве запроса (storage.SpaceFrom). База, брокеры и очистка ключей общие
+func (s *SQLiteStorage) WithContext(ctx context.Context) storage.Storage {
+	scoped := *s
+	scoped.space = storage.SpaceFrom(ctx)
+	return &scoped
+}
+
+// Открытие файла базы с журналом WAL и внешними ключами и применение миграций
+func Open(path string) (*sql.DB, error) {
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := migrations.Apply(db, migrations.SQLite); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+func (s *SQLiteStorage) GetCommentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Comment, error) {
+	trees, err := s.GetCommentsTrees([]string{postID}, limit, offset, createdIn)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, ok := trees[postID]
+	if !ok {
+		return nil, storage.PostNotFound(postID)
+	}
+	return comments, nil
+}
+
+// Комментарии для нескольких постов одним запросом. Несуществующие посты в результат не попадают
+func (s *SQLiteStorage) GetCommentsTrees(postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	// LEFT JOIN от постов: пост без комментариев тоже попадет в выборку (с NULL вместо комментария)
+	rows, err := s.db.Query(`
+        SELECT p.id, c.id, c.parent_id, c.author_id, c.text, c.entities, c.status, c.reply_count, c.descendant_count,
+               c.pinned_at, c.locked, c.created_at, c.edited_at, c.version
+        FROM posts p
+        LEFT JOIN comments c ON c.post_id = p.id
+        WHERE p.id IN (SELECT value FROM json_each($1)) AND p.space_id = $2
+        ORDER BY c.created_at, c.id
+    `, jsonArray(postIDs), s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Группируем комментарии по постам и parent_id
+	replies := make(map[string][]*model.Comment)
+	rootComments := make(map[string][]*model.Comment)
+	pinnedComments := make(map[string][]*model.Comment)
+	pinnedAt := make(map[string]time.Time)
+
+	for rows.Next() {
+		var postID string
+		var id, parent, authorID, text, status sql.NullString
+		var replyCount, descendantCount, version sql.NullInt32
+		var pinned, createdAt, editedAt timestamp
+		var locked sql.NullBool
+		var entities entitiesJSON
+
+		if err := rows.Scan(&postID, &id, &parent, &authorID, &text, &entities, &status, &replyCount, &descendantCount,
+			&pinned, &locked, &createdAt, &editedAt, &version); err != nil {
+			return nil, err
+		}
+		if _, ok := rootComments[postID]; !ok {
+			rootComments[postID] = []*model.Comment{}
+		}
+		if !id.Valid {
+			continue
+		}
+
+		c := &model.Comment{
+			ID:              id.String,
+			PostID:          postID,
+			AuthorID:        nullStringPtr(authorID),
+			Text:            text.String,
+			Mentions:        entities.Mentions,
+			Hashtags:        entities.Hashtags,
+			Status:          model.ModerationStatus(status.String),
+			ReplyCount:      replyCount.Int32,
+			DescendantCount: descendantCount.Int32,
+			IsPinned:        pinned.Valid,
+			IsLocked:        locked.Bool,
+			CreatedAt:       createdAt.Time,
+			EditedAt:        editedAt.Ptr(),
+			Version:         version.Int32,
+		}
+		if parent.Valid {
+			c.ParentID = &parent.String
+		}
+		storage.ApplyModeration(c)
+
+		switch {
+		case parent.Valid:
+			replies[parent.String] = append(replies[parent.String], c)
+		case !createdIn.Contains(c.CreatedAt):
+			// Фильтр по времени применяется только к корневым комментариям, ответы возвращаются целиком
+		case c.IsPinned:
+			pinnedComments[postID] = append(pinnedComments[postID], c)
+			pinnedAt[c.ID] = pinned.Time
+		default:
+			rootComments[postID] = append(rootComments[postID], c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var attachChildren func(comment *model.Comment)
+	attachChildren = func(comment *model.Comment) {
+		if children, exists := replies[comment.ID]; exists {
+			comment.Replies = children
+			for _, child := range children {
+				attachChildren(child)
+			}
+		}
+	}
+
+	result := make(map[string][]*model.Comment, len(rootComments))
+	for postID, roots := range rootComments {
+		// Закрепленные комментарии идут первыми на любой странице, последний закрепленный - выше
+		pinned := pinnedComments[postID]
+		sort.Slice(pinned, func(i, j int) bool {
+			return pinnedAt[pinned[i].ID].After(pinnedAt[pinned[j].ID])
+		})
+		paginatedRoots := append([]*model.Comment{}, pinned...)
+
+		if int(offset) < len(roots) {
+			end := int(offset + limit)
+			if end > len(roots) {
+				end = len(roots)
+			}
+			paginatedRoots = append(paginatedRoots, roots[offset:end]...)
+		}
+
+		for _, root := range paginatedRoots {
+			attachChildren(root)
+		}
+		result[postID] = paginatedRoots
+	}
+
+	return result, nil
+}
+
+// Колонки поста в порядке scanPost. Теги собираются подзапросом в JSON-массив
+const postColumns = `p.id, p.author_id, p.title, p.slug, p.text, p.entities, p.comments_enabled, p.status, p.publish_at,
+	p.comments_close_days, p.max_comments, p.slow_mode_seconds, p.comment_count, p.root_comment_count, p.created_at,
+	p.edited_at, p.version, (SELECT json_group_array(tag) FROM (SELECT t.tag FROM post_tags t WHERE t.post_id = p.id ORDER BY t.tag))`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row scanner) (*model.Post, error) {
+	var post model.Post
+	var authorID sql.NullString
+	var entities entitiesJSON
+	var publishAt, createdAt, editedAt timestamp
+	var policy storage.CommentsPolicy
+	var tags stringList
+	err := row.Scan(&post.ID, &authorID, &post.Title, &post.Slug, &post.Text, &entities, &post.CommentsEnabled,
+		&post.Status, &publishAt, &policy.CloseAfterDays, &policy.MaxComments, &post.SlowModeSeconds,
+		&post.CommentCount, &post.RootCommentCount, &createdAt, &editedAt, &post.Version, &tags)
+	if err != nil {
+		return nil, err
+	}
+	post.AuthorID = nullStringPtr(authorID)
+	post.PublishAt = publishAt.Ptr()
+	post.CreatedAt = createdAt.Time
+	post.EditedAt = editedAt.Ptr()
+	post.Tags = tags
+	applyCommentsPolicy(&post, policy)
+	post.Mentions, post.Hashtags = entities.Mentions, entities.Hashtags
+	return &post, nil
+}
+
+// Колонки комментария (без ответов) в порядке scanComment
+const commentColumns = `c.id, c.post_id, c.parent_id, c.author_id, c.text, c.entities, c.status, c.reply_count, c.descendant_count,
+	c.pinned_at IS NOT NULL, c.locked, c.created_at, c.edited_at, c.version`
+
+func scanComment(row scanner) (*model.Comment, error) {
+	var c model.Comment
+	var parent, authorID sql.NullString
+	var entities entitiesJSON
+	var createdAt, editedAt timestamp
+	err := row.Scan(&c.ID, &c.PostID, &parent, &authorID, &c.Text, &entities, &c.Status, &c.ReplyCount, &c.DescendantCount,
+		&c.IsPinned, &c.IsLocked, &createdAt, &editedAt, &c.Version)
+	if err != nil {
+		return nil, err
+	}
+	c.ParentID = nullStringPtr(parent)
+	c.AuthorID = nullStringPtr(authorID)
+	c.Mentions, c.Hashtags = entities.Mentions, entities.Hashtags
+	c.CreatedAt = createdAt.Time
+	c.EditedAt = editedAt.Ptr()
+	return &c, nil
+}
+
+// Создание поста
+func (s *SQLiteStorage) NewPost(params storage.NewPostParams) (*model.Post, error) {
+	tags, err := storage.NormalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.CommentsPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	slug := storage.MakeSlug(params.Title, params.Text, id)
+	entities := storage.ExtractEntities(params.Text)
+
+	// Время хранится в микросекундах, отбрасываем остальное заранее,
+	// чтобы возвращаемое значение совпадало с сохраненным
+	createdTime := time.Now().Truncate(time.Microsecond)
+	status := params.Status()
+	var publishAt *time.Time
+	if status == model.PostStatusScheduled {
+		at := params.PublishAt.Truncate(time.Microsecond)
+		publishAt = &at
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO posts (id, space_id, author_id, title, slug, text, entities, comments_enabled, status, publish_at,
+		                   comments_close_days, max_comments, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		id, s.space, params.AuthorID, params.Title, slug, params.Text, entitiesJSON{entities}, params.CommentsEnabled,
+		status, microsPtr(publishAt), params.CommentsPolicy.CloseAfterDays, params.CommentsPolicy.MaxComments,
+		micros(createdTime))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertTags(tx, id, tags); err != nil {
+		return nil, err
+	}
+
+	// Упомянутые в черновике узнают о нем только после публикации
+	var notifications []*model.Notification
+	if status == model.PostStatusPublished {
+		notifications, err = notifyMentioned(tx, s.space, entities.MentionedUsers(), id, nil, createdTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	var authorID *string
+	if params.AuthorID != "" {
+		authorID = &params.AuthorID
+	}
+
+	post := &model.Post{
+		ID:              id,
+		AuthorID:        authorID,
+		Title:           params.Title,
+		Slug:            slug,
+		Text:            params.Text,
+		Tags:            tags,
+		Mentions:        entities.Mentions,
+		Hashtags:        entities.Hashtags,
+		CommentsEnabled: params.CommentsEnabled,
+		Status:          status,
+		PublishAt:       publishAt,
+		CreatedAt:       createdTime,
+		Version:         storage.InitialVersion,
+	}
+	applyCommentsPolicy(post, params.CommentsPolicy)
+
+	s.publishNotifications(s.space, notifications)
+	if status == model.PostStatusPublished {
+		s.posts.Publish(s.space, post)
+	}
+	return post, nil
+}
+
+func insertTags(tx *sql.Tx, postID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO post_tags (post_id, tag)
+		SELECT $1, value FROM json_each($2)`,
+		postID, jsonArray(tags))
+	if err != nil {
+		return fmt.Errorf("failed to insert tags: %w", err)
+	}
+	return nil
+}
+
+// Публикация поста сейчас или по расписанию
+func (s *SQLiteStorage) PublishPost(postID string, publishAt *time.Time, expectedVersion *int32) (*model.Post, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status model.PostStatus
+	var version int32
+	err = tx.QueryRow("SELECT status, version FROM posts WHERE id = $1 AND space_id = $2", postID, s.space).Scan(&status, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.PostNotFound(postID)
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+	if err = storage.CheckVersion("post", postID, expectedVersion, version); err != nil {
+		return nil, err
+	}
+	if status == model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is already published", postID)
+	}
+
+	var published []*publishedPost
+	now := time.Now()
+	if publishAt != nil && publishAt.After(now) {
+		_, err = tx.Exec("UPDATE posts SET status = $1, publish_at = $2, version = version + 1 WHERE id = $3",
+			model.PostStatusScheduled, micros(*publishAt), postID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule post: %w", err)
+		}
+	} else if published, err = publishPosts(tx, now, "id = $2", postID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.publishPosts(published)
+	return s.GetPost(postID)
+}
+
+// Публикация запланированных постов с наступившим publishAt во всех пространствах
+func (s *SQLiteStorage) PublishDuePosts(now time.Time) ([]*model.Post, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	published, err := publishPosts(tx, now, "status = 'SCHEDULED' AND publish_at <= $2", micros(now))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.publishPosts(published)
+
+	posts := make([]*model.Post, len(published))
+	for i, p := range published {
+		posts[i] = p.post
+	}
+	return posts, nil
+}
+
+// Опубликованный пост с пространством и уведомлениями для рассылки после фиксации транзакции
+type publishedPost struct {
+	space         string
+	post          *model.Post
+	notifications []*model.Notification
+}
+
+// Перевод постов, подходящих под условие where (параметр $2 - arg), в опубликованные
+// с временем публикации now в created_at и уведомлениями упомянутым пользователям
+func publishPosts(tx *sql.Tx, now time.Time, where string, arg any) ([]*publishedPost, error) {
+	publishedAt := now.Truncate(time.Microsecond)
+
+	// RETURNING в SQLite не видит псевдоним таблицы, поэтому посты перечитываем по ID
+	rows, err := tx.Query(`
+		UPDATE posts
+		SET status = 'PUBLISHED', publish_at = NULL, created_at = $1, version = version + 1
+		WHERE `+where+`
+		RETURNING id, space_id
+	`, micros(publishedAt), arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish posts: %w", err)
+	}
+
+	var ids []string
+	spaces := make(map[string]string)
+	for rows.Next() {
+		var id, space string
+		if err := rows.Scan(&id, &space); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		spaces[id] = space
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.id IN (SELECT value FROM json_each($1))
+		ORDER BY p.id
+	`, jsonArray(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load published posts: %w", err)
+	}
+
+	published := []*publishedPost{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		published = append(published, &publishedPost{space: spaces[post.ID], post: post})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range published {
+		mentioned := storage.Entities{Mentions: p.post.Mentions}.MentionedUsers()
+		if p.notifications, err = notifyMentioned(tx, p.space, mentioned, p.post.ID, nil, publishedAt); err != nil {
+			return nil, err
+		}
+	}
+	return published, nil
+}
+
+// Рассылка опубликованных постов и уведомлений о них после фиксации транзакции
+func (s *SQLiteStorage) publishPosts(published []*publishedPost) {
+	for _, p := range published {
+		s.publishNotifications(p.space, p.notifications)
+		s.posts.Publish(p.space, p.post)
+	}
+}
+
+// Неопубликованные посты автора, новые первыми
+func (s *SQLiteStorage) GetDrafts(authorID string, limit, offset int32) ([]*model.Post, error) {
+	rows, err := s.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.space_id = $4 AND p.author_id = $1 AND p.status <> 'PUBLISHED'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`, authorID, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// Добавление комментария
+func (s *SQLiteStorage) AddComment(params storage.NewCommentParams) (*model.Comment, error) {
+	postID, parentID, text := params.PostID, params.ParentID, params.Text
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var commentsEnabled bool
+	var status model.PostStatus
+	var publishedAt timestamp
+	var commentCount, slowModeSeconds int32
+	var policy storage.CommentsPolicy
+	err = tx.QueryRow(`
+		SELECT comments_enabled, status, created_at, comment_count, comments_close_days, max_comments, slow_mode_seconds
+		FROM posts WHERE id = $1 AND space_id = $2
+	`, postID, s.space).Scan(&commentsEnabled, &status, &publishedAt, &commentCount, &policy.CloseAfterDays, &policy.MaxComments,
+		&slowModeSeconds)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check post existence: %w", err)
+	}
+	if err == sql.ErrNoRows {
+		return nil, storage.PostNotFound(postID)
+	}
+	if !commentsEnabled {
+		return nil, storage.ErrCommentsDisabled
+	}
+	if status != model.PostStatusPublished {
+		return nil, storage.Errorf(storage.ErrConflict, "post with ID %s is not published", postID)
+	}
+	if policy.Closed(publishedAt.Time, commentCount, time.Now()) {
+		return nil, storage.ErrCommentsClosed
+	}
+
+	if parentID != nil {
+		// Родитель в этом же посте и его предки: ветка закрыта, если заблокирован любой из них
+		var found, locked bool
+		err = tx.QueryRow(`
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, locked FROM comments WHERE id = $1 AND space_id = $2 AND post_id = $3
+				UNION ALL
+				SELECT c.id, c.parent_id, c.locked FROM comments c JOIN ancestors a ON c.id = a.parent_id
+			)
+			SELECT COUNT(*) > 0, COALESCE(MAX(locked), 0) FROM ancestors
+		`, *parentID, s.space, postID).Scan(&found, &locked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check parent comment: %w", err)
+		}
+		if !found {
+			return nil, storage.Errorf(storage.ErrNotFound, "parent comment with ID %s not found in post %s", *parentID, postID)
+		}
+		if locked {
+			return nil, storage.ErrThreadLocked
+		}
+	}
+
+	now := time.Now()
+	var lastCommentAt timestamp
+	if slowModeSeconds > 0 && params.AuthorID != "" {
+		err = tx.QueryRow("SELECT MAX(created_at) FROM comments WHERE post_id = $1 AND author_id = $2",
+			postID, params.AuthorID).Scan(&lastCommentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check slow mode: %w", err)
+		}
+	}
+	if err = storage.CheckSlowMode(slowModeSeconds, params.AuthorID, lastCommentAt.Time, now); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	createdAt := now.Truncate(time.Microsecond)
+	entities := storage.ExtractEntities(text)
+	_, err = tx.Exec(`
+        INSERT INTO comments (id, space_id, post_id, parent_id, author_id, text, entities, created_at)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+    `, id, s.space, postID, parentID, params.AuthorID, text, entitiesJSON{entities}, micros(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert comment: %w", err)
+	}
+
+	if err = updateCounters(tx, postID, parentID, 1); err != nil {
+		return nil, err
+	}
+
+	notifications, err := notifyMentioned(tx, s.space, entities.MentionedUsers(), postID, &id, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	var authorID *string
+	if params.AuthorID != "" {
+		authorID = &params.AuthorID
+	}
+
+	newComment := &model.Comment{
+		ID:        id,
+		PostID:    postID,
+		ParentID:  parentID,
+		AuthorID:  authorID,
+		Text:      text,
+		Mentions:  entities.Mentions,
+		Hashtags:  entities.Hashtags,
+		CreatedAt: createdAt,
+		Status:    model.ModerationStatusVisible,
+		Version:   storage.InitialVersion,
+	}
+
+	s.publishNotifications(s.space, notifications)
+	s.comments.Publish(postID, newComment)
+	return newComment, nil
+}
+
+// Список из limit постов начиная с offset
+func (s *SQLiteStorage) GetPosts(limit, offset int32, createdIn storage.TimeRange) ([]*model.Post, error) {
+	rows, err := s.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.space_id = $5 AND p.status = 'PUBLISHED'
+		  AND ($3 IS NULL OR p.created_at > $3)
+		  AND ($4 IS NULL OR p.created_at < $4)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset, microsPtr(createdIn.After), microsPtr(createdIn.Before), s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]*model.Post, 0)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// Запрос поста по ID
+func (s *SQLiteStorage) GetPost(postID string) (*model.Post, error) {
+	post, err := scanPost(s.db.QueryRow(`
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.id = $1 AND p.space_id = $2
+	`, postID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.PostNotFound(postID)
+		}
+		return nil, err
+	}
+	return post, nil
+}
+
+// Посты с тегом после курсора, новые первыми
+func (s *SQLiteStorage) GetPostsByTag(tag string, limit int32, after *storage.PostCursor) ([]*model.Post, error) {
+	var afterTime *time.Time
+	var afterID string
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, after.ID
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts p
+		JOIN post_tags pt ON pt.post_id = p.id AND pt.tag = $1
+		WHERE p.space_id = $5 AND p.status = 'PUBLISHED'
+		  AND ($3 IS NULL OR (p.created_at, p.id) < ($3, $4))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`, storage.NormalizeTag(tag), limit, microsPtr(afterTime), afterID, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// Экранирование спецсимволов LIKE в префиксе
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Теги, начинающиеся с prefix: сначала самые популярные
+func (s *SQLiteStorage) GetTags(prefix string, limit int32) ([]*model.Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM post_tags t
+		JOIN posts p ON p.id = t.post_id AND p.status = 'PUBLISHED' AND p.space_id = $3
+		WHERE t.tag LIKE $1 ESCAPE '\'
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag
+		LIMIT $2
+	`, likeEscaper.Replace(storage.NormalizeTag(prefix))+"%", limit, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+// Изменение настроек поста одним условным UPDATE: query с параметрами args, затем ID поста,
+// ожидаемой версией и пространством
+func (s *SQLiteStorage) updatePost(postID string, expectedVersion *int32, query string, args ...any) (*model.Post, error) {
+	result, err := s.db.Exec(query, append(args, postID, expectedVersion, s.space)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+
+	rowsAf, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAf == 0 {
+		return nil, s.versionMismatch("posts", postID, expectedVersion)
+	}
+
+	return s.GetPost(postID)
+}
+
+func (s *SQLiteStorage) SetCommentsEnabled(postID string, enabled bool, expectedVersion *int32) (*model.Post, error) {
+	return s.updatePost(postID, expectedVersion, `
+		UPDATE posts SET comments_enabled = $1, version = version + 1
+		WHERE id = $2 AND space_id = $4 AND ($3 IS NULL OR version = $3)`, enabled)
+}
+
+// Правило автоматического закрытия комментариев к посту
+func (s *SQLiteStorage) SetCommentsPolicy(postID string, policy storage.CommentsPolicy, expectedVersion *int32) (*model.Post, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return s.updatePost(postID, expectedVersion, `
+		UPDATE posts SET comments_close_days = $1, max_comments = $2, version = version + 1
+		WHERE id = $3 AND space_id = $5 AND ($4 IS NULL OR version = $4)`, policy.CloseAfterDays, policy.MaxComments)
+}
+
+// Медленный режим комментариев к посту
+func (s *SQLiteStorage) SetSlowMode(postID string, seconds int32, expectedVersion *int32) (*model.Post, error) {
+	return s.updatePost(postID, expectedVersion, `
+		UPDATE posts SET slow_mode_seconds = $1, version = version + 1
+		WHERE id = $2 AND space_id = $4 AND ($3 IS NULL OR version = $3)`, seconds)
+}
+
+// Блокировка и разблокировка ответов под комментарием
+func (s *SQLiteStorage) SetThreadLocked(commentID string, locked bool, expectedVersion *int32) (*model.Comment, error) {
+	result, err := s.db.Exec(`
+		UPDATE comments SET locked = $1, version = version + 1
+		WHERE id = $2 AND space_id = $4 AND ($3 IS NULL OR version = $3)`, locked, commentID, expectedVersion, s.space)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock thread: %w", err)
+	}
+
+	rowsAf, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAf == 0 {
+		return nil, s.versionMismatch("comments", commentID, expectedVersion)
+	}
+
+	return s.GetComment(commentID)
+}
+
+// Комментарий по ID (без ответов)
+func (s *SQLiteStorage) GetComment(commentID string) (*model.Comment, error) {
+	c, err := scanComment(s.db.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, err
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Правка текста поста
+func (s *SQLiteStorage) EditPost(postID, editorID, text string, expectedVersion *int32) (*model.Post, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	post, err := scanPost(tx.QueryRow(`
+		SELECT `+postColumns+`
+		FROM posts p
+		WHERE p.id = $1 AND p.space_id = $2
+	`, postID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.PostNotFound(postID)
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+	if err = storage.CheckVersion("post", postID, expectedVersion, post.Version); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now().Truncate(time.Microsecond)
+	if err = saveRevision(tx, postID, post.Text, post.AuthorID, post.CreatedAt, text, editorID, editedAt); err != nil {
+		return nil, err
+	}
+
+	entities := storage.ExtractEntities(text)
+	_, err = tx.Exec("UPDATE posts SET text = $1, entities = $2, edited_at = $3, version = version + 1 WHERE id = $4",
+		text, entitiesJSON{entities}, micros(editedAt), postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+	post, err = scanPost(tx.QueryRow("SELECT "+postColumns+" FROM posts p WHERE p.id = $1", postID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return post, nil
+}
+
+// Правка текста комментария
+func (s *SQLiteStorage) EditComment(commentID, editorID, text string, expectedVersion *int32) (*model.Comment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c, err := scanComment(tx.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now().Truncate(time.Microsecond)
+	if err = saveRevision(tx, commentID, c.Text, c.AuthorID, c.CreatedAt, text, editorID, editedAt); err != nil {
+		return nil, err
+	}
+
+	entities := storage.ExtractEntities(text)
+	_, err = tx.Exec("UPDATE comments SET text = $1, entities = $2, edited_at = $3, version = version + 1 WHERE id = $4",
+		text, entitiesJSON{entities}, micros(editedAt), commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+	c, err = scanComment(tx.QueryRow("SELECT "+commentColumns+" FROM comments c WHERE c.id = $1", commentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Сохранение версий при правке в транзакции самой правки
+func saveRevision(tx *sql.Tx, targetID, original string, authorID *string, createdAt time.Time,
+	text, editorID string, editedAt time.Time) error {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM revisions WHERE target_id = $1", targetID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count revisions: %w", err)
+	}
+
+	// При первой правке сохраняем исходный текст первой версией
+	if count == 0 {
+		_, err := tx.Exec(`
+			INSERT INTO revisions (target_id, number, text, editor_id, created_at)
+			VALUES ($1, 1, $2, $3, $4)
+		`, targetID, original, authorID, micros(createdAt))
+		if err != nil {
+			return fmt.Errorf("failed to save revision: %w", err)
+		}
+		count = 1
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO revisions (target_id, number, text, editor_id, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, targetID, count+1, text, editorID, micros(editedAt))
+	if err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+	return nil
+}
+
+// Версии текста поста или комментария
+func (s *SQLiteStorage) GetRevisions(targetID string) ([]*model.Revision, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND space_id = $2)
+		    OR EXISTS(SELECT 1 FROM comments WHERE id = $1 AND space_id = $2)
+	`, targetID, s.space).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.Errorf(storage.ErrNotFound, "post or comment with ID %s not found", targetID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT number, text, editor_id, created_at
+		FROM revisions
+		WHERE target_id = $1
+		ORDER BY number
+	`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.Revision{}
+	for rows.Next() {
+		var r model.Revision
+		var editorID sql.NullString
+		var createdAt timestamp
+		if err := rows.Scan(&r.Number, &r.Text, &editorID, &createdAt); err != nil {
+			return nil, err
+		}
+		r.EditorID = nullStringPtr(editorID)
+		r.CreatedAt = createdAt.Time
+		revisions = append(revisions, &r)
+	}
+	return revisions, rows.Err()
+}
+
+// Закрепление и открепление корневого комментария
+func (s *SQLiteStorage) SetCommentPinned(commentID string, pinned bool, expectedVersion *int32) (*model.Comment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c, err := scanComment(tx.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if c.ParentID != nil {
+		return nil, storage.Errorf(storage.ErrInvalidArgument, "only root comments can be pinned")
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	if pinned && !c.IsPinned {
+		var count int
+		err = tx.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = $1 AND pinned_at IS NOT NULL", c.PostID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pinned comments: %w", err)
+		}
+		if count >= storage.MaxPinnedComments {
+			return nil, storage.Errorf(storage.ErrConflict, "too many pinned comments: maximum allowed is %d", storage.MaxPinnedComments)
+		}
+
+		if _, err = tx.Exec("UPDATE comments SET pinned_at = $1 WHERE id = $2", micros(time.Now()), commentID); err != nil {
+			return nil, fmt.Errorf("failed to pin comment: %w", err)
+		}
+	}
+	if !pinned && c.IsPinned {
+		if _, err = tx.Exec("UPDATE comments SET pinned_at = NULL WHERE id = $1", commentID); err != nil {
+			return nil, fmt.Errorf("failed to unpin comment: %w", err)
+		}
+	}
+	if _, err = tx.Exec("UPDATE comments SET version = version + 1 WHERE id = $1", commentID); err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	c.IsPinned = pinned
+	c.Version++
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// Подписка на комментарии к посту
+func (s *SQLiteStorage) SubscribeToComments(postID string) (<-chan *model.Comment, *func(), error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND space_id = $2)", postID, s.space).Scan(&exists)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, storage.PostNotFound(postID)
+	}
+
+	ch, unsubscribe := s.comments.Subscribe(postID)
+	return ch, &unsubscribe, nil
+}
+
+// Подписка на публикацию постов
+func (s *SQLiteStorage) SubscribeToPosts() (<-chan *model.Post, *func(), error) {
+	ch, unsubscribe := s.posts.Subscribe(s.space)
+	return ch, &unsubscribe, nil
+}
+
+// Уведомления пользователя, новые первыми
+func (s *SQLiteStorage) GetNotifications(userID string, limit, offset int32) ([]*model.Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, kind, post_id, comment_id, created_at
+		FROM notifications
+		WHERE space_id = $4 AND user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// Подписка на новые уведомления пользователя
+func (s *SQLiteStorage) SubscribeToNotifications(userID string) (<-chan *model.Notification, *func(), error) {
+	ch, unsubscribe := s.notifications.Subscribe(storage.SpaceKey(s.space, userID))
+	return ch, &unsubscribe, nil
+}
+
+func (s *SQLiteStorage) publishNotifications(space string, notifications []*model.Notification) {
+	for _, n := range notifications {
+		s.notifications.Publish(storage.SpaceKey(space, n.UserID), n)
+	}
+}
+
+func scanNotification(row scanner) (*model.Notification, error) {
+	var n model.Notification
+	var commentID sql.NullString
+	var createdAt timestamp
+	if err := row.Scan(&n.ID, &n.UserID, &n.Kind, &n.PostID, &commentID, &createdAt); err != nil {
+		return nil, err
+	}
+	n.CommentID = nullStringPtr(commentID)
+	n.CreatedAt = createdAt.Time
+	return &n, nil
+}
+
+// Жалоба на комментарий
+func (s *SQLiteStorage) ReportComment(commentID, reporterID, reason string) (*model.Report, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status model.ModerationStatus
+	var postID string
+	var parentID sql.NullString
+	err = tx.QueryRow(`
+		SELECT status, post_id, parent_id FROM comments WHERE id = $1 AND space_id = $2
+	`, commentID, s.space).Scan(&status, &postID, &parentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to check comment: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM reports WHERE comment_id = $1 AND reporter_id = $2 AND NOT resolved)
+	`, commentID, reporterID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check reports: %w", err)
+	}
+	if exists {
+		return nil, storage.Errorf(storage.ErrAlreadyExists, "comment with ID %s already reported by this user", commentID)
+	}
+
+	createdAt := time.Now().Truncate(time.Microsecond)
+	report := &model.Report{
+		ID:         uuid.New().String(),
+		TargetID:   commentID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		CreatedAt:  createdAt,
+	}
+	_, err = tx.Exec(`
+		INSERT INTO reports (id, comment_id, reporter_id, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, report.ID, commentID, reporterID, reason, micros(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert report: %w", err)
+	}
+
+	// Набралось много жалоб - скрываем комментарий до решения модератора
+	if status == model.ModerationStatusVisible {
+		var openReports int
+		err = tx.QueryRow("SELECT COUNT(*) FROM reports WHERE comment_id = $1 AND NOT resolved", commentID).Scan(&openReports)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count reports: %w", err)
+		}
+		if openReports >= storage.ReportsToHold {
+			_, err = tx.Exec("UPDATE comments SET status = $1, version = version + 1 WHERE id = $2", model.ModerationStatusHeld, commentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hold comment: %w", err)
+			}
+			if err = updateCounters(tx, postID, nullStringPtr(parentID), -1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
+
+// Очередь модерации: комментарии с открытыми жалобами в порядке первой жалобы
+func (s *SQLiteStorage) GetModerationQueue(limit, offset int32) (*model.ModerationQueue, error) {
+	queue := &model.ModerationQueue{Items: []*model.ModerationItem{}}
+
+	err := s.db.QueryRow(`
+		SELECT COUNT(DISTINCT r.comment_id),
+		       COUNT(DISTINCT CASE WHEN c.status = $1 THEN r.comment_id END)
+		FROM reports r
+		JOIN comments c ON c.id = r.comment_id
+		WHERE NOT r.resolved AND c.space_id = $2
+	`, model.ModerationStatusHeld, s.space).Scan(&queue.ReportedCount, &queue.HeldCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count moderation queue: %w", err)
+	}
+	queue.Total = queue.ReportedCount
+
+	rows, err := s.db.Query(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		JOIN reports r ON r.comment_id = c.id AND NOT r.resolved
+		WHERE c.space_id = $3
+		GROUP BY c.id
+		ORDER BY MIN(r.created_at), c.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset, s.space)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itemsByComment := make(map[string]*model.ModerationItem)
+	var commentIDs []string
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		item := &model.ModerationItem{Comment: c, Reports: []*model.Report{}}
+		queue.Items = append(queue.Items, item)
+		itemsByComment[c.ID] = item
+		commentIDs = append(commentIDs, c.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(commentIDs) == 0 {
+		return queue, nil
+	}
+
+	// Открытые жалобы для комментариев страницы одним запросом
+	reportRows, err := s.db.Query(`
+		SELECT id, comment_id, reporter_id, reason, resolved, created_at
+		FROM reports
+		WHERE comment_id IN (SELECT value FROM json_each($1)) AND NOT resolved
+		ORDER BY created_at
+	`, jsonArray(commentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer reportRows.Close()
+
+	for reportRows.Next() {
+		var r model.Report
+		var createdAt timestamp
+
+		if err := reportRows.Scan(&r.ID, &r.TargetID, &r.ReporterID, &r.Reason, &r.Resolved, &createdAt); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = createdAt.Time
+
+		item := itemsByComment[r.TargetID]
+		item.Reports = append(item.Reports, &r)
+		item.ReportCount++
+	}
+
+	return queue, reportRows.Err()
+}
+
+// Решение модератора: меняет статус комментария, закрывает жалобы и пишет запись в журнал
+func (s *SQLiteStorage) ModerateComment(commentID, moderatorID string, action model.ModerationAction, reason *string, expectedVersion *int32) (*model.Comment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c, err := scanComment(tx.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		WHERE c.id = $1 AND c.space_id = $2
+	`, commentID, s.space))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.CommentNotFound(commentID)
+		}
+		return nil, fmt.Errorf("failed to load comment: %w", err)
+	}
+	if err = storage.CheckVersion("comment", commentID, expectedVersion, c.Version); err != nil {
+		return nil, err
+	}
+
+	// Скрытые комментарии не учитываются в счетчиках
+	wasCounted := storage.IsCounted(c.Status)
+	c.Status = storage.StatusForAction(action)
+	isCounted := storage.IsCounted(c.Status)
+
+	if _, err = tx.Exec("UPDATE comments SET status = $1, version = version + 1 WHERE id = $2", c.Status, commentID); err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+	c.Version++
+	if isCounted != wasCounted {
+		delta := 1
+		if !isCounted {
+			delta = -1
+		}
+		if err = updateCounters(tx, c.PostID, c.ParentID, delta); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE reports SET resolved = 1 WHERE comment_id = $1 AND NOT resolved", commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reports: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO moderation_decisions (id, comment_id, moderator_id, action, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uuid.New().String(), commentID, moderatorID, action, reason, micros(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert moderation decision: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	storage.ApplyModeration(c)
+	return c, nil
+}
+
+// История решений модераторов по комментарию
+func (s *SQLiteStorage) GetModerationLog(commentID string) ([]*model.ModerationDecision, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM comments WHERE id = $1 AND space_id = $2)", commentID, s.space).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check comment existence: %w", err)
+	}
+	if !exists {
+		return nil, storage.CommentNotFound(commentID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, comment_id, moderator_id, action, reason, created_at
+		FROM moderation_decisions
+		WHERE comment_id = $1
+		ORDER BY created_at
+	`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []*model.ModerationDecision{}
+	for rows.Next() {
+		var d model.ModerationDecision
+		var reason sql.NullString
+		var createdAt timestamp
+
+		if err := rows.Scan(&d.ID, &d.TargetID, &d.ModeratorID, &d.Action, &reason, &createdAt); err != nil {
+			return nil, err
+		}
+		d.Reason = nullStringPtr(reason)
+		d.CreatedAt = createdAt.Time
+		decisions = append(decisions, &d)
+	}
+	return decisions, rows.Err()
+}
+
+// Уведомления упомянутым пользователям в пространстве space в транзакции создания поста или комментария.
+// Возвращаются для рассылки подписчикам после фиксации транзакции
+func notifyMentioned(tx *sql.Tx, space string, users []string, postID string, commentID *string, createdAt time.Time) ([]*model.Notification, error) {
+	var notifications []*model.Notification
+	for _, userID := range users {
+		n := &model.Notification{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			Kind:      model.NotificationKindMention,
+			PostID:    postID,
+			CommentID: commentID,
+			CreatedAt: createdAt,
+		}
+		_, err := tx.Exec(`
+			INSERT INTO notifications (id, space_id, user_id, kind, post_id, comment_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, n.ID, space, userID, n.Kind, postID, commentID, micros(createdAt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// Изменение счетчиков поста и предков комментария на delta
+func updateCounters(tx *sql.Tx, postID string, parentID *string, delta int) error {
+	_, err := tx.Exec(`
+		UPDATE posts
+		SET comment_count = comment_count + $1,
+		    root_comment_count = root_comment_count + CASE WHEN $2 THEN $1 ELSE 0 END
+		WHERE id = $3
+	`, delta, parentID == nil, postID)
+	if err != nil {
+		return fmt.Errorf("failed to update post counters: %w", err)
+	}
+
+	if parentID == nil {
+		return nil
+	}
+
+	// Родителю меняем и число ответов, и число потомков, остальным предкам - только потомков
+	_, err = tx.Exec(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id = $2
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		)
+		UPDATE comments
+		SET descendant_count = descendant_count + $1,
+		    reply_count = reply_count + CASE WHEN id = $2 THEN $1 ELSE 0 END
+		WHERE id IN (SELECT id FROM ancestors)
+	`, delta, *parentID)
+	if err != nil {
+		return fmt.Errorf("failed to update comment counters: %w", err)
+	}
+
+	return nil
+}
+
+// Поля правила закрытия комментариев. Срок считается от публикации, поэтому у черновика его нет
+func applyCommentsPolicy(post *model.Post, policy storage.CommentsPolicy) {
+	post.MaxComments = policy.Limit()
+	if post.Status == model.PostStatusPublished {
+		post.CommentsCloseAt = policy.CloseAt(post.CreatedAt)
+	}
+}
+
+// Ошибка условного UPDATE, не изменившего ни одной строки: объекта нет или его версия
+// отличается от ожидаемой. table - posts или comments
+func (s *SQLiteStorage) versionMismatch(table, id string, expected *int32) error {
+	kind, notFound := "post", storage.PostNotFound(id)
+	if table == "comments" {
+		kind, notFound = "comment", storage.CommentNotFound(id)
+	}
+
+	var actual int32
+	err := s.db.QueryRow("SELECT version FROM "+table+" WHERE id = $1 AND space_id = $2", id, s.space).Scan(&actual)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return notFound
+		}
+		return fmt.Errorf("failed to load %s version: %w", kind, err)
+	}
+	if err := storage.CheckVersion(kind, id, expected, actual); err != nil {
+		return err
+	}
+	return storage.Errorf(storage.ErrConflict, "%s with ID %s was modified concurrently", kind, id)
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Упоминания и хештеги в колонке entities (JSON-текст)
+type entitiesJSON struct {
+	storage.Entities
+}
+
+func (e *entitiesJSON) Scan(src any) error {
+	e.Entities = storage.Entities{}
+
+	// NULL бывает у LEFT JOIN без комментария
+	if src != nil {
+		data, err := textBytes(src)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &e.Entities); err != nil {
+			return fmt.Errorf("failed to decode entities: %w", err)
+		}
+	}
+
+	if e.Mentions == nil {
+		e.Mentions = []*model.Mention{}
+	}
+	if e.Hashtags == nil {
+		e.Hashtags = []*model.Hashtag{}
+	}
+	return nil
+}
+
+func (e entitiesJSON) Value() (driver.Value, error) {
+	data, err := json.Marshal(e.Entities)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Список строк из json_group_array (теги поста)
+type stringList []string
+
+func (l *stringList) Scan(src any) error {
+	data, err := textBytes(src)
+	if err != nil {
+		return err
+	}
+	*l = []string{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return fmt.Errorf("failed to decode list: %w", err)
+	}
+	return nil
+}
+
+// Время в колонке - микросекунды Unix, NULL - отсутствие времени
+type timestamp struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = timestamp{}
+	case int64:
+		*t = timestamp{Time: time.UnixMicro(v), Valid: true}
+	default:
+		return fmt.Errorf("unexpected timestamp type %T", src)
+	}
+	return nil
+}
+
+func (t timestamp) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// Значения времени для параметров запроса
+func micros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func microsPtr(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixMicro()
+}
+
+// Список ID параметром запроса: в SQL разворачивается через json_each
+func jsonArray(values []string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func textBytes(src any) ([]byte, error) {
+	switch data := src.(type) {
+	case []byte:
+		return data, nil
+	case string:
+		return []byte(data), nil
+	default:
+		return nil, fmt.Errorf("unexpected text type %T", src)
+	}
+}
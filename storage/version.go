@@ -0,0 +1,30 @@
+package storage
+
+import "fmt"
+
+// Версия нового поста или комментария. Каждое изменение (правка, публикация, настройки, модерация)
+// увеличивает ее на 1, счетчики комментариев и ответов версию не меняют
+const InitialVersion int32 = 1
+
+// Объект изменили после того, как клиент прочитал его версию
+type VersionConflictError struct {
+	Kind     string // post или comment
+	ID       string
+	Expected int32 // Версия, которую ожидал клиент
+	Actual   int32 // Текущая версия
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s with ID %s was modified: expected version %d, current version %d",
+		e.Kind, e.ID, e.Expected, e.Actual)
+}
+
+func (e *VersionConflictError) Unwrap() error { return ErrConflict }
+
+// Проверка ожидаемой версии объекта перед изменением. expected nil - без проверки
+func CheckVersion(kind, id string, expected *int32, actual int32) error {
+	if expected == nil || *expected == actual {
+		return nil
+	}
+	return &VersionConflictError{Kind: kind, ID: id, Expected: *expected, Actual: actual}
+}
@@ -0,0 +1,130 @@
+package tenant
+
+import (
+	"PostAndComment/storage"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Заголовок с ID пространства. Имеет приоритет над именем хоста
+const SpaceHeader = "X-Space-ID"
+
+// Пространство (сообщество) со своим набором постов и комментариев
+type Space struct {
+	ID    string
+	Hosts []string // Имена хостов, запросы к которым попадают в пространство
+}
+
+type ctxKey struct{}
+
+type Resolver struct {
+	spaces   map[string]*Space
+	hosts    map[string]*Space
+	fallback *Space // Пространство для запросов без заголовка и с незнакомым хостом, nil - отказ
+}
+
+// Резолвер для списка пространств. Без списка все запросы идут в пространство по умолчанию.
+// Запросы без заголовка и с незнакомым хостом попадают в пространство по умолчанию,
+// только если оно есть в списке
+func New(spaces []Space) (*Resolver, error) {
+	if len(spaces) == 0 {
+		spaces = []Space{{ID: storage.DefaultSpace}}
+	}
+
+	r := &Resolver{spaces: make(map[string]*Space, len(spaces)), hosts: make(map[string]*Space)}
+	for i := range spaces {
+		space := &spaces[i]
+		if err := storage.ValidateSpaceID(space.ID); err != nil {
+			return nil, err
+		}
+		if _, ok := r.spaces[space.ID]; ok {
+			return nil, fmt.Errorf("duplicate space %q", space.ID)
+		}
+		r.spaces[space.ID] = space
+
+		for _, host := range space.Hosts {
+			host = normalizeHost(host)
+			if other, ok := r.hosts[host]; ok {
+				return nil, fmt.Errorf("host %q is assigned to spaces %q and %q", host, other.ID, space.ID)
+			}
+			r.hosts[host] = space
+		}
+	}
+	r.fallback = r.spaces[storage.DefaultSpace]
+	return r, nil
+}
+
+// Разбор списка пространств вида "docs=docs.example.com|docs.local,blog=blog.example.com,default"
+func ParseSpaces(spec string) ([]Space, error) {
+	var spaces []Space
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		id, hosts, _ := strings.Cut(item, "=")
+		space := Space{ID: strings.TrimSpace(id)}
+		if space.ID == "" {
+			return nil, fmt.Errorf("invalid space %q: empty ID", item)
+		}
+		for _, host := range strings.Split(hosts, "|") {
+			if host = strings.TrimSpace(host); host != "" {
+				space.Hosts = append(space.Hosts, host)
+			}
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, nil
+}
+
+// Пространство запроса: по заголовку, иначе по имени хоста, иначе пространство по умолчанию
+func (r *Resolver) Resolve(req *http.Request) (*Space, error) {
+	if id := strings.TrimSpace(req.Header.Get(SpaceHeader)); id != "" {
+		if space, ok := r.spaces[id]; ok {
+			return space, nil
+		}
+		return nil, fmt.Errorf("unknown space %q", id)
+	}
+
+	if space, ok := r.hosts[normalizeHost(req.Host)]; ok {
+		return space, nil
+	}
+	if r.fallback == nil {
+		return nil, fmt.Errorf("no space for host %q", req.Host)
+	}
+	return r.fallback, nil
+}
+
+// Middleware кладет пространство запроса в контекст. На запросы к неизвестному
+// пространству отвечает 404: существование чужих пространств не раскрывается
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		space, err := r.Resolve(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithSpace(req.Context(), space)))
+	})
+}
+
+// Контекст с пространством: его же видят хранилища (storage.ForContext)
+func WithSpace(ctx context.Context, space *Space) context.Context {
+	return context.WithValue(storage.WithSpace(ctx, space.ID), ctxKey{}, space)
+}
+
+// Пространство запроса, nil - если запрос пришел не через Middleware
+func ForContext(ctx context.Context) *Space {
+	space, _ := ctx.Value(ctxKey{}).(*Space)
+	return space
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
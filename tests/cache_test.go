@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"PostAndComment/storage"
+	"PostAndComment/storage/cache"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tests/conformance"
+	"PostAndComment/tests/testutils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+	inner   *memory.InMemoryStorage
+	storage *cache.CachedStorage
+}
+
+func (suite *CacheTestSuite) SetupTest() {
+	suite.inner = memory.New()
+	suite.storage = cache.New(suite.inner, cache.NewLRU(100, time.Minute))
+}
+
+// Повторное чтение поста и страницы дерева берется из кеша
+func (suite *CacheTestSuite) TestReadThrough() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "comment")
+
+	for i := 0; i < 2; i++ {
+		_, err := suite.storage.GetPost(post.ID)
+		require.NoError(suite.T(), err)
+		comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+		require.NoError(suite.T(), err)
+		require.Len(suite.T(), comments, 1)
+	}
+
+	stats := suite.storage.Stats()
+	assert.Equal(suite.T(), uint64(2), stats.Misses)
+	assert.Equal(suite.T(), uint64(2), stats.Hits)
+}
+
+// Разные страницы и фильтры кешируются отдельно
+func (suite *CacheTestSuite) TestTreePagesAreSeparate() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	first := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "first")
+	time.Sleep(10 * time.Millisecond)
+	second := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "second")
+
+	page1, err := suite.storage.GetCommentsTree(post.ID, 1, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	page2, err := suite.storage.GetCommentsTree(post.ID, 1, 1, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	filtered, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{After: &first.CreatedAt})
+	require.NoError(suite.T(), err)
+
+	require.Len(suite.T(), page1, 1)
+	require.Len(suite.T(), page2, 1)
+	assert.NotEqual(suite.T(), page1[0].ID, page2[0].ID)
+	require.Len(suite.T(), filtered, 1)
+	assert.Equal(suite.T(), second.ID, filtered[0].ID)
+	assert.Equal(suite.T(), uint64(0), suite.storage.Stats().Hits)
+}
+
+// Новый комментарий сбрасывает пост (счетчики) и все страницы его дерева, но не другие посты
+func (suite *CacheTestSuite) TestInvalidate_AddComment() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	other := testutils.CreateTestPost(suite.T(), suite.storage, "Other post", true)
+
+	_, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.GetCommentsTree(other.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "comment")
+
+	retrieved, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), retrieved.CommentCount)
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), comments, 1)
+
+	hits := suite.storage.Stats().Hits
+	_, err = suite.storage.GetCommentsTree(other.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), hits+1, suite.storage.Stats().Hits)
+	assert.Equal(suite.T(), uint64(1), suite.storage.Stats().Invalidations)
+}
+
+// Изменения поста и комментариев видны сразу после вызова
+func (suite *CacheTestSuite) TestInvalidate_Mutations() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "comment")
+
+	cachedPost := func() *storage.PostExport {
+		p, err := suite.storage.GetPost(post.ID)
+		require.NoError(suite.T(), err)
+		comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+		require.NoError(suite.T(), err)
+		return &storage.PostExport{Post: p, Comments: comments}
+	}
+	cachedPost()
+
+	_, err := suite.storage.SetCommentsEnabled(post.ID, false, nil)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), cachedPost().Post.CommentsEnabled)
+
+	_, err = suite.storage.EditPost(post.ID, "", "Edited post", nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Edited post", cachedPost().Post.Text)
+
+	_, err = suite.storage.EditComment(comment.ID, "", "Edited comment", nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Edited comment", cachedPost().Comments[0].Text)
+
+	_, err = suite.storage.SetCommentPinned(comment.ID, true, nil)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), cachedPost().Comments[0].IsPinned)
+
+	_, err = suite.storage.SetSlowMode(post.ID, 30, nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(30), cachedPost().Post.SlowModeSeconds)
+}
+
+// Изменения мимо кеша видны только после истечения TTL
+func (suite *CacheTestSuite) TestTTL() {
+	s := cache.New(suite.inner, cache.NewLRU(100, 50*time.Millisecond))
+	post := testutils.CreateTestPost(suite.T(), s, "Test post", true)
+	_, err := s.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+
+	testutils.CreateTestComment(suite.T(), suite.inner, post.ID, nil, "comment")
+
+	comments, err := s.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), comments)
+
+	time.Sleep(100 * time.Millisecond)
+	comments, err = s.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), comments, 1)
+}
+
+// Для нескольких постов вложенное хранилище читает только отсутствующие в кеше
+func (suite *CacheTestSuite) TestGetCommentsTrees_PartialHit() {
+	first := testutils.CreateTestPost(suite.T(), suite.storage, "First", true)
+	second := testutils.CreateTestPost(suite.T(), suite.storage, "Second", true)
+	testutils.CreateTestComment(suite.T(), suite.storage, first.ID, nil, "first")
+	testutils.CreateTestComment(suite.T(), suite.storage, second.ID, nil, "second")
+
+	_, err := suite.storage.GetCommentsTree(first.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+
+	trees, err := suite.storage.GetCommentsTrees([]string{first.ID, second.ID, "nonexistent-id"}, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), trees[first.ID], 1)
+	assert.Len(suite.T(), trees[second.ID], 1)
+	assert.NotContains(suite.T(), trees, "nonexistent-id")
+
+	stats := suite.storage.Stats()
+	assert.Equal(suite.T(), uint64(1), stats.Hits)
+	assert.Equal(suite.T(), uint64(3), stats.Misses)
+}
+
+// Результат из кеша - копия: его изменение не влияет на следующие чтения
+func (suite *CacheTestSuite) TestResultIsCopy() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	_, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+
+	cached, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	cached.Text = "changed"
+
+	again, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Test post", again.Text)
+}
+
+// Хранилище запроса пользуется общими записями и счетчиками кеша
+func (suite *CacheTestSuite) TestWithContext_SharesCache() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	_, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+
+	bound := storage.ForContext(storage.NewSession(context.Background()), suite.storage)
+	_, err = bound.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint64(1), suite.storage.Stats().Hits)
+
+	testutils.CreateTestComment(suite.T(), bound, post.ID, nil, "comment")
+	retrieved, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), retrieved.CommentCount)
+}
+
+// Закешированный пост не отдается запросам из другого пространства
+func (suite *CacheTestSuite) TestSpaces() {
+	docs := storage.ForContext(storage.WithSpace(context.Background(), "docs"), suite.storage)
+	blog := storage.ForContext(storage.WithSpace(context.Background(), "blog"), suite.storage)
+
+	post := testutils.CreateTestPost(suite.T(), docs, "Test post", true)
+	_, err := docs.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	_, err = docs.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+
+	_, err = blog.GetPost(post.ID)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	assert.Equal(suite.T(), uint64(0), suite.storage.Stats().Hits)
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+// Хранилище с кешем ведет себя так же, как вложенное
+func TestCachedStorageConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) storage.Storage {
+		return cache.New(memory.New(), cache.NewLRU(1000, time.Minute))
+	})
+}
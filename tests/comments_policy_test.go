@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type CommentsPolicyTestSuite struct {
+	suite.Suite
+	client *client.Client
+}
+
+func (suite *CommentsPolicyTestSuite) SetupTest() {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{
+		Storage:        memory.New(),
+		CommentsPolicy: storage.CommentsPolicy{CloseAfterDays: 30, MaxComments: 1},
+	})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+func (suite *CommentsPolicyTestSuite) errors(query string, options ...client.Option) []gqlError {
+	resp, err := suite.client.RawPost(query, options...)
+	require.NoError(suite.T(), err)
+	var errs []gqlError
+	require.NoError(suite.T(), json.Unmarshal(resp.Errors, &errs))
+	return errs
+}
+
+// Срок и лимит правила
+func (suite *CommentsPolicyTestSuite) TestClosed() {
+	published := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := storage.CommentsPolicy{CloseAfterDays: 2, MaxComments: 10}
+
+	assert.False(suite.T(), policy.Closed(published, 9, published.Add(47*time.Hour)))
+	assert.True(suite.T(), policy.Closed(published, 9, published.Add(48*time.Hour)))
+	assert.True(suite.T(), policy.Closed(published, 10, published))
+	assert.False(suite.T(), storage.CommentsPolicy{}.Closed(published, 1000, published.AddDate(10, 0, 0)))
+}
+
+// Правило по умолчанию применяется к новому посту, аргументы мутации его переопределяют
+func (suite *CommentsPolicyTestSuite) TestDefaultPolicy() {
+	var resp struct {
+		Default struct {
+			MaxComments     *int
+			CommentsCloseAt *string
+		}
+		Custom struct {
+			MaxComments     *int
+			CommentsCloseAt *string
+		}
+	}
+	suite.client.MustPost(`mutation {
+		default: newPost(text: "post", commentsEnabled: true) { maxComments commentsCloseAt }
+		custom: newPost(text: "post", commentsEnabled: true, closeCommentsAfterDays: 0, maxComments: 5) { maxComments commentsCloseAt }
+	}`, &resp)
+
+	require.NotNil(suite.T(), resp.Default.MaxComments)
+	assert.Equal(suite.T(), 1, *resp.Default.MaxComments)
+	assert.NotNil(suite.T(), resp.Default.CommentsCloseAt)
+	require.NotNil(suite.T(), resp.Custom.MaxComments)
+	assert.Equal(suite.T(), 5, *resp.Custom.MaxComments)
+	assert.Nil(suite.T(), resp.Custom.CommentsCloseAt)
+}
+
+// Закрытое обсуждение отдает ошибку с кодом COMMENTS_CLOSED
+func (suite *CommentsPolicyTestSuite) TestErrorCode() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &post)
+
+	const addComment = `mutation($id: ID!) { addComment(postID: $id, text: "comment") { id } }`
+	var comment struct{ AddComment struct{ ID string } }
+	suite.client.MustPost(addComment, &comment, client.Var("id", post.NewPost.ID))
+
+	errs := suite.errors(addComment, client.Var("id", post.NewPost.ID))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "COMMENTS_CLOSED", errs[0].Extensions["code"])
+	assert.Contains(suite.T(), errs[0].Message, "comments are closed")
+}
+
+// Заблокировать ветку может автор поста или модератор, ответ в нее получает THREAD_LOCKED
+func (suite *CommentsPolicyTestSuite) TestLockThread() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true, maxComments: 0) { id } }`, &post, asUser("author"))
+	var comment struct{ AddComment struct{ ID string } }
+	suite.client.MustPost(`mutation($id: ID!) { addComment(postID: $id, text: "comment") { id } }`, &comment,
+		client.Var("id", post.NewPost.ID))
+
+	const lock = `mutation($id: ID!) { lockThread(commentID: $id) { isLocked } }`
+	errs := suite.errors(lock, client.Var("id", comment.AddComment.ID), asUser("stranger"))
+	require.Len(suite.T(), errs, 1)
+	assert.Contains(suite.T(), errs[0].Message, "access denied")
+
+	var locked struct{ LockThread struct{ IsLocked bool } }
+	suite.client.MustPost(lock, &locked, client.Var("id", comment.AddComment.ID), asUser("moderator"))
+	assert.True(suite.T(), locked.LockThread.IsLocked)
+
+	errs = suite.errors(`mutation($post: ID!, $parent: ID!) { addComment(postID: $post, parentID: $parent, text: "reply") { id } }`,
+		client.Var("post", post.NewPost.ID), client.Var("parent", comment.AddComment.ID))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "THREAD_LOCKED", errs[0].Extensions["code"])
+}
+
+// Повторный комментарий в медленном режиме получает SLOW_MODE и время до следующей попытки
+func (suite *CommentsPolicyTestSuite) TestSlowMode() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true, maxComments: 0) { id } }`, &post, asUser("author"))
+
+	const setSlowMode = `mutation($id: ID!, $seconds: Int!) { setSlowMode(postID: $id, seconds: $seconds) { slowModeSeconds } }`
+	errs := suite.errors(setSlowMode, client.Var("id", post.NewPost.ID), client.Var("seconds", -1), asUser("author"))
+	require.Len(suite.T(), errs, 1)
+	assert.Contains(suite.T(), errs[0].Message, "seconds must be between")
+
+	var slowMode struct{ SetSlowMode struct{ SlowModeSeconds int } }
+	suite.client.MustPost(setSlowMode, &slowMode, client.Var("id", post.NewPost.ID), client.Var("seconds", 30), asUser("author"))
+	assert.Equal(suite.T(), 30, slowMode.SetSlowMode.SlowModeSeconds)
+
+	const addComment = `mutation($id: ID!) { addComment(postID: $id, text: "comment") { authorID } }`
+	var comment struct{ AddComment struct{ AuthorID string } }
+	suite.client.MustPost(addComment, &comment, client.Var("id", post.NewPost.ID), asUser("alice"))
+	assert.Equal(suite.T(), "alice", comment.AddComment.AuthorID)
+
+	errs = suite.errors(addComment, client.Var("id", post.NewPost.ID), asUser("alice"))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "SLOW_MODE", errs[0].Extensions["code"])
+	assert.EqualValues(suite.T(), 30, errs[0].Extensions["retryAfter"])
+
+	// Анонимный комментарий в медленном режиме ограничить нельзя, поэтому он запрещен
+	errs = suite.errors(addComment, client.Var("id", post.NewPost.ID))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "UNAUTHENTICATED", errs[0].Extensions["code"])
+}
+
+// Запуск тестов
+func TestCommentsPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(CommentsPolicyTestSuite))
+}
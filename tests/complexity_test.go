@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/storage/memory"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ComplexityTestSuite struct {
+	suite.Suite
+	client *client.Client
+}
+
+type gqlError struct {
+	Message    string         `json:"message"`
+	Extensions map[string]any `json:"extensions"`
+}
+
+func (suite *ComplexityTestSuite) SetupTest() {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: memory.New()})))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.FixedComplexityLimit(10000))
+	srv.Use(graph.DepthLimit{Max: 5})
+
+	suite.client = client.New(srv)
+}
+
+func (suite *ComplexityTestSuite) queryErrors(query string) []gqlError {
+	resp, err := suite.client.RawPost(query)
+	require.NoError(suite.T(), err)
+	if resp.Errors == nil {
+		return nil
+	}
+
+	var errs []gqlError
+	require.NoError(suite.T(), json.Unmarshal(resp.Errors, &errs))
+	return errs
+}
+
+// Обычный запрос проходит
+func (suite *ComplexityTestSuite) TestRegularQuery() {
+	errs := suite.queryErrors(`query { getPosts(limit: 10) { id comments(limit: 10) { id replies { id } } } }`)
+	assert.Empty(suite.T(), errs)
+}
+
+// Запрос с огромными limit отклоняется с указанием стоимости
+func (suite *ComplexityTestSuite) TestComplexityLimitExceeded() {
+	errs := suite.queryErrors(`query {
+		getPosts(limit: 10000) { comments(limit: 10000) { replies(limit: 10000) { id } } }
+	}`)
+
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "COMPLEXITY_LIMIT_EXCEEDED", errs[0].Extensions["code"])
+	assert.Contains(suite.T(), errs[0].Message, "operation has complexity")
+	assert.Contains(suite.T(), errs[0].Message, "exceeds the limit of 10000")
+}
+
+// Слишком глубокий запрос отклоняется
+func (suite *ComplexityTestSuite) TestDepthLimitExceeded() {
+	errs := suite.queryErrors(`query {
+		getPosts(limit: 1) { comments(limit: 1) { replies(limit: 1) { replies(limit: 1) { replies(limit: 1) { id } } } } }
+	}`)
+
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "DEPTH_LIMIT_EXCEEDED", errs[0].Extensions["code"])
+	assert.Contains(suite.T(), errs[0].Message, "operation has depth 6, which exceeds the limit of 5")
+}
+
+// Глубина считается и через фрагменты
+func (suite *ComplexityTestSuite) TestDepthLimitWithFragments() {
+	errs := suite.queryErrors(`
+		query { getPosts(limit: 1) { ...PostFields } }
+		fragment PostFields on Post { comments(limit: 1) { replies(limit: 1) { replies(limit: 1) { replies(limit: 1) { id } } } } }
+	`)
+
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "DEPTH_LIMIT_EXCEEDED", errs[0].Extensions["code"])
+}
+
+// limit больше максимального размера страницы отклоняется резолвером
+func (suite *ComplexityTestSuite) TestMaxPageSize() {
+	errs := suite.queryErrors(`query { getPosts(limit: 101) { id } }`)
+
+	require.Len(suite.T(), errs, 1)
+	assert.Contains(suite.T(), errs[0].Message, "limit must not exceed 100")
+}
+
+// Запуск тестов
+func TestComplexityTestSuite(t *testing.T) {
+	suite.Run(t, new(ComplexityTestSuite))
+}
@@ -0,0 +1,469 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Фильтр корневых комментариев по времени создания, ответы возвращаются целиком
+func (suite *Suite) TestGetCommentsTree_CreatedRange() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	first := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Comment 1")
+	second := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Comment 2")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &first.ID, "Reply")
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{Before: &second.CreatedAt})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 1)
+	assert.Equal(suite.T(), first.ID, comments[0].ID)
+	require.Len(suite.T(), comments[0].Replies, 1)
+	assert.Equal(suite.T(), reply.ID, comments[0].Replies[0].ID)
+
+	comments, err = suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{After: &second.CreatedAt})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), comments)
+}
+
+// Комментарии, добавленные в одну секунду, сохраняют порядок
+func (suite *Suite) TestGetCommentsTree_SameSecondOrder() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+
+	var created []*model.Comment
+	for i := 0; i < 5; i++ {
+		created = append(created, testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, fmt.Sprintf("Comment %d", i+1)))
+	}
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 5)
+	for i, comment := range comments {
+		assert.Equal(suite.T(), created[i].ID, comment.ID)
+		assert.True(suite.T(), created[i].CreatedAt.Equal(comment.CreatedAt))
+		if i > 0 {
+			assert.True(suite.T(), comment.CreatedAt.After(comments[i-1].CreatedAt))
+		}
+	}
+}
+
+// Добавить комментарий к посту
+func (suite *Suite) TestAddComment_RootComment() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post text", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "root comment"})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.ID, comment.PostID)
+	assert.Equal(suite.T(), "root comment", comment.Text)
+	assert.Nil(suite.T(), comment.ParentID)
+	assert.NotEmpty(suite.T(), comment.ID)
+	assert.NotEmpty(suite.T(), comment.CreatedAt)
+}
+
+// Упоминания и хештеги в комментарии, уведомления упомянутым
+func (suite *Suite) TestAddComment_Mentions() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "hi @alice and @bob, see #GoLang @alice")
+
+	assert.Len(suite.T(), comment.Mentions, 3)
+	assert.Equal(suite.T(), []*model.Hashtag{{Tag: "golang", Offset: 24, Length: 7}}, comment.Hashtags)
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 1)
+	assert.Equal(suite.T(), comment.Mentions, comments[0].Mentions)
+	assert.Equal(suite.T(), comment.Hashtags, comments[0].Hashtags)
+
+	notifications, err := suite.storage.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), notifications, 1)
+	assert.Equal(suite.T(), model.NotificationKindMention, notifications[0].Kind)
+	assert.Equal(suite.T(), post.ID, notifications[0].PostID)
+	require.NotNil(suite.T(), notifications[0].CommentID)
+	assert.Equal(suite.T(), comment.ID, *notifications[0].CommentID)
+
+	notifications, err = suite.storage.GetNotifications("carol", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), notifications)
+}
+
+// Закрепленные комментарии идут первыми на каждой странице
+func (suite *Suite) TestSetCommentPinned_FirstOnEveryPage() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	var roots []*model.Comment
+	for i := 0; i < 4; i++ {
+		roots = append(roots, testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, fmt.Sprintf("Comment %d", i+1)))
+	}
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &roots[3].ID, "Reply")
+
+	_, err := suite.storage.SetCommentPinned(roots[3].ID, true, nil)
+	require.NoError(suite.T(), err)
+	pinned, err := suite.storage.SetCommentPinned(roots[2].ID, true, nil)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), pinned.IsPinned)
+
+	page, err := suite.storage.GetCommentsTree(post.ID, 1, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page, 3)
+	assert.Equal(suite.T(), roots[2].ID, page[0].ID)
+	assert.Equal(suite.T(), roots[3].ID, page[1].ID)
+	assert.Len(suite.T(), page[1].Replies, 1)
+	assert.Equal(suite.T(), roots[0].ID, page[2].ID)
+	assert.False(suite.T(), page[2].IsPinned)
+
+	page, err = suite.storage.GetCommentsTree(post.ID, 1, 5, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page, 2)
+	assert.True(suite.T(), page[0].IsPinned)
+	assert.True(suite.T(), page[1].IsPinned)
+
+	// после открепления комментарий возвращается на свое место
+	_, err = suite.storage.SetCommentPinned(roots[2].ID, false, nil)
+	require.NoError(suite.T(), err)
+
+	page, err = suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page, 4)
+	assert.Equal(suite.T(), roots[3].ID, page[0].ID)
+	assert.Equal(suite.T(), roots[0].ID, page[1].ID)
+	assert.Equal(suite.T(), roots[2].ID, page[3].ID)
+}
+
+// Закрепить можно только корневой комментарий и не больше лимита
+func (suite *Suite) TestSetCommentPinned_Errors() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	root := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Root")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &root.ID, "Reply")
+
+	_, err := suite.storage.SetCommentPinned(reply.ID, true, nil)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "only root comments")
+
+	_, err = suite.storage.SetCommentPinned("nonexistent-id", true, nil)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+
+	for i := 0; i < storage.MaxPinnedComments; i++ {
+		comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, fmt.Sprintf("Comment %d", i))
+		_, err = suite.storage.SetCommentPinned(comment.ID, true, nil)
+		require.NoError(suite.T(), err)
+	}
+
+	_, err = suite.storage.SetCommentPinned(root.ID, true, nil)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "too many pinned comments")
+}
+
+// Добавить ответ к комментарию
+func (suite *Suite) TestAddComment_ReplyToComment() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "test post text", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	rootComment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "root comment"})
+	require.NoError(suite.T(), err)
+
+	reply, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &rootComment.ID, Text: "reply comment"})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.ID, reply.PostID)
+	assert.Equal(suite.T(), "reply comment", reply.Text)
+	require.NotNil(suite.T(), reply.ParentID)
+	assert.Equal(suite.T(), rootComment.ID, *reply.ParentID)
+}
+
+// Комментарий к несуществующему посту
+func (suite *Suite) TestAddComment_NonexistentPost() {
+	_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: "nonexistent-post", Text: "Comment"})
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+}
+
+// Комментарий к посту с выключенными комментариями
+func (suite *Suite) TestAddComment_DisabledComments() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Post without comments", CommentsEnabled: false})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Test comment"})
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "comments are disabled")
+}
+
+// После maxComments комментариев обсуждение закрывается, правило можно снять
+func (suite *Suite) TestAddComment_CommentsPolicy() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{
+		Text: "Test post", CommentsEnabled: true, CommentsPolicy: storage.CommentsPolicy{CloseAfterDays: 7, MaxComments: 2},
+	})
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), post.MaxComments)
+	assert.EqualValues(suite.T(), 2, *post.MaxComments)
+	require.NotNil(suite.T(), post.CommentsCloseAt)
+	assert.True(suite.T(), post.CreatedAt.AddDate(0, 0, 7).Equal(*post.CommentsCloseAt))
+
+	root := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "first")
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &root.ID, "second")
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "third"})
+	require.Error(suite.T(), err)
+	assert.ErrorIs(suite.T(), err, storage.ErrCommentsClosed)
+
+	updated, err := suite.storage.SetCommentsPolicy(post.ID, storage.CommentsPolicy{}, nil)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), updated.MaxComments)
+	assert.Nil(suite.T(), updated.CommentsCloseAt)
+
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "third")
+}
+
+// Ответы под заблокированным комментарием запрещены на любой глубине
+func (suite *Suite) TestAddComment_ThreadLocked() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	root := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Root")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &root.ID, "Reply")
+
+	locked, err := suite.storage.SetThreadLocked(root.ID, true, nil)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), locked.IsLocked)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &reply.ID, Text: "deep reply"})
+	assert.ErrorIs(suite.T(), err, storage.ErrThreadLocked)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &root.ID, Text: "reply"})
+	assert.ErrorIs(suite.T(), err, storage.ErrThreadLocked)
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Other root")
+
+	_, err = suite.storage.SetThreadLocked(root.ID, false, nil)
+	require.NoError(suite.T(), err)
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &reply.ID, "deep reply")
+}
+
+// Медленный режим: один комментарий пользователя к посту за интервал
+func (suite *Suite) TestAddComment_SlowMode() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	updated, err := suite.storage.SetSlowMode(post.ID, 60, nil)
+	require.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 60, updated.SlowModeSeconds)
+
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, AuthorID: "alice", Text: "first"})
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), comment.AuthorID)
+	assert.Equal(suite.T(), "alice", *comment.AuthorID)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment.ID, AuthorID: "alice", Text: "second"})
+	var slowMode *storage.SlowModeError
+	require.ErrorAs(suite.T(), err, &slowMode)
+	assert.EqualValues(suite.T(), 60, slowMode.Seconds)
+	assert.True(suite.T(), slowMode.RetryAfter > 0 && slowMode.RetryAfter <= time.Minute)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, AuthorID: "bob", Text: "bob"})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "anonymous"})
+	assert.ErrorIs(suite.T(), err, storage.ErrAnonymous)
+	assert.Contains(suite.T(), err.Error(), "authentication required")
+
+	_, err = suite.storage.SetSlowMode(post.ID, 0, nil)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, AuthorID: "alice", Text: "second"})
+	require.NoError(suite.T(), err)
+}
+
+// Правка комментария сохраняет версии отдельно от версий поста
+func (suite *Suite) TestEditComment_Revisions() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "original")
+
+	edited, err := suite.storage.EditComment(comment.ID, "editor", "changed", nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "changed", edited.Text)
+	require.NotNil(suite.T(), edited.EditedAt)
+
+	revisions, err := suite.storage.GetRevisions(comment.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), revisions, 2)
+	assert.Equal(suite.T(), "original", revisions[0].Text)
+	assert.Nil(suite.T(), revisions[0].EditorID)
+	assert.Equal(suite.T(), "changed", revisions[1].Text)
+
+	tree, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tree, 1)
+	assert.Equal(suite.T(), "changed", tree[0].Text)
+	assert.NotNil(suite.T(), tree[0].EditedAt)
+
+	postRevisions, err := suite.storage.GetRevisions(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), postRevisions)
+
+	_, err = suite.storage.EditComment("nonexistent-id", "editor", "text", nil)
+	require.Error(suite.T(), err)
+}
+
+// Вкл./выкл. комментарии к посту
+func (suite *Suite) TestSetCommentsEnabled() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post text", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), post.CommentsEnabled)
+
+	updatedPost, err := suite.storage.SetCommentsEnabled(post.ID, false, nil)
+
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), updatedPost.CommentsEnabled)
+
+	retrievedPost, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), retrievedPost.CommentsEnabled)
+}
+
+// Комментарии можно выключить еще у черновика, настройка сохраняется после публикации
+func (suite *Suite) TestSetCommentsEnabled_Draft() {
+	draft, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "Draft", CommentsEnabled: true, Draft: true})
+	require.NoError(suite.T(), err)
+
+	updated, err := suite.storage.SetCommentsEnabled(draft.ID, false, nil)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), updated.CommentsEnabled)
+	assert.Equal(suite.T(), model.PostStatusDraft, updated.Status)
+
+	published, err := suite.storage.PublishPost(draft.ID, nil, nil)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), published.CommentsEnabled)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: draft.ID, Text: "comment"})
+	assert.ErrorIs(suite.T(), err, storage.ErrCommentsDisabled)
+}
+
+// Вложенные комментарии
+func (suite *Suite) TestGetCommentsTree_SimpleStructure() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	// comment1 -> reply1, reply2
+	// comment2
+	// comment2
+	comment1, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Comment 1"})
+	require.NoError(suite.T(), err)
+
+	comment2, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Comment 2"})
+	require.NoError(suite.T(), err)
+
+	reply1, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment1.ID, Text: "Reply 1"})
+	require.NoError(suite.T(), err)
+
+	reply2, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment1.ID, Text: "Reply 2"})
+	require.NoError(suite.T(), err)
+
+	_ = comment2
+	_, _ = reply1, reply2
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), comments, 2) // 2 корневых комментария
+
+	assert.Equal(suite.T(), "Comment 1", comments[0].Text)
+	assert.Equal(suite.T(), "Comment 2", comments[1].Text)
+
+	//проверяем, ответы у comment1
+	require.NotNil(suite.T(), comments[0].Replies)
+	assert.Len(suite.T(), comments[0].Replies, 2)
+
+	replies := comments[0].Replies
+	assert.Equal(suite.T(), "Reply 1", replies[0].Text)
+	assert.Equal(suite.T(), "Reply 2", replies[1].Text)
+	assert.Equal(suite.T(), comment1.ID, *replies[0].ParentID)
+	assert.Equal(suite.T(), comment1.ID, *replies[1].ParentID)
+
+	// проверяем, что у comment2 нет ответов
+	if comments[1].Replies != nil {
+		assert.Len(suite.T(), comments[1].Replies, 0)
+	}
+}
+
+// Счетчики комментариев поста и ответов
+func (suite *Suite) TestCommentCounters() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	// comment1 -> reply1 -> reply2
+	// comment2
+	comment1, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Comment 1"})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Comment 2"})
+	require.NoError(suite.T(), err)
+	reply1, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment1.ID, Text: "Reply 1"})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &reply1.ID, Text: "Reply 2"})
+	require.NoError(suite.T(), err)
+
+	retrievedPost, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(4), retrievedPost.CommentCount)
+	assert.Equal(suite.T(), int32(2), retrievedPost.RootCommentCount)
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 2)
+	assert.Equal(suite.T(), int32(1), comments[0].ReplyCount)
+	assert.Equal(suite.T(), int32(2), comments[0].DescendantCount)
+	assert.Equal(suite.T(), int32(0), comments[1].ReplyCount)
+
+	// скрытый ответ не учитывается
+	_, err = suite.storage.ModerateComment(reply1.ID, "moderator-1", model.ModerationActionHide, nil, nil)
+	require.NoError(suite.T(), err)
+
+	retrievedPost, err = suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), retrievedPost.CommentCount)
+	assert.Equal(suite.T(), int32(2), retrievedPost.RootCommentCount)
+
+	comments, err = suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(0), comments[0].ReplyCount)
+	assert.Equal(suite.T(), int32(1), comments[0].DescendantCount)
+
+	// повторное скрытие не меняет счетчики, одобрение возвращает их
+	_, err = suite.storage.ModerateComment(reply1.ID, "moderator-1", model.ModerationActionReject, nil, nil)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.ModerateComment(reply1.ID, "moderator-1", model.ModerationActionApprove, nil, nil)
+	require.NoError(suite.T(), err)
+
+	retrievedPost, err = suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(4), retrievedPost.CommentCount)
+
+	comments, err = suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), comments[0].ReplyCount)
+	assert.Equal(suite.T(), int32(2), comments[0].DescendantCount)
+}
+
+// Ответ с родителем из другого поста не создается и не меняет счетчики ни одного из постов
+func (suite *Suite) TestAddComment_ParentFromOtherPost() {
+	postA := testutils.CreateTestPost(suite.T(), suite.storage, "Post A", true)
+	postB := testutils.CreateTestPost(suite.T(), suite.storage, "Post B", true)
+	parent := testutils.CreateTestComment(suite.T(), suite.storage, postB.ID, nil, "Comment B")
+
+	_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: postA.ID, ParentID: &parent.ID, Text: "Reply"})
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+
+	retrievedA, err := suite.storage.GetPost(postA.ID)
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), retrievedA.CommentCount)
+	assert.Zero(suite.T(), retrievedA.RootCommentCount)
+
+	retrievedB, err := suite.storage.GetPost(postB.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), retrievedB.CommentCount)
+	assert.Equal(suite.T(), int32(1), retrievedB.RootCommentCount)
+
+	retrievedParent, err := suite.storage.GetComment(parent.ID)
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), retrievedParent.ReplyCount)
+	assert.Zero(suite.T(), retrievedParent.DescendantCount)
+}
@@ -0,0 +1,33 @@
+package conformance
+
+import (
+	"PostAndComment/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Общие тесты поведения storage.Storage. Каждая реализация запускает их через Run со своей фабрикой,
+// поэтому расхождение между хранилищами видно как упавший тест конкретного хранилища
+
+// Фабрика пустого хранилища для одного теста. Ресурсы (соединения, файлы) освобождаются через t.Cleanup
+type Factory func(t *testing.T) storage.Storage
+
+// Сколько ждать события подписки. Postgres опрашивает базу раз в несколько секунд
+const deliveryTimeout = 10 * time.Second
+
+type Suite struct {
+	suite.Suite
+	factory Factory
+	storage storage.Storage
+}
+
+func (suite *Suite) SetupTest() {
+	suite.storage = suite.factory(suite.T())
+}
+
+// Запуск всех тестов для хранилища из factory
+func Run(t *testing.T, factory Factory) {
+	suite.Run(t, &Suite{factory: factory})
+}
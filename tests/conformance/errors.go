@@ -0,0 +1,139 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Отсутствующий пост или комментарий - ErrNotFound во всех методах
+func (suite *Suite) TestErrors_NotFound() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	missing := "nonexistent-id"
+
+	checks := map[string]func() error{
+		"GetPost":            func() error { _, err := suite.storage.GetPost(missing); return err },
+		"GetComment":         func() error { _, err := suite.storage.GetComment(missing); return err },
+		"GetCommentsTree":    func() error { _, err := suite.storage.GetCommentsTree(missing, 10, 0, storage.TimeRange{}); return err },
+		"EditPost":           func() error { _, err := suite.storage.EditPost(missing, "", "text", nil); return err },
+		"EditComment":        func() error { _, err := suite.storage.EditComment(missing, "", "text", nil); return err },
+		"GetRevisions":       func() error { _, err := suite.storage.GetRevisions(missing); return err },
+		"PublishPost":        func() error { _, err := suite.storage.PublishPost(missing, nil, nil); return err },
+		"SetCommentsEnabled": func() error { _, err := suite.storage.SetCommentsEnabled(missing, false, nil); return err },
+		"SetCommentsPolicy": func() error {
+			_, err := suite.storage.SetCommentsPolicy(missing, storage.CommentsPolicy{MaxComments: 1}, nil)
+			return err
+		},
+		"SetCommentPinned":    func() error { _, err := suite.storage.SetCommentPinned(missing, true, nil); return err },
+		"SetThreadLocked":     func() error { _, err := suite.storage.SetThreadLocked(missing, true, nil); return err },
+		"SetSlowMode":         func() error { _, err := suite.storage.SetSlowMode(missing, 10, nil); return err },
+		"SubscribeToComments": func() error { _, _, err := suite.storage.SubscribeToComments(missing); return err },
+		"ReportComment":       func() error { _, err := suite.storage.ReportComment(missing, "user-1", "spam"); return err },
+		"ModerateComment": func() error {
+			_, err := suite.storage.ModerateComment(missing, "moderator", model.ModerationActionHide, nil, nil)
+			return err
+		},
+		"AddComment": func() error {
+			_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: missing, Text: "text"})
+			return err
+		},
+		"AddComment parent": func() error {
+			_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &missing, Text: "text"})
+			return err
+		},
+	}
+
+	for name, check := range checks {
+		err := check()
+		require.Error(suite.T(), err, name)
+		assert.ErrorIs(suite.T(), err, storage.ErrNotFound, name)
+	}
+}
+
+// Комментарий к посту с выключенными комментариями - ErrCommentsDisabled, а не ErrNotFound
+func (suite *Suite) TestErrors_CommentsDisabled() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", false)
+
+	_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "text"})
+	require.Error(suite.T(), err)
+	assert.ErrorIs(suite.T(), err, storage.ErrCommentsDisabled)
+	assert.NotErrorIs(suite.T(), err, storage.ErrNotFound)
+}
+
+// Операции, недопустимые в текущем состоянии объекта, - ErrConflict
+func (suite *Suite) TestErrors_Conflict() {
+	published := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	_, err := suite.storage.PublishPost(published.ID, nil, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+
+	draft, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "draft", CommentsEnabled: true, Draft: true})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: draft.ID, Text: "text"})
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+
+	for i := 0; i < storage.MaxPinnedComments; i++ {
+		comment := testutils.CreateTestComment(suite.T(), suite.storage, published.ID, nil, "comment")
+		_, err = suite.storage.SetCommentPinned(comment.ID, true, nil)
+		require.NoError(suite.T(), err)
+	}
+	extra := testutils.CreateTestComment(suite.T(), suite.storage, published.ID, nil, "extra")
+	_, err = suite.storage.SetCommentPinned(extra.ID, true, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+}
+
+// Повторная жалоба и повторный импорт - ErrAlreadyExists
+func (suite *Suite) TestErrors_AlreadyExists() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "comment")
+
+	_, err := suite.storage.ReportComment(comment.ID, "user-1", "spam")
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.ReportComment(comment.ID, "user-1", "spam again")
+	assert.ErrorIs(suite.T(), err, storage.ErrAlreadyExists)
+
+	exported, err := suite.storage.ExportPosts(10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), exported, 1)
+	err = suite.storage.ImportPost(exported[0])
+	assert.ErrorIs(suite.T(), err, storage.ErrAlreadyExists)
+}
+
+// Неверные параметры - ErrInvalidArgument
+func (suite *Suite) TestErrors_InvalidArgument() {
+	_, err := suite.storage.NewPost(storage.NewPostParams{Text: "post", Tags: []string{"bad tag"}})
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	_, err = suite.storage.SetCommentsPolicy(post.ID, storage.CommentsPolicy{MaxComments: -1}, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+
+	parent := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "parent")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &parent.ID, "reply")
+	_, err = suite.storage.SetCommentPinned(reply.ID, true, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+}
+
+// Закрытые комментарии и заблокированная ветка - отдельные ошибки-значения
+func (suite *Suite) TestErrors_ClosedAndLocked() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	parent := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "parent")
+
+	_, err := suite.storage.SetThreadLocked(parent.ID, true, nil)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &parent.ID, Text: "reply"})
+	assert.ErrorIs(suite.T(), err, storage.ErrThreadLocked)
+
+	_, err = suite.storage.SetCommentsPolicy(post.ID, storage.CommentsPolicy{MaxComments: 1}, nil)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "second"})
+	assert.ErrorIs(suite.T(), err, storage.ErrCommentsClosed)
+}
+
+// Ожидание нужно, чтобы время создания отличалось и порядок был однозначным
+func (suite *Suite) pause() {
+	time.Sleep(10 * time.Millisecond)
+}
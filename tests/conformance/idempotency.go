@@ -0,0 +1,111 @@
+package conformance
+
+import (
+	"PostAndComment/storage"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const idempotencyTTL = time.Hour
+
+// Повтор с тем же ключом получает запомненный ответ
+func (suite *Suite) TestIdempotency_ReplayCompleted() {
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+
+	rec, err := suite.storage.ClaimIdempotencyKey(key, "addComment", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+	require.NoError(suite.T(), suite.storage.CompleteIdempotencyKey(key, []byte(`{"id":"comment-1"}`)))
+
+	rec, err = suite.storage.ClaimIdempotencyKey(key, "addComment", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), rec)
+	assert.Equal(suite.T(), "addComment", rec.Operation)
+	assert.JSONEq(suite.T(), `{"id":"comment-1"}`, string(rec.Result))
+}
+
+// Пока первая мутация выполняется, повтор получает ErrConflict
+func (suite *Suite) TestIdempotency_InProgress() {
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+
+	_, err := suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+}
+
+// Ключ другой мутации - ErrInvalidArgument
+func (suite *Suite) TestIdempotency_OtherOperation() {
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+
+	_, err := suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.storage.CompleteIdempotencyKey(key, []byte(`{}`)))
+
+	_, err = suite.storage.ClaimIdempotencyKey(key, "addComment", idempotencyTTL)
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+}
+
+// Освобожденный ключ занимается заново, а ключ с ответом не освобождается
+func (suite *Suite) TestIdempotency_Release() {
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+
+	_, err := suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.storage.ReleaseIdempotencyKey(key))
+
+	rec, err := suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+
+	require.NoError(suite.T(), suite.storage.CompleteIdempotencyKey(key, []byte(`{}`)))
+	require.NoError(suite.T(), suite.storage.ReleaseIdempotencyKey(key))
+	rec, err = suite.storage.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), rec)
+}
+
+// Одинаковые ключи разных клиентов не пересекаются
+func (suite *Suite) TestIdempotency_Scopes() {
+	first := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+	second := storage.IdempotencyKey{Scope: "user:2", Key: "key-1"}
+
+	_, err := suite.storage.ClaimIdempotencyKey(first, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.storage.CompleteIdempotencyKey(first, []byte(`{}`)))
+
+	rec, err := suite.storage.ClaimIdempotencyKey(second, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+}
+
+// После ttl ключ занимается заново
+func (suite *Suite) TestIdempotency_Expired() {
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+	ttl := time.Millisecond
+
+	_, err := suite.storage.ClaimIdempotencyKey(key, "newPost", ttl)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.storage.CompleteIdempotencyKey(key, []byte(`{}`)))
+	time.Sleep(10 * time.Millisecond)
+
+	rec, err := suite.storage.ClaimIdempotencyKey(key, "addComment", ttl)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+}
+
+func (suite *Suite) TestIdempotency_InvalidKey() {
+	_, err := suite.storage.ClaimIdempotencyKey(storage.IdempotencyKey{Scope: "user:1"}, "newPost", idempotencyTTL)
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+
+	long := storage.IdempotencyKey{Scope: "user:1", Key: string(make([]byte, storage.MaxIdempotencyKeyLen+1))}
+	_, err = suite.storage.ClaimIdempotencyKey(long, "newPost", idempotencyTTL)
+	assert.ErrorIs(suite.T(), err, storage.ErrInvalidArgument)
+
+	// Ответ не сохраняется для незанятого ключа
+	err = suite.storage.CompleteIdempotencyKey(storage.IdempotencyKey{Scope: "user:1", Key: "free"}, []byte(`{}`))
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+}
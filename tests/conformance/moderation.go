@@ -0,0 +1,160 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"fmt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Жалоба на комментарий попадает в очередь модерации
+func (suite *Suite) TestReportComment_Queue() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Bad comment"})
+	require.NoError(suite.T(), err)
+
+	report, err := suite.storage.ReportComment(comment.ID, "user-1", "spam")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), comment.ID, report.TargetID)
+	assert.False(suite.T(), report.Resolved)
+
+	// повторная жалоба от того же пользователя
+	_, err = suite.storage.ReportComment(comment.ID, "user-1", "spam again")
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "already reported")
+
+	queue, err := suite.storage.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), queue.Total)
+	assert.Equal(suite.T(), int32(1), queue.ReportedCount)
+	assert.Equal(suite.T(), int32(0), queue.HeldCount)
+	require.Len(suite.T(), queue.Items, 1)
+	assert.Equal(suite.T(), comment.ID, queue.Items[0].Comment.ID)
+	assert.Equal(suite.T(), int32(1), queue.Items[0].ReportCount)
+}
+
+// Очередь модерации - снимок: решение модератора после запроса не меняет выданные комментарий и жалобы
+func (suite *Suite) TestGetModerationQueue_Snapshot() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Bad comment")
+	_, err := suite.storage.ReportComment(comment.ID, "user-1", "spam")
+	require.NoError(suite.T(), err)
+
+	queue, err := suite.storage.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), queue.Items, 1)
+	item := queue.Items[0]
+
+	_, err = suite.storage.ModerateComment(comment.ID, "moderator-1", model.ModerationActionHide, nil, nil)
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), model.ModerationStatusVisible, item.Comment.Status)
+	assert.Equal(suite.T(), storage.InitialVersion, item.Comment.Version)
+	require.Len(suite.T(), item.Reports, 1)
+	assert.False(suite.T(), item.Reports[0].Resolved)
+}
+
+// Жалоба на несуществующий комментарий
+func (suite *Suite) TestReportComment_NotFound() {
+	_, err := suite.storage.ReportComment("nonexistent-comment", "user-1", "spam")
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+}
+
+// После нескольких жалоб комментарий скрывается до решения модератора
+func (suite *Suite) TestReportComment_HoldAfterThreshold() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Bad comment"})
+	require.NoError(suite.T(), err)
+
+	for i := 0; i < storage.ReportsToHold; i++ {
+		_, err := suite.storage.ReportComment(comment.ID, fmt.Sprintf("user-%d", i), "spam")
+		require.NoError(suite.T(), err)
+	}
+
+	queue, err := suite.storage.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), queue.HeldCount)
+	require.Len(suite.T(), queue.Items, 1)
+	assert.Equal(suite.T(), model.ModerationStatusHeld, queue.Items[0].Comment.Status)
+	assert.Equal(suite.T(), "Bad comment", queue.Items[0].Comment.Text)
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 1)
+	assert.NotEqual(suite.T(), "Bad comment", comments[0].Text)
+}
+
+// Скрытый модератором комментарий отображается заглушкой, ответы сохраняются
+func (suite *Suite) TestModerateComment_Hide() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Bad comment"})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment.ID, Text: "Reply"})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.ReportComment(comment.ID, "user-1", "spam")
+	require.NoError(suite.T(), err)
+
+	reason := "offensive"
+	moderated, err := suite.storage.ModerateComment(comment.ID, "moderator-1", model.ModerationActionHide, &reason, nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), model.ModerationStatusHidden, moderated.Status)
+	assert.NotEqual(suite.T(), "Bad comment", moderated.Text)
+
+	// Результат - копия: его изменение не затрагивает хранилище
+	moderated.Status = model.ModerationStatusVisible
+	moderated.Text = "Changed"
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 1)
+	assert.Equal(suite.T(), model.ModerationStatusHidden, comments[0].Status)
+	assert.NotEqual(suite.T(), "Bad comment", comments[0].Text)
+	require.Len(suite.T(), comments[0].Replies, 1)
+	assert.Equal(suite.T(), "Reply", comments[0].Replies[0].Text)
+
+	// жалобы закрыты решением модератора
+	queue, err := suite.storage.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(0), queue.Total)
+	assert.Len(suite.T(), queue.Items, 0)
+
+	decisions, err := suite.storage.GetModerationLog(comment.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), decisions, 1)
+	assert.Equal(suite.T(), "moderator-1", decisions[0].ModeratorID)
+	assert.Equal(suite.T(), model.ModerationActionHide, decisions[0].Action)
+	assert.Equal(suite.T(), &reason, decisions[0].Reason)
+}
+
+// Одобрение возвращает скрытый комментарий
+func (suite *Suite) TestModerateComment_Approve() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	comment, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Fine comment"})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.storage.ModerateComment(comment.ID, "moderator-1", model.ModerationActionReject, nil, nil)
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.ModerateComment(comment.ID, "moderator-2", model.ModerationActionApprove, nil, nil)
+	require.NoError(suite.T(), err)
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), comments, 1)
+	assert.Equal(suite.T(), "Fine comment", comments[0].Text)
+	assert.Equal(suite.T(), model.ModerationStatusVisible, comments[0].Status)
+
+	decisions, err := suite.storage.GetModerationLog(comment.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), decisions, 2)
+	assert.Equal(suite.T(), model.ModerationActionReject, decisions[0].Action)
+	assert.Equal(suite.T(), model.ModerationActionApprove, decisions[1].Action)
+}
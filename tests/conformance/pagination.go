@@ -0,0 +1,167 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"fmt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Нулевой limit и offset за концом списка дают пустую страницу, а не ошибку
+func (suite *Suite) TestPagination_Bounds() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "@alice comment")
+	_, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "draft", Draft: true})
+	require.NoError(suite.T(), err)
+
+	posts, err := suite.storage.GetPosts(0, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), posts)
+	posts, err = suite.storage.GetPosts(10, 5, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), posts)
+
+	comments, err := suite.storage.GetCommentsTree(post.ID, 0, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), comments)
+	comments, err = suite.storage.GetCommentsTree(post.ID, 10, 5, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), comments)
+
+	notifications, err := suite.storage.GetNotifications("alice", 0, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), notifications)
+	notifications, err = suite.storage.GetNotifications("alice", 10, 5)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), notifications)
+
+	drafts, err := suite.storage.GetDrafts("author", 0, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), drafts)
+	drafts, err = suite.storage.GetDrafts("author", 10, 5)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), drafts)
+
+	exported, err := suite.storage.ExportPosts(10, 5)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), exported)
+}
+
+// Уведомления идут новыми первыми, страницы не пересекаются
+func (suite *Suite) TestGetNotifications_Pagination() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	var comments []*model.Comment
+	for i := 0; i < 5; i++ {
+		comments = append(comments, testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, fmt.Sprintf("@alice comment %d", i)))
+		suite.pause()
+	}
+
+	first, err := suite.storage.GetNotifications("alice", 3, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), first, 3)
+	second, err := suite.storage.GetNotifications("alice", 3, 3)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), second, 2)
+
+	all := append(first, second...)
+	for i, notification := range all {
+		require.NotNil(suite.T(), notification.CommentID)
+		assert.Equal(suite.T(), comments[len(comments)-1-i].ID, *notification.CommentID)
+	}
+}
+
+// Черновики автора идут новыми первыми, страницы не пересекаются
+func (suite *Suite) TestGetDrafts_Pagination() {
+	var drafts []*model.Post
+	for i := 0; i < 5; i++ {
+		draft, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: fmt.Sprintf("draft %d", i), Draft: true})
+		require.NoError(suite.T(), err)
+		drafts = append(drafts, draft)
+		suite.pause()
+	}
+
+	first, err := suite.storage.GetDrafts("author", 2, 0)
+	require.NoError(suite.T(), err)
+	second, err := suite.storage.GetDrafts("author", 2, 2)
+	require.NoError(suite.T(), err)
+	third, err := suite.storage.GetDrafts("author", 2, 4)
+	require.NoError(suite.T(), err)
+
+	all := append(append(first, second...), third...)
+	require.Len(suite.T(), all, 5)
+	for i, draft := range all {
+		assert.Equal(suite.T(), drafts[len(drafts)-1-i].ID, draft.ID)
+	}
+}
+
+// Страницы очереди модерации не пересекаются, а счетчики считаются по всей очереди
+func (suite *Suite) TestGetModerationQueue_Pagination() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	for i := 0; i < 3; i++ {
+		comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, fmt.Sprintf("comment %d", i))
+		_, err := suite.storage.ReportComment(comment.ID, "user-1", "spam")
+		require.NoError(suite.T(), err)
+		suite.pause()
+	}
+
+	first, err := suite.storage.GetModerationQueue(2, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), first.Total)
+	require.Len(suite.T(), first.Items, 2)
+
+	second, err := suite.storage.GetModerationQueue(2, 2)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), second.Total)
+	require.Len(suite.T(), second.Items, 1)
+
+	seen := map[string]bool{}
+	for _, item := range append(first.Items, second.Items...) {
+		assert.False(suite.T(), seen[item.Comment.ID])
+		seen[item.Comment.ID] = true
+	}
+
+	empty, err := suite.storage.GetModerationQueue(2, 10)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), empty.Total)
+	assert.Empty(suite.T(), empty.Items)
+}
+
+// Комментарий без ответов по ID
+func (suite *Suite) TestGetComment() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	parent := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "parent")
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &parent.ID, "reply")
+
+	comment, err := suite.storage.GetComment(parent.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), parent.ID, comment.ID)
+	assert.Equal(suite.T(), post.ID, comment.PostID)
+	assert.Equal(suite.T(), "parent", comment.Text)
+	assert.Nil(suite.T(), comment.ParentID)
+	assert.Empty(suite.T(), comment.Replies)
+}
+
+// Деревья комментариев нескольких постов; несуществующие посты в результат не попадают
+func (suite *Suite) TestGetCommentsTrees() {
+	first := testutils.CreateTestPost(suite.T(), suite.storage, "First", true)
+	second := testutils.CreateTestPost(suite.T(), suite.storage, "Second", true)
+	empty := testutils.CreateTestPost(suite.T(), suite.storage, "Empty", true)
+	firstComment := testutils.CreateTestComment(suite.T(), suite.storage, first.ID, nil, "first")
+	secondComment := testutils.CreateTestComment(suite.T(), suite.storage, second.ID, nil, "second")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, second.ID, &secondComment.ID, "reply")
+
+	trees, err := suite.storage.GetCommentsTrees([]string{first.ID, second.ID, empty.ID, "nonexistent-id"}, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.NotContains(suite.T(), trees, "nonexistent-id")
+
+	require.Len(suite.T(), trees[first.ID], 1)
+	assert.Equal(suite.T(), firstComment.ID, trees[first.ID][0].ID)
+	require.Len(suite.T(), trees[second.ID], 1)
+	assert.Equal(suite.T(), secondComment.ID, trees[second.ID][0].ID)
+	require.Len(suite.T(), trees[second.ID][0].Replies, 1)
+	assert.Equal(suite.T(), reply.ID, trees[second.ID][0].Replies[0].ID)
+	assert.Empty(suite.T(), trees[empty.ID])
+}
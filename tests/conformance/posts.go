@@ -0,0 +1,400 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Создание поста
+func (suite *Suite) TestNewPost_Success() {
+	text := "Test post text"
+	commentsEnabled := true
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: text, CommentsEnabled: commentsEnabled})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), text, post.Text)
+	assert.Equal(suite.T(), commentsEnabled, post.CommentsEnabled)
+	assert.NotEmpty(suite.T(), post.ID)
+	assert.NotEmpty(suite.T(), post.CreatedAt)
+
+	retrievedPost, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	testutils.AssertPostEqual(suite.T(), post, retrievedPost)
+}
+
+// Создание пустого поста
+func (suite *Suite) TestNewPost_EmptyText() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "", CommentsEnabled: true})
+
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), post.Text)
+	assert.True(suite.T(), post.CommentsEnabled)
+}
+
+// Получение постов
+func (suite *Suite) TestGetPost_Success() {
+	originalPost, err := suite.storage.NewPost(storage.NewPostParams{Text: "Post text", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	retrievedPost, err := suite.storage.GetPost(originalPost.ID)
+
+	require.NoError(suite.T(), err)
+	testutils.AssertPostEqual(suite.T(), originalPost, retrievedPost)
+}
+
+// Поиск нессуществующего поста
+func (suite *Suite) TestGetPost_NotFound() {
+	_, err := suite.storage.GetPost("aboba")
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+}
+
+// Запрос постов при пустом хранилище: пустой список, а не nil
+func (suite *Suite) TestGetPosts_EmptyStorage() {
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+
+	require.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), posts)
+	assert.Len(suite.T(), posts, 0)
+}
+
+// Запрос постов с пагинацией
+func (suite *Suite) TestGetPosts_WithPagination() {
+	// создаем 5 постов
+	createdPosts := make([]*model.Post, 5)
+	for i := 0; i < 5; i++ {
+		post, err := suite.storage.NewPost(storage.NewPostParams{Text: fmt.Sprintf("Post %d", i+1), CommentsEnabled: true})
+		require.NoError(suite.T(), err)
+		createdPosts[i] = post
+
+		time.Sleep(10 * time.Millisecond) // задержка для разного времени
+	}
+
+	// получаем первые 3 поста
+	retrievedPosts, err := suite.storage.GetPosts(3, 0, storage.TimeRange{})
+
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), retrievedPosts, 3)
+
+	// проверяем порядок
+	assert.Equal(suite.T(), "Post 5", retrievedPosts[0].Text)
+	assert.Equal(suite.T(), "Post 4", retrievedPosts[1].Text)
+	assert.Equal(suite.T(), "Post 3", retrievedPosts[2].Text)
+
+	// получаем следующие посты с offset
+	remainingPosts, err := suite.storage.GetPosts(3, 3, storage.TimeRange{})
+
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), remainingPosts, 2)
+	assert.Equal(suite.T(), "Post 2", remainingPosts[0].Text)
+	assert.Equal(suite.T(), "Post 1", remainingPosts[1].Text)
+}
+
+// Фильтр постов по времени создания
+func (suite *Suite) TestGetPosts_CreatedRange() {
+	first := testutils.CreateTestPost(suite.T(), suite.storage, "Post 1", true)
+	second := testutils.CreateTestPost(suite.T(), suite.storage, "Post 2", true)
+	third := testutils.CreateTestPost(suite.T(), suite.storage, "Post 3", true)
+
+	// границы не включаются
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{After: &first.CreatedAt, Before: &third.CreatedAt})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), posts, 1)
+	assert.Equal(suite.T(), second.ID, posts[0].ID)
+
+	posts, err = suite.storage.GetPosts(10, 0, storage.TimeRange{After: &first.CreatedAt})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), posts, 2)
+	assert.Equal(suite.T(), third.ID, posts[0].ID)
+	assert.Equal(suite.T(), second.ID, posts[1].ID)
+
+	// offset применяется после фильтра
+	posts, err = suite.storage.GetPosts(10, 1, storage.TimeRange{Before: &third.CreatedAt})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), posts, 1)
+	assert.Equal(suite.T(), first.ID, posts[0].ID)
+}
+
+// Пост с заголовком и тегами
+func (suite *Suite) TestNewPost_TitleAndTags() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{
+		Title:           "Привет, GraphQL!",
+		Text:            "Post text",
+		Tags:            []string{"#Go", "graphql", "go"},
+		CommentsEnabled: true,
+	})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Привет, GraphQL!", post.Title)
+	assert.Equal(suite.T(), []string{"go", "graphql"}, post.Tags)
+	assert.Equal(suite.T(), "привет-graphql-"+post.ID[:8], post.Slug)
+
+	retrieved, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.Title, retrieved.Title)
+	assert.Equal(suite.T(), post.Slug, retrieved.Slug)
+	assert.Equal(suite.T(), post.Tags, retrieved.Tags)
+}
+
+// Недопустимый тег
+func (suite *Suite) TestNewPost_InvalidTag() {
+	_, err := suite.storage.NewPost(storage.NewPostParams{Text: "Post text", Tags: []string{"two words"}})
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "is invalid")
+}
+
+// Лента по тегу с курсором
+func (suite *Suite) TestGetPostsByTag_Cursor() {
+	posts := make([]*model.Post, 3)
+	for i := range posts {
+		post, err := suite.storage.NewPost(storage.NewPostParams{Text: fmt.Sprintf("Post %d", i+1), Tags: []string{"go"}})
+		require.NoError(suite.T(), err)
+		posts[i] = post
+	}
+	_, err := suite.storage.NewPost(storage.NewPostParams{Text: "Other", Tags: []string{"rust"}})
+	require.NoError(suite.T(), err)
+
+	page, err := suite.storage.GetPostsByTag("#Go", 2, nil)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page, 2)
+	assert.Equal(suite.T(), posts[2].ID, page[0].ID)
+	assert.Equal(suite.T(), posts[1].ID, page[1].ID)
+
+	cursor := storage.CursorFor(page[1])
+	page, err = suite.storage.GetPostsByTag("go", 2, &cursor)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page, 1)
+	assert.Equal(suite.T(), posts[0].ID, page[0].ID)
+
+	page, err = suite.storage.GetPostsByTag("unknown", 2, nil)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), page)
+}
+
+// Подсказки тегов по началу
+func (suite *Suite) TestGetTags_Prefix() {
+	for _, tags := range [][]string{{"go", "golang"}, {"go"}, {"graphql"}, {"rust"}} {
+		_, err := suite.storage.NewPost(storage.NewPostParams{Text: "Post", Tags: tags})
+		require.NoError(suite.T(), err)
+	}
+
+	tags, err := suite.storage.GetTags("G", 10)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []*model.Tag{
+		{Name: "go", PostCount: 2},
+		{Name: "golang", PostCount: 1},
+		{Name: "graphql", PostCount: 1},
+	}, tags)
+
+	tags, err = suite.storage.GetTags("", 1)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []*model.Tag{{Name: "go", PostCount: 2}}, tags)
+}
+
+// Упоминание в посте
+func (suite *Suite) TestNewPost_Mentions() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "cc @alice #news", true)
+
+	retrieved, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []*model.Mention{{Username: "alice", Offset: 3, Length: 6}}, retrieved.Mentions)
+	assert.Equal(suite.T(), []*model.Hashtag{{Tag: "news", Offset: 10, Length: 5}}, retrieved.Hashtags)
+
+	notifications, err := suite.storage.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), notifications, 1)
+	assert.Equal(suite.T(), post.ID, notifications[0].PostID)
+	assert.Nil(suite.T(), notifications[0].CommentID)
+}
+
+// Черновик не виден в ленте, тегах и не комментируется до публикации
+func (suite *Suite) TestNewPost_Draft() {
+	draft, err := suite.storage.NewPost(storage.NewPostParams{
+		AuthorID: "author", Text: "draft @alice", Tags: []string{"go"}, CommentsEnabled: true, Draft: true,
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), model.PostStatusDraft, draft.Status)
+	assert.Nil(suite.T(), draft.PublishAt)
+
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), posts)
+	tagged, err := suite.storage.GetPostsByTag("go", 10, nil)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), tagged)
+	tags, err := suite.storage.GetTags("", 10)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), tags)
+
+	_, err = suite.storage.AddComment(storage.NewCommentParams{PostID: draft.ID, Text: "comment"})
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not published")
+
+	drafts, err := suite.storage.GetDrafts("author", 10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), drafts, 1)
+	assert.Equal(suite.T(), draft.ID, drafts[0].ID)
+	drafts, err = suite.storage.GetDrafts("stranger", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), drafts)
+
+	// Упомянутые узнают о посте только после публикации
+	notifications, err := suite.storage.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), notifications)
+
+	published, err := suite.storage.PublishPost(draft.ID, nil, nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), model.PostStatusPublished, published.Status)
+	assert.False(suite.T(), published.CreatedAt.Before(draft.CreatedAt))
+
+	posts, err = suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), posts, 1)
+	assert.Equal(suite.T(), draft.ID, posts[0].ID)
+	drafts, err = suite.storage.GetDrafts("author", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), drafts)
+	notifications, err = suite.storage.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), notifications, 1)
+
+	_, err = suite.storage.PublishPost(draft.ID, nil, nil)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "already published")
+}
+
+// Запланированный пост публикуется, когда наступает publishAt, и попадает в начало ленты
+func (suite *Suite) TestPublishDuePosts() {
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	scheduled, err := suite.storage.NewPost(storage.NewPostParams{
+		AuthorID: "author", Text: "scheduled", CommentsEnabled: true, PublishAt: &publishAt,
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), model.PostStatusScheduled, scheduled.Status)
+	require.NotNil(suite.T(), scheduled.PublishAt)
+	assert.True(suite.T(), publishAt.Equal(*scheduled.PublishAt))
+	older := testutils.CreateTestPost(suite.T(), suite.storage, "older", true)
+
+	published, err := suite.storage.PublishDuePosts(time.Now())
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), published)
+
+	published, err = suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), published, 1)
+	assert.Equal(suite.T(), scheduled.ID, published[0].ID)
+	assert.Equal(suite.T(), model.PostStatusPublished, published[0].Status)
+	assert.Nil(suite.T(), published[0].PublishAt)
+	// Время публикации - момент, на который планировщик отобрал посты
+	assert.True(suite.T(), publishAt.Equal(published[0].CreatedAt), published[0].CreatedAt)
+	assert.Equal(suite.T(), int32(2), published[0].Version)
+
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), posts, 2)
+	assert.Equal(suite.T(), scheduled.ID, posts[0].ID)
+	assert.Equal(suite.T(), older.ID, posts[1].ID)
+
+	// Повторный запуск ничего не публикует
+	published, err = suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), published)
+}
+
+// Правка поста: исходный текст сохраняется первой версией, новые тексты - следующими
+func (suite *Suite) TestEditPost_Revisions() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "first text", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	revisions, err := suite.storage.GetRevisions(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), revisions)
+
+	edited, err := suite.storage.EditPost(post.ID, "author", "second text @bob", nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "second text @bob", edited.Text)
+	require.NotNil(suite.T(), edited.EditedAt)
+	require.Len(suite.T(), edited.Mentions, 1)
+
+	_, err = suite.storage.EditPost(post.ID, "moderator", "third text", nil)
+	require.NoError(suite.T(), err)
+
+	revisions, err = suite.storage.GetRevisions(post.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), revisions, 3)
+	for i, text := range []string{"first text", "second text @bob", "third text"} {
+		assert.EqualValues(suite.T(), i+1, revisions[i].Number)
+		assert.Equal(suite.T(), text, revisions[i].Text)
+	}
+	assert.Equal(suite.T(), "author", *revisions[0].EditorID)
+	assert.True(suite.T(), revisions[0].CreatedAt.Equal(post.CreatedAt))
+	assert.Equal(suite.T(), "moderator", *revisions[2].EditorID)
+
+	retrieved, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "third text", retrieved.Text)
+
+	_, err = suite.storage.EditPost("nonexistent-id", "author", "text", nil)
+	require.Error(suite.T(), err)
+	_, err = suite.storage.GetRevisions("nonexistent-id")
+	require.Error(suite.T(), err)
+}
+
+// Выгрузка и загрузка поста сохраняют ID, связи, время и пересчитывают счетчики
+func (suite *Suite) TestExportImportPost() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Title: "Title", Text: "Post text",
+		Tags: []string{"go"}, CommentsEnabled: true, CommentsPolicy: storage.CommentsPolicy{CloseAfterDays: 7}})
+	require.NoError(suite.T(), err)
+	root := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Root")
+	reply := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &root.ID, "Reply")
+	_, err = suite.storage.ModerateComment(reply.ID, "moderator", model.ModerationActionHide, nil, nil)
+	require.NoError(suite.T(), err)
+
+	exported, err := suite.storage.ExportPosts(10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), exported, 1)
+	assert.Equal(suite.T(), storage.CommentsPolicy{CloseAfterDays: 7}, exported[0].Policy)
+	require.Len(suite.T(), exported[0].Comments, 2)
+	assert.Equal(suite.T(), "Reply", exported[0].Comments[1].Text, "export keeps the text hidden by moderation")
+
+	err = suite.storage.ImportPost(exported[0])
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "already exists")
+
+	// Загрузка под новыми ID, чтобы проверить результат в том же хранилище
+	exported[0].Post.ID = "imported-post"
+	exported[0].Comments[0].ID = "imported-root"
+	exported[0].Comments[1].ID = "imported-reply"
+	exported[0].Comments[1].ParentID = &exported[0].Comments[0].ID
+	require.NoError(suite.T(), suite.storage.ImportPost(exported[0]))
+
+	imported, err := suite.storage.GetPost("imported-post")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), post.CreatedAt.Equal(imported.CreatedAt))
+	assert.Equal(suite.T(), post.Slug, imported.Slug)
+	assert.Equal(suite.T(), []string{"go"}, imported.Tags)
+	assert.EqualValues(suite.T(), 1, imported.CommentCount)
+	require.NotNil(suite.T(), imported.CommentsCloseAt)
+
+	tree, err := suite.storage.GetCommentsTree("imported-post", 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tree, 1)
+	assert.True(suite.T(), root.CreatedAt.Equal(tree[0].CreatedAt))
+	require.Len(suite.T(), tree[0].Replies, 1)
+	assert.Equal(suite.T(), "imported-reply", tree[0].Replies[0].ID)
+	assert.Equal(suite.T(), model.ModerationStatusHidden, tree[0].Replies[0].Status)
+	assert.EqualValues(suite.T(), 0, tree[0].ReplyCount)
+
+	posts, err := suite.storage.GetPostsByTag("go", 10, nil)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 2)
+}
@@ -0,0 +1,232 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Хранилище, ограниченное пространством id
+func (suite *Suite) inSpace(id string) storage.Storage {
+	return storage.ForContext(storage.WithSpace(context.Background(), id), suite.storage)
+}
+
+// Посты и комментарии одного пространства не видны из другого
+func (suite *Suite) TestSpaces_ReadIsolation() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+
+	post, err := docs.NewPost(storage.NewPostParams{AuthorID: "author", Title: "Guide", Text: "about #golang", Tags: []string{"golang"}, CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	comment := testutils.CreateTestComment(suite.T(), docs, post.ID, nil, "Comment")
+	_, err = docs.NewPost(storage.NewPostParams{AuthorID: "author", Text: "Draft", Draft: true})
+	require.NoError(suite.T(), err)
+
+	_, err = blog.GetPost(post.ID)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.GetComment(comment.ID)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+
+	posts, err := blog.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), posts)
+	trees, err := blog.GetCommentsTrees([]string{post.ID}, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), trees)
+	tagged, err := blog.GetPostsByTag("golang", 10, nil)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), tagged)
+	tags, err := blog.GetTags("", 10)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), tags)
+	drafts, err := blog.GetDrafts("author", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), drafts)
+	exported, err := blog.ExportPosts(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), exported)
+
+	// Пространство по умолчанию - такое же отдельное пространство
+	posts, err = suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), posts)
+
+	stored, err := docs.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.ID, stored.ID)
+	posts, err = docs.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 1)
+}
+
+// Изменения чужих постов и комментариев выглядят как изменения несуществующих
+func (suite *Suite) TestSpaces_WriteIsolation() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+
+	post := testutils.CreateTestPost(suite.T(), docs, "Post", true)
+	comment := testutils.CreateTestComment(suite.T(), docs, post.ID, nil, "Comment")
+
+	_, err := blog.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "Intruder"})
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &comment.ID, Text: "Intruder"})
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.EditPost(post.ID, "", "Edited", nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.EditComment(comment.ID, "", "Edited", nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.SetCommentsEnabled(post.ID, false, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.SetSlowMode(post.ID, 10, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.PublishPost(post.ID, nil, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.SetCommentPinned(comment.ID, true, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.SetThreadLocked(comment.ID, true, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.ReportComment(comment.ID, "user-1", "spam")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+	_, err = blog.ModerateComment(comment.ID, "moderator", model.ModerationActionHide, nil, nil)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+
+	stored, err := docs.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Post", stored.Text)
+	assert.True(suite.T(), stored.CommentsEnabled)
+	assert.Equal(suite.T(), storage.InitialVersion, stored.Version)
+	assert.Equal(suite.T(), int32(1), stored.CommentCount)
+}
+
+// Уведомления и очередь модерации у каждого пространства свои
+func (suite *Suite) TestSpaces_NotificationsAndModeration() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+
+	post := testutils.CreateTestPost(suite.T(), docs, "Post", true)
+	comment := testutils.CreateTestComment(suite.T(), docs, post.ID, nil, "@alice look")
+	_, err := docs.ReportComment(comment.ID, "user-1", "spam")
+	require.NoError(suite.T(), err)
+
+	notifications, err := docs.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), notifications, 1)
+	notifications, err = blog.GetNotifications("alice", 10, 0)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), notifications)
+
+	queue, err := docs.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(1), queue.Total)
+	queue, err = blog.GetModerationQueue(10, 0)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(0), queue.Total)
+	assert.Empty(suite.T(), queue.Items)
+}
+
+// Подписчик пространства не получает уведомления и посты других пространств
+func (suite *Suite) TestSpaces_Subscriptions() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+
+	notifications, unsubscribe, err := blog.SubscribeToNotifications("alice")
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+	posts, unsubscribePosts, err := blog.SubscribeToPosts()
+	require.NoError(suite.T(), err)
+	defer (*unsubscribePosts)()
+
+	publishAt := time.Now().Add(time.Minute)
+	_, err = docs.NewPost(storage.NewPostParams{AuthorID: "author", Text: "soon", PublishAt: &publishAt})
+	require.NoError(suite.T(), err)
+	post := testutils.CreateTestPost(suite.T(), docs, "Post", true)
+	testutils.CreateTestComment(suite.T(), docs, post.ID, nil, "@alice look")
+	_, err = suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+
+	// Событие своего пространства показывает, что чужие уже были бы доставлены
+	own, err := blog.NewPost(storage.NewPostParams{AuthorID: "author", Text: "own", PublishAt: &publishAt})
+	require.NoError(suite.T(), err)
+	_, err = suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+
+	select {
+	case published := <-posts:
+		assert.Equal(suite.T(), own.ID, published.ID)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("post was not delivered")
+	}
+	select {
+	case notification := <-notifications:
+		suite.T().Fatalf("notification %s delivered to another space", notification.ID)
+	default:
+	}
+}
+
+// Планировщик публикует посты всех пространств
+func (suite *Suite) TestSpaces_PublishDuePosts() {
+	publishAt := time.Now().Add(time.Minute)
+	ids := map[string]string{}
+	for _, space := range []string{"docs", "blog"} {
+		post, err := suite.inSpace(space).NewPost(storage.NewPostParams{AuthorID: "author", Text: space, PublishAt: &publishAt})
+		require.NoError(suite.T(), err)
+		ids[space] = post.ID
+	}
+
+	published, err := suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), published, 2)
+
+	for space, id := range ids {
+		post, err := suite.inSpace(space).GetPost(id)
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), model.PostStatusPublished, post.Status)
+	}
+}
+
+// Одинаковые ключи идемпотентности в разных пространствах не пересекаются
+func (suite *Suite) TestSpaces_Idempotency() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+	key := storage.IdempotencyKey{Scope: "user:1", Key: "key-1"}
+
+	_, err := docs.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), docs.CompleteIdempotencyKey(key, []byte(`{}`)))
+
+	rec, err := blog.ClaimIdempotencyKey(key, "addComment", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+
+	rec, err = docs.ClaimIdempotencyKey(key, "newPost", idempotencyTTL)
+	require.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), rec)
+}
+
+// Импортированный пост попадает в пространство импорта. ID уникальны на всем сервере,
+// поэтому пост с ID из другого пространства не импортируется
+func (suite *Suite) TestSpaces_Import() {
+	docs, blog := suite.inSpace("docs"), suite.inSpace("blog")
+
+	post := testutils.CreateTestPost(suite.T(), docs, "Post", true)
+	testutils.CreateTestComment(suite.T(), docs, post.ID, nil, "Comment")
+	exported, err := docs.ExportPosts(10, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), exported, 1)
+
+	err = blog.ImportPost(exported[0])
+	assert.ErrorIs(suite.T(), err, storage.ErrAlreadyExists)
+
+	imported := *exported[0]
+	importedPost := *imported.Post
+	importedPost.ID = "imported-post"
+	imported.Post = &importedPost
+	imported.Comments = nil
+	require.NoError(suite.T(), blog.ImportPost(&imported))
+
+	stored, err := blog.GetPost(importedPost.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Post", stored.Text)
+	_, err = docs.GetPost(importedPost.ID)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+}
@@ -0,0 +1,170 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Уведомление приходит подписчику
+func (suite *Suite) TestSubscribeToNotifications() {
+	ch, unsubscribe, err := suite.storage.SubscribeToNotifications("alice")
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "@alice look")
+
+	select {
+	case notification := <-ch:
+		require.NotNil(suite.T(), notification.CommentID)
+		assert.Equal(suite.T(), comment.ID, *notification.CommentID)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("notification was not delivered")
+	}
+}
+
+// Подписчик получает пост в момент публикации
+func (suite *Suite) TestSubscribeToPosts() {
+	ch, unsubscribe, err := suite.storage.SubscribeToPosts()
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+
+	publishAt := time.Now().Add(time.Minute).Truncate(time.Microsecond)
+	post, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "soon", PublishAt: &publishAt})
+	require.NoError(suite.T(), err)
+
+	select {
+	case <-ch:
+		suite.T().Fatal("scheduled post delivered before publication")
+	default:
+	}
+
+	_, err = suite.storage.PublishDuePosts(publishAt)
+	require.NoError(suite.T(), err)
+
+	select {
+	case published := <-ch:
+		assert.Equal(suite.T(), post.ID, published.ID)
+		assert.Equal(suite.T(), model.PostStatusPublished, published.Status)
+		assert.True(suite.T(), publishAt.Equal(published.CreatedAt), published.CreatedAt)
+		assert.Equal(suite.T(), int32(2), published.Version)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("post was not delivered")
+	}
+}
+
+// Получение комментария по подписке
+func (suite *Suite) TestSubscribeToComments() {
+	post, err := suite.storage.NewPost(storage.NewPostParams{Text: "Test post", CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+
+	ch, unsubscribe, err := suite.storage.SubscribeToComments(post.ID)
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+
+	// добавляем комментарий
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, err := suite.storage.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "New comment"})
+		require.NoError(suite.T(), err)
+	}()
+
+	//ждем комментарий через подписку
+	select {
+	case comment := <-ch:
+		assert.Equal(suite.T(), post.ID, comment.PostID)
+		assert.Equal(suite.T(), "New comment", comment.Text)
+		suite.T().Log("Successfully received comment by subscription")
+	case <-time.After(deliveryTimeout):
+		suite.T().Error("Expected to receive comment by subscription")
+	}
+}
+
+// Подписка на комментарии несуществующего поста
+func (suite *Suite) TestSubscribeToComments_NonexistentPost() {
+	_, _, err := suite.storage.SubscribeToComments("nonexistent-id")
+
+	require.Error(suite.T(), err)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound)
+}
+
+// Подписчик получает комментарии только своего поста
+func (suite *Suite) TestSubscribeToComments_OtherPost() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	other := testutils.CreateTestPost(suite.T(), suite.storage, "Other post", true)
+
+	ch, unsubscribe, err := suite.storage.SubscribeToComments(post.ID)
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+
+	testutils.CreateTestComment(suite.T(), suite.storage, other.ID, nil, "other")
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "mine")
+
+	select {
+	case received := <-ch:
+		assert.Equal(suite.T(), comment.ID, received.ID)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("comment was not delivered")
+	}
+}
+
+// Подписчик получает только свои уведомления
+func (suite *Suite) TestSubscribeToNotifications_OtherUser() {
+	ch, unsubscribe, err := suite.storage.SubscribeToNotifications("alice")
+	require.NoError(suite.T(), err)
+	defer (*unsubscribe)()
+
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "@bob look")
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "@alice look")
+
+	select {
+	case notification := <-ch:
+		require.NotNil(suite.T(), notification.CommentID)
+		assert.Equal(suite.T(), comment.ID, *notification.CommentID)
+		assert.Equal(suite.T(), "alice", notification.UserID)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("notification was not delivered")
+	}
+}
+
+// После отписки канал закрывается, повторная отписка безопасна
+func (suite *Suite) TestSubscribe_Unsubscribe() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Test post", true)
+
+	comments, unsubscribeComments, err := suite.storage.SubscribeToComments(post.ID)
+	require.NoError(suite.T(), err)
+	notifications, unsubscribeNotifications, err := suite.storage.SubscribeToNotifications("alice")
+	require.NoError(suite.T(), err)
+	posts, unsubscribePosts, err := suite.storage.SubscribeToPosts()
+	require.NoError(suite.T(), err)
+
+	for _, unsubscribe := range []*func(){unsubscribeComments, unsubscribeNotifications, unsubscribePosts} {
+		(*unsubscribe)()
+		(*unsubscribe)()
+	}
+
+	select {
+	case _, ok := <-comments:
+		assert.False(suite.T(), ok)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("comments channel was not closed")
+	}
+	select {
+	case _, ok := <-notifications:
+		assert.False(suite.T(), ok)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("notifications channel was not closed")
+	}
+	select {
+	case _, ok := <-posts:
+		assert.False(suite.T(), ok)
+	case <-time.After(deliveryTimeout):
+		suite.T().Fatal("posts channel was not closed")
+	}
+}
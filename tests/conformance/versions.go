@@ -0,0 +1,197 @@
+package conformance
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/tests/testutils"
+	"errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func version(v int32) *int32 { return &v }
+
+// Новые пост и комментарий начинают с версии 1, каждое изменение увеличивает ее на 1
+func (suite *Suite) TestVersions_Bump() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Post", true)
+	assert.Equal(suite.T(), storage.InitialVersion, post.Version)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Comment")
+	assert.Equal(suite.T(), storage.InitialVersion, comment.Version)
+
+	updated, err := suite.storage.SetCommentsEnabled(post.ID, false, version(1))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(2), updated.Version)
+	updated, err = suite.storage.EditPost(post.ID, "", "Edited", version(2))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), updated.Version)
+	updated, err = suite.storage.SetSlowMode(post.ID, 10, nil)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(4), updated.Version)
+
+	pinned, err := suite.storage.SetCommentPinned(comment.ID, true, version(1))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(2), pinned.Version)
+	moderated, err := suite.storage.ModerateComment(comment.ID, "moderator", model.ModerationActionHide, nil, version(2))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), moderated.Version)
+
+	stored, err := suite.storage.GetComment(comment.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(3), stored.Version)
+}
+
+// Выданный пост - снимок: последующие изменения не меняют его версию и счетчики,
+// а правка снимка не меняет хранимый пост
+func (suite *Suite) TestVersions_ReturnedPostIsSnapshot() {
+	created, err := suite.storage.NewPost(storage.NewPostParams{Text: "Post", Tags: []string{"go"}, CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	retrieved, err := suite.storage.GetPost(created.ID)
+	require.NoError(suite.T(), err)
+	listed, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), listed, 1)
+
+	_, err = suite.storage.EditPost(created.ID, "", "Edited", version(1))
+	require.NoError(suite.T(), err)
+	testutils.CreateTestComment(suite.T(), suite.storage, created.ID, nil, "Comment")
+
+	for _, post := range []*model.Post{created, retrieved, listed[0]} {
+		assert.Equal(suite.T(), storage.InitialVersion, post.Version)
+		assert.Zero(suite.T(), post.CommentCount)
+		assert.Equal(suite.T(), "Post", post.Text)
+	}
+
+	retrieved.Tags[0] = "changed"
+	retrieved.CommentsEnabled = false
+	stored, err := suite.storage.GetPost(created.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"go"}, stored.Tags)
+	assert.True(suite.T(), stored.CommentsEnabled)
+	assert.Equal(suite.T(), int32(2), stored.Version)
+	assert.Equal(suite.T(), int32(1), stored.CommentCount)
+}
+
+// Счетчики ответов и комментариев версию не меняют
+func (suite *Suite) TestVersions_CountersKeepVersion() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Post", true)
+	root := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Root")
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &root.ID, "Reply")
+
+	stored, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), storage.InitialVersion, stored.Version)
+
+	tree, err := suite.storage.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tree, 1)
+	assert.Equal(suite.T(), storage.InitialVersion, tree[0].Version)
+	assert.Equal(suite.T(), int32(1), tree[0].ReplyCount)
+}
+
+// Устаревшая версия - VersionConflictError с текущей версией, изменение не применяется
+func (suite *Suite) TestVersions_Conflict() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Post", true)
+	_, err := suite.storage.SetCommentsEnabled(post.ID, false, version(1))
+	require.NoError(suite.T(), err)
+
+	// Второй модератор видел версию 1
+	_, err = suite.storage.SetCommentsEnabled(post.ID, true, version(1))
+	require.ErrorIs(suite.T(), err, storage.ErrConflict)
+	var conflict *storage.VersionConflictError
+	require.True(suite.T(), errors.As(err, &conflict))
+	assert.Equal(suite.T(), int32(1), conflict.Expected)
+	assert.Equal(suite.T(), int32(2), conflict.Actual)
+
+	stored, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), stored.CommentsEnabled)
+	assert.Equal(suite.T(), int32(2), stored.Version)
+
+	_, err = suite.storage.EditPost(post.ID, "", "Edited", version(1))
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+	revisions, err := suite.storage.GetRevisions(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), revisions)
+}
+
+// Проверка версии у каждого метода изменения: несовпадение - конфликт, у отсутствующего объекта - ErrNotFound
+func (suite *Suite) TestVersions_AllMutations() {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Post", true)
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Comment")
+	draft, err := suite.storage.NewPost(storage.NewPostParams{Text: "Draft", Draft: true})
+	require.NoError(suite.T(), err)
+	stale := version(42)
+
+	postMutations := map[string]func(postID string) error{
+		"EditPost": func(id string) error { _, err := suite.storage.EditPost(id, "", "text", stale); return err },
+		"SetCommentsEnabled": func(id string) error {
+			_, err := suite.storage.SetCommentsEnabled(id, false, stale)
+			return err
+		},
+		"SetCommentsPolicy": func(id string) error {
+			_, err := suite.storage.SetCommentsPolicy(id, storage.CommentsPolicy{MaxComments: 1}, stale)
+			return err
+		},
+		"SetSlowMode": func(id string) error { _, err := suite.storage.SetSlowMode(id, 10, stale); return err },
+	}
+	for name, mutate := range postMutations {
+		var conflict *storage.VersionConflictError
+		err := mutate(post.ID)
+		assert.True(suite.T(), errors.As(err, &conflict), "%s: %v", name, err)
+		assert.ErrorIs(suite.T(), mutate("nonexistent-id"), storage.ErrNotFound, name)
+	}
+
+	_, err = suite.storage.PublishPost(draft.ID, nil, stale)
+	assert.ErrorIs(suite.T(), err, storage.ErrConflict)
+
+	commentMutations := map[string]func(commentID string) error{
+		"EditComment": func(id string) error { _, err := suite.storage.EditComment(id, "", "text", stale); return err },
+		"SetCommentPinned": func(id string) error {
+			_, err := suite.storage.SetCommentPinned(id, true, stale)
+			return err
+		},
+		"SetThreadLocked": func(id string) error {
+			_, err := suite.storage.SetThreadLocked(id, true, stale)
+			return err
+		},
+		"ModerateComment": func(id string) error {
+			_, err := suite.storage.ModerateComment(id, "moderator", model.ModerationActionHide, nil, stale)
+			return err
+		},
+	}
+	for name, mutate := range commentMutations {
+		var conflict *storage.VersionConflictError
+		err := mutate(comment.ID)
+		assert.True(suite.T(), errors.As(err, &conflict), "%s: %v", name, err)
+		assert.ErrorIs(suite.T(), mutate("nonexistent-id"), storage.ErrNotFound, name)
+	}
+
+	// Ни одно отклоненное изменение не тронуло объекты
+	storedPost, err := suite.storage.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), storage.InitialVersion, storedPost.Version)
+	storedComment, err := suite.storage.GetComment(comment.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), storage.InitialVersion, storedComment.Version)
+	assert.False(suite.T(), storedComment.IsPinned)
+}
+
+// Публикация черновика и скрытие по жалобам тоже меняют версию
+func (suite *Suite) TestVersions_ImplicitChanges() {
+	draft, err := suite.storage.NewPost(storage.NewPostParams{Text: "Draft", CommentsEnabled: true, Draft: true})
+	require.NoError(suite.T(), err)
+	published, err := suite.storage.PublishPost(draft.ID, nil, version(1))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(2), published.Version)
+
+	comment := testutils.CreateTestComment(suite.T(), suite.storage, published.ID, nil, "Comment")
+	for i := 0; i < storage.ReportsToHold; i++ {
+		_, err := suite.storage.ReportComment(comment.ID, string(rune('a'+i)), "spam")
+		require.NoError(suite.T(), err)
+	}
+	held, err := suite.storage.GetComment(comment.ID)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), model.ModerationStatusHeld, held.Status)
+	assert.Equal(suite.T(), int32(2), held.Version)
+}
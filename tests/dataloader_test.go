@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/storage/postgres"
+	"PostAndComment/tests/testutils"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Хранилище со счетчиками вызовов загрузки комментариев
+type countingStorage struct {
+	storage.Storage
+	treeCalls  atomic.Int64
+	treesCalls atomic.Int64
+}
+
+func (s *countingStorage) GetCommentsTree(postID string, limit, offset int32, createdIn storage.TimeRange) ([]*model.Comment, error) {
+	s.treeCalls.Add(1)
+	return s.Storage.GetCommentsTree(postID, limit, offset, createdIn)
+}
+
+func (s *countingStorage) GetCommentsTrees(postIDs []string, limit, offset int32, createdIn storage.TimeRange) (map[string][]*model.Comment, error) {
+	s.treesCalls.Add(1)
+	return s.Storage.GetCommentsTrees(postIDs, limit, offset, createdIn)
+}
+
+type feedResponse struct {
+	GetPosts []struct {
+		ID       string
+		Comments []struct {
+			Text    string
+			Replies []struct{ Text string }
+		}
+	}
+}
+
+const feedQuery = `query { getPosts(limit: 5) { id comments { text replies { text } } } }`
+
+type DataloaderTestSuite struct {
+	suite.Suite
+}
+
+// Окно пачки с запасом: на загруженной машине резолверы полей доходят до загрузчика
+// дольше, чем за окно по умолчанию, и пачка распадается на несколько вызовов
+const feedBatchWait = 200 * time.Millisecond
+
+func newFeedClient(s storage.Storage) *client.Client {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: s})))
+	srv.AddTransport(transport.POST{})
+	srv.Use(graph.Dataloaders{Storage: s, Wait: feedBatchWait})
+	return client.New(srv)
+}
+
+// Лента из 5 постов с комментариями
+func createFeed(t *testing.T, s storage.Storage) {
+	for i := 0; i < 5; i++ {
+		post := testutils.CreateTestPost(t, s, fmt.Sprintf("Post %d", i), true)
+		comment := testutils.CreateTestComment(t, s, post.ID, nil, fmt.Sprintf("Comment %d", i))
+		testutils.CreateTestComment(t, s, post.ID, &comment.ID, fmt.Sprintf("Reply %d", i))
+	}
+}
+
+func assertFeed(t *testing.T, resp feedResponse) {
+	require.Len(t, resp.GetPosts, 5)
+	for _, post := range resp.GetPosts {
+		require.Len(t, post.Comments, 1)
+		require.Len(t, post.Comments[0].Replies, 1)
+	}
+}
+
+// Комментарии всех постов ленты загружаются одним вызовом хранилища
+func (suite *DataloaderTestSuite) TestFeedPage_Memory() {
+	s := &countingStorage{Storage: memory.New()}
+	createFeed(suite.T(), s)
+
+	var resp feedResponse
+	newFeedClient(s).MustPost(feedQuery, &resp)
+
+	assertFeed(suite.T(), resp)
+	assert.Equal(suite.T(), int64(1), s.treesCalls.Load())
+	assert.Equal(suite.T(), int64(0), s.treeCalls.Load())
+}
+
+// Страница ленты в Postgres: запрос постов и один запрос комментариев
+func (suite *DataloaderTestSuite) TestFeedPage_PostgresQueryCount() {
+	pool := testutils.SetupCountingTestPool(suite.T())
+	defer pool.Close()
+	defer testutils.CleanTestPool(suite.T(), pool)
+
+	s := postgres.New(pool)
+	createFeed(suite.T(), s)
+
+	testutils.ResetQueryCount()
+
+	var resp feedResponse
+	newFeedClient(s).MustPost(feedQuery, &resp)
+
+	assertFeed(suite.T(), resp)
+	assert.Equal(suite.T(), int64(2), testutils.QueryCount())
+}
+
+// Несуществующий пост в пачке не ломает загрузку остальных
+func (suite *DataloaderTestSuite) TestGetCommentsTrees_MissingPost() {
+	s := memory.New()
+	post := testutils.CreateTestPost(suite.T(), s, "Post", true)
+	testutils.CreateTestComment(suite.T(), s, post.ID, nil, "Comment")
+
+	trees, err := s.GetCommentsTrees([]string{post.ID, "nonexistent-id"}, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), trees, 1)
+	assert.Len(suite.T(), trees[post.ID], 1)
+}
+
+// Фильтр по времени передается через DateTime и не смешивает пачки с разными фильтрами
+func (suite *DataloaderTestSuite) TestFeedPage_CreatedAfter() {
+	s := &countingStorage{Storage: memory.New()}
+	post := testutils.CreateTestPost(suite.T(), s, "Post", true)
+	first := testutils.CreateTestComment(suite.T(), s, post.ID, nil, "Comment 1")
+	testutils.CreateTestComment(suite.T(), s, post.ID, nil, "Comment 2")
+
+	var resp struct {
+		GetPost struct {
+			All      []struct{ Text string }
+			Filtered []struct{ Text string }
+		}
+	}
+	newFeedClient(s).MustPost(`query($id: ID!, $after: DateTime) {
+		getPost(postID: $id) {
+			all: comments { text }
+			filtered: comments(createdAfter: $after) { text }
+		}
+	}`, &resp,
+		client.Var("id", post.ID),
+		client.Var("after", first.CreatedAt.Format(time.RFC3339Nano)))
+
+	assert.Len(suite.T(), resp.GetPost.All, 2)
+	require.Len(suite.T(), resp.GetPost.Filtered, 1)
+	assert.Equal(suite.T(), "Comment 2", resp.GetPost.Filtered[0].Text)
+	assert.Equal(suite.T(), int64(2), s.treesCalls.Load())
+}
+
+// Запуск тестов
+func TestDataloaderTestSuite(t *testing.T) {
+	suite.Run(t, new(DataloaderTestSuite))
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/scheduler"
+	"PostAndComment/storage/memory"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DraftsTestSuite struct {
+	suite.Suite
+	storage *memory.InMemoryStorage
+	client  *client.Client
+}
+
+const newDraftMutation = `mutation($status: PostStatus, $at: DateTime) {
+	newPost(text: "draft", commentsEnabled: true, status: $status, publishAt: $at) { id status publishAt }
+}`
+
+type draftResponse struct {
+	NewPost struct {
+		ID        string
+		Status    string
+		PublishAt *string
+	}
+}
+
+func (suite *DraftsTestSuite) SetupTest() {
+	suite.storage = memory.New()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: suite.storage})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+// Черновик виден автору в myDrafts и getPost, остальным - нет
+func (suite *DraftsTestSuite) TestDraft_VisibleOnlyToAuthor() {
+	var draft draftResponse
+	suite.client.MustPost(newDraftMutation, &draft, client.Var("status", "DRAFT"), asUser("author"))
+	assert.Equal(suite.T(), "DRAFT", draft.NewPost.Status)
+
+	var drafts struct{ MyDrafts []struct{ ID string } }
+	suite.client.MustPost(`query { myDrafts { id } }`, &drafts, asUser("author"))
+	require.Len(suite.T(), drafts.MyDrafts, 1)
+	assert.Equal(suite.T(), draft.NewPost.ID, drafts.MyDrafts[0].ID)
+
+	const getPost = `query($id: ID!) { getPost(postID: $id) { id } }`
+	var resp struct{ GetPost struct{ ID string } }
+	suite.client.MustPost(getPost, &resp, client.Var("id", draft.NewPost.ID), asUser("author"))
+	suite.client.MustPost(getPost, &resp, client.Var("id", draft.NewPost.ID), asUser("moderator"))
+
+	err := suite.client.Post(getPost, &resp, client.Var("id", draft.NewPost.ID), asUser("stranger"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+
+	var feed struct{ GetPosts []struct{ ID string } }
+	suite.client.MustPost(`query { getPosts { id } }`, &feed)
+	assert.Empty(suite.T(), feed.GetPosts)
+}
+
+// Публиковать может только автор
+func (suite *DraftsTestSuite) TestPublishPost() {
+	var draft draftResponse
+	suite.client.MustPost(newDraftMutation, &draft, client.Var("status", "DRAFT"), asUser("author"))
+
+	const publish = `mutation($id: ID!) { publishPost(postID: $id) { status } }`
+	var resp struct{ PublishPost struct{ Status string } }
+	err := suite.client.Post(publish, &resp, client.Var("id", draft.NewPost.ID), asUser("stranger"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "access denied")
+
+	suite.client.MustPost(publish, &resp, client.Var("id", draft.NewPost.ID), asUser("author"))
+	assert.Equal(suite.T(), "PUBLISHED", resp.PublishPost.Status)
+}
+
+// Проверка аргументов состояния
+func (suite *DraftsTestSuite) TestNewPost_InvalidStatus() {
+	var resp draftResponse
+	future := time.Now().Add(time.Hour).Format(time.RFC3339Nano)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339Nano)
+
+	err := suite.client.Post(newDraftMutation, &resp, client.Var("status", "DRAFT"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "authentication required")
+
+	err = suite.client.Post(newDraftMutation, &resp, client.Var("status", "SCHEDULED"), asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "publishAt is required")
+
+	err = suite.client.Post(newDraftMutation, &resp, client.Var("status", "SCHEDULED"), client.Var("at", past), asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "must be in the future")
+
+	err = suite.client.Post(newDraftMutation, &resp, client.Var("at", future), asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "only be set for scheduled posts")
+}
+
+// Планировщик публикует пост, когда наступает publishAt
+func (suite *DraftsTestSuite) TestScheduler_PublishesDuePosts() {
+	publishAt := time.Now().Add(time.Hour)
+	var scheduled draftResponse
+	suite.client.MustPost(newDraftMutation, &scheduled,
+		client.Var("status", "SCHEDULED"), client.Var("at", publishAt.Format(time.RFC3339Nano)), asUser("author"))
+	assert.Equal(suite.T(), "SCHEDULED", scheduled.NewPost.Status)
+	require.NotNil(suite.T(), scheduled.NewPost.PublishAt)
+
+	s := scheduler.New(suite.storage, time.Minute)
+	count, err := s.PublishDue(time.Now())
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+
+	count, err = s.PublishDue(publishAt)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	var feed struct{ GetPosts []struct{ ID, Status string } }
+	suite.client.MustPost(`query { getPosts { id status } }`, &feed)
+	require.Len(suite.T(), feed.GetPosts, 1)
+	assert.Equal(suite.T(), scheduled.NewPost.ID, feed.GetPosts[0].ID)
+	assert.Equal(suite.T(), "PUBLISHED", feed.GetPosts[0].Status)
+}
+
+// Запуск тестов
+func TestDraftsTestSuite(t *testing.T) {
+	suite.Run(t, new(DraftsTestSuite))
+}
@@ -0,0 +1,270 @@
+package tests
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tests/testutils"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DurableStorageTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *DurableStorageTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+// Хранилище с журналом в каталоге теста. Без Close экземпляр просто бросается, как при сбое процесса
+func (suite *DurableStorageTestSuite) open(snapshotEvery int) *memory.DurableStorage {
+	s, err := memory.Open(memory.Options{Dir: suite.dir, Fsync: memory.FsyncAlways, SnapshotEvery: snapshotEvery})
+	require.NoError(suite.T(), err)
+	return s
+}
+
+// Состояние, по которому сравниваются хранилища до и после восстановления
+func (suite *DurableStorageTestSuite) state(s storage.Storage) string {
+	posts, err := s.ExportPosts(100, 0)
+	require.NoError(suite.T(), err)
+	notifications, err := s.GetNotifications("bob", 100, 0)
+	require.NoError(suite.T(), err)
+	queue, err := s.GetModerationQueue(100, 0)
+	require.NoError(suite.T(), err)
+
+	revisions := map[string][]*model.Revision{}
+	decisions := map[string][]*model.ModerationDecision{}
+	for _, post := range posts {
+		revisions[post.Post.ID], err = s.GetRevisions(post.Post.ID)
+		require.NoError(suite.T(), err)
+		for _, c := range post.Comments {
+			decisions[c.ID], err = s.GetModerationLog(c.ID)
+			require.NoError(suite.T(), err)
+		}
+	}
+
+	data, err := json.Marshal([]any{posts, notifications, queue, revisions, decisions})
+	require.NoError(suite.T(), err)
+	return string(data)
+}
+
+// Операции всех видов, чтобы повтор журнала прошел по каждой
+func (suite *DurableStorageTestSuite) fill(s storage.Storage) {
+	post, err := s.NewPost(storage.NewPostParams{AuthorID: "alice", Title: "Title", Text: "Hello @bob",
+		Tags: []string{"go"}, CommentsEnabled: true})
+	require.NoError(suite.T(), err)
+	root, err := s.AddComment(storage.NewCommentParams{PostID: post.ID, AuthorID: "carol", Text: "Root @bob"})
+	require.NoError(suite.T(), err)
+	reply := testutils.CreateTestComment(suite.T(), s, post.ID, &root.ID, "Reply")
+
+	_, err = s.EditPost(post.ID, "alice", "Hello again @bob", nil)
+	require.NoError(suite.T(), err)
+	_, err = s.EditComment(reply.ID, "moderator", "Edited reply", nil)
+	require.NoError(suite.T(), err)
+	_, err = s.SetCommentPinned(root.ID, true, nil)
+	require.NoError(suite.T(), err)
+	_, err = s.SetThreadLocked(root.ID, true, nil)
+	require.NoError(suite.T(), err)
+	_, err = s.SetSlowMode(post.ID, 30, nil)
+	require.NoError(suite.T(), err)
+	_, err = s.SetCommentsPolicy(post.ID, storage.CommentsPolicy{MaxComments: 100}, nil)
+	require.NoError(suite.T(), err)
+	_, err = s.ReportComment(reply.ID, "dave", "spam")
+	require.NoError(suite.T(), err)
+	_, err = s.ModerateComment(reply.ID, "moderator", model.ModerationActionHide, nil, nil)
+	require.NoError(suite.T(), err)
+
+	draft, err := s.NewPost(storage.NewPostParams{AuthorID: "alice", Text: "Draft", Draft: true})
+	require.NoError(suite.T(), err)
+	_, err = s.PublishPost(draft.ID, nil, nil)
+	require.NoError(suite.T(), err)
+	_, err = s.SetCommentsEnabled(draft.ID, false, nil)
+	require.NoError(suite.T(), err)
+}
+
+// После сбоя состояние восстанавливается из журнала с теми же ID и временем
+func (suite *DurableStorageTestSuite) TestReplay() {
+	s := suite.open(1000)
+	suite.fill(s)
+	expected := suite.state(s)
+
+	restored := suite.open(1000)
+	assert.Equal(suite.T(), expected, suite.state(restored))
+
+	// Восстановленное хранилище продолжает журнал
+	testutils.CreateTestPost(suite.T(), restored, "After restart", true)
+	expected = suite.state(restored)
+	assert.Equal(suite.T(), expected, suite.state(suite.open(1000)))
+}
+
+// Снимок пишется каждые SnapshotEvery операций, журнал после него начинается заново
+func (suite *DurableStorageTestSuite) TestSnapshot() {
+	s := suite.open(5)
+	suite.fill(s)
+	expected := suite.state(s)
+
+	_, err := os.Stat(filepath.Join(suite.dir, "snapshot.json"))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), expected, suite.state(suite.open(5)))
+
+	// Close пишет снимок и очищает журнал
+	require.NoError(suite.T(), s.Close())
+	info, err := os.Stat(filepath.Join(suite.dir, "wal.log"))
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), info.Size())
+	assert.Equal(suite.T(), expected, suite.state(suite.open(5)))
+
+	_, err = s.NewPost(storage.NewPostParams{Text: "closed"})
+	require.Error(suite.T(), err)
+}
+
+// Сбой между записью снимка и очисткой журнала: записи из снимка не повторяются
+func (suite *DurableStorageTestSuite) TestSnapshot_StaleLog() {
+	s := suite.open(1000)
+	suite.fill(s)
+	expected := suite.state(s)
+
+	walPath := filepath.Join(suite.dir, "wal.log")
+	wal, err := os.ReadFile(walPath)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), s.Snapshot())
+	require.NoError(suite.T(), os.WriteFile(walPath, wal, 0o644))
+
+	assert.Equal(suite.T(), expected, suite.state(suite.open(1000)))
+}
+
+// Оборванная на середине последняя запись отбрасывается, остальные восстанавливаются
+func (suite *DurableStorageTestSuite) TestTornRecord() {
+	s := suite.open(1000)
+	testutils.CreateTestPost(suite.T(), s, "First", true)
+	expected := suite.state(s)
+	testutils.CreateTestPost(suite.T(), s, "Second", true)
+
+	walPath := filepath.Join(suite.dir, "wal.log")
+	data, err := os.ReadFile(walPath)
+	require.NoError(suite.T(), err)
+	for _, cut := range []int{1, 20, len(data)/2 - 1} {
+		require.NoError(suite.T(), os.WriteFile(walPath, data[:len(data)-cut], 0o644))
+		assert.Equal(suite.T(), expected, suite.state(suite.open(1000)), "cut %d bytes", cut)
+	}
+
+	// Хвост из нулей (файловая система успела увеличить файл, но не записать данные)
+	require.NoError(suite.T(), os.WriteFile(walPath, append(data[:len(data)-20], make([]byte, 64)...), 0o644))
+	assert.Equal(suite.T(), expected, suite.state(suite.open(1000)))
+
+	// Новая запись пишется после последней целой, а не после оборванного хвоста
+	restored := suite.open(1000)
+	testutils.CreateTestPost(suite.T(), restored, "Third", true)
+	expected = suite.state(restored)
+	assert.Equal(suite.T(), expected, suite.state(suite.open(1000)))
+}
+
+// Запись с неверной контрольной суммой считается оборванной
+func (suite *DurableStorageTestSuite) TestCorruptedRecord() {
+	s := suite.open(1000)
+	testutils.CreateTestPost(suite.T(), s, "First", true)
+	expected := suite.state(s)
+	testutils.CreateTestPost(suite.T(), s, "Second", true)
+
+	walPath := filepath.Join(suite.dir, "wal.log")
+	data, err := os.ReadFile(walPath)
+	require.NoError(suite.T(), err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(suite.T(), os.WriteFile(walPath, data, 0o644))
+
+	assert.Equal(suite.T(), expected, suite.state(suite.open(1000)))
+}
+
+// Ошибочные операции в журнал не попадают, пустая публикация планировщика тоже
+func (suite *DurableStorageTestSuite) TestFailedOperationsNotLogged() {
+	s := suite.open(1000)
+	post := testutils.CreateTestPost(suite.T(), s, "Post", false)
+	walPath := filepath.Join(suite.dir, "wal.log")
+	before, err := os.Stat(walPath)
+	require.NoError(suite.T(), err)
+
+	_, err = s.AddComment(storage.NewCommentParams{PostID: post.ID, Text: "disabled"})
+	require.Error(suite.T(), err)
+	stale := post.Version + 1
+	_, err = s.SetCommentsEnabled(post.ID, true, &stale)
+	require.ErrorIs(suite.T(), err, storage.ErrConflict)
+	published, err := s.PublishDuePosts(time.Now())
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), published)
+
+	after, err := os.Stat(walPath)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), before.Size(), after.Size())
+	assert.Equal(suite.T(), suite.state(s), suite.state(suite.open(1000)))
+}
+
+func (suite *DurableStorageTestSuite) TestInvalidOptions() {
+	_, err := memory.Open(memory.Options{Dir: suite.dir, Fsync: "sometimes"})
+	require.Error(suite.T(), err)
+
+	require.NoError(suite.T(), os.WriteFile(filepath.Join(suite.dir, "snapshot.json"), []byte("{broken"), 0o644))
+	_, err = memory.Open(memory.Options{Dir: suite.dir})
+	require.Error(suite.T(), err)
+}
+
+// Запуск тестов
+// Ключи идемпотентности переживают перезапуск и через журнал, и через снимок
+func (suite *DurableStorageTestSuite) TestIdempotencyKeys() {
+	completed := storage.IdempotencyKey{Scope: "alice", Key: "completed"}
+	released := storage.IdempotencyKey{Scope: "alice", Key: "released"}
+
+	s := suite.open(1000)
+	_, err := s.ClaimIdempotencyKey(completed, "newPost", time.Hour)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), s.CompleteIdempotencyKey(completed, []byte(`{"id":"post-1"}`)))
+	_, err = s.ClaimIdempotencyKey(released, "newPost", time.Hour)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), s.ReleaseIdempotencyKey(released))
+
+	check := func(s storage.Storage) {
+		rec, err := s.ClaimIdempotencyKey(completed, "newPost", time.Hour)
+		require.NoError(suite.T(), err)
+		require.NotNil(suite.T(), rec)
+		assert.JSONEq(suite.T(), `{"id":"post-1"}`, string(rec.Result))
+	}
+
+	restored := suite.open(1000)
+	check(restored)
+	rec, err := restored.ClaimIdempotencyKey(released, "newPost", time.Hour)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), rec)
+
+	require.NoError(suite.T(), restored.Close())
+	check(suite.open(1000))
+}
+
+// Данные пространств восстанавливаются в своих пространствах: из журнала и из снимка
+func (suite *DurableStorageTestSuite) TestSpaces() {
+	for _, snapshotEvery := range []int{1000, 5} {
+		suite.dir = suite.T().TempDir()
+		s := suite.open(snapshotEvery)
+		docs := storage.ForContext(storage.WithSpace(context.Background(), "docs"), s)
+		suite.fill(docs)
+		testutils.CreateTestPost(suite.T(), s, "Default", true)
+		expectedDocs, expectedDefault := suite.state(docs), suite.state(s)
+
+		restored := suite.open(snapshotEvery)
+		assert.Equal(suite.T(), expectedDocs, suite.state(storage.ForContext(storage.WithSpace(context.Background(), "docs"), restored)))
+		assert.Equal(suite.T(), expectedDefault, suite.state(restored))
+		assert.NotEqual(suite.T(), expectedDocs, expectedDefault)
+	}
+}
+
+func TestDurableStorageTestSuite(t *testing.T) {
+	suite.Run(t, new(DurableStorageTestSuite))
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EntitiesTestSuite struct {
+	suite.Suite
+}
+
+// Упоминания и хештеги со смещениями в символах
+func (suite *EntitiesTestSuite) TestExtract_Offsets() {
+	entities := storage.ExtractEntities("Привет, @alice! Смотри #GoLang и @bob_2.")
+
+	assert.Equal(suite.T(), []*model.Mention{
+		{Username: "alice", Offset: 8, Length: 6},
+		{Username: "bob_2", Offset: 33, Length: 6},
+	}, entities.Mentions)
+	assert.Equal(suite.T(), []*model.Hashtag{
+		{Tag: "golang", Offset: 23, Length: 7},
+	}, entities.Hashtags)
+}
+
+// Адреса почты, "##" и пустые имена не считаются
+func (suite *EntitiesTestSuite) TestExtract_NotEntities() {
+	entities := storage.ExtractEntities("mail me at user@example.com ## # @ a#b")
+
+	assert.Empty(suite.T(), entities.Mentions)
+	assert.Empty(suite.T(), entities.Hashtags)
+}
+
+// Код в обратных кавычках пропускается
+func (suite *EntitiesTestSuite) TestExtract_SkipsCode() {
+	entities := storage.ExtractEntities("`@decorator` and\n```\n#include <stdio.h>\n```\n@alice")
+
+	assert.Equal(suite.T(), []*model.Mention{{Username: "alice", Offset: 44, Length: 6}}, entities.Mentions)
+	assert.Empty(suite.T(), entities.Hashtags)
+}
+
+// Повторные упоминания дают одно уведомление
+func (suite *EntitiesTestSuite) TestMentionedUsers_Distinct() {
+	entities := storage.ExtractEntities("@bob @alice @bob")
+
+	assert.Equal(suite.T(), []string{"bob", "alice"}, entities.MentionedUsers())
+}
+
+// Запуск тестов
+func TestEntitiesTestSuite(t *testing.T) {
+	suite.Run(t, new(EntitiesTestSuite))
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencyTestSuite struct {
+	suite.Suite
+	storage storage.Storage
+	client  *client.Client
+}
+
+const (
+	idempotentNewPost    = `mutation($key: String) { newPost(text: "post", commentsEnabled: true, clientMutationId: $key) { id } }`
+	idempotentAddComment = `mutation($id: ID!, $key: String) { addComment(postID: $id, text: "comment", clientMutationId: $key) { id text } }`
+)
+
+func (suite *IdempotencyTestSuite) SetupTest() {
+	suite.storage = memory.New()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: suite.storage})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+func (suite *IdempotencyTestSuite) newPost(key string, options ...client.Option) string {
+	var resp struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(idempotentNewPost, &resp, append(options, client.Var("key", key))...)
+	return resp.NewPost.ID
+}
+
+// Повтор newPost с тем же ключом возвращает тот же пост и не создает второй
+func (suite *IdempotencyTestSuite) TestNewPost_Retry() {
+	first := suite.newPost("key-1", asUser("author"))
+	second := suite.newPost("key-1", asUser("author"))
+
+	assert.Equal(suite.T(), first, second)
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 1)
+}
+
+// Повтор addComment возвращает тот же комментарий
+func (suite *IdempotencyTestSuite) TestAddComment_Retry() {
+	postID := suite.newPost("post-key")
+
+	var first, second struct{ AddComment struct{ ID, Text string } }
+	suite.client.MustPost(idempotentAddComment, &first, client.Var("id", postID), client.Var("key", "comment-key"))
+	suite.client.MustPost(idempotentAddComment, &second, client.Var("id", postID), client.Var("key", "comment-key"))
+
+	assert.Equal(suite.T(), first.AddComment, second.AddComment)
+	comments, err := suite.storage.GetCommentsTree(postID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), comments, 1)
+}
+
+// Без ключа каждый вызов создает новый объект
+func (suite *IdempotencyTestSuite) TestWithoutKey() {
+	var resp struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &resp)
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &resp)
+
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 2)
+}
+
+// Одинаковые ключи разных пользователей не пересекаются
+func (suite *IdempotencyTestSuite) TestKeysPerUser() {
+	first := suite.newPost("key-1", asUser("alice"))
+	second := suite.newPost("key-1", asUser("bob"))
+
+	assert.NotEqual(suite.T(), first, second)
+}
+
+// Ключ, использованный для другой мутации, - ошибка
+func (suite *IdempotencyTestSuite) TestOtherMutation() {
+	postID := suite.newPost("key-1")
+
+	var resp struct{}
+	err := suite.client.Post(idempotentAddComment, &resp, client.Var("id", postID), client.Var("key", "key-1"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "was already used for newPost")
+}
+
+// Неудавшаяся мутация освобождает ключ: повтор выполняется заново
+func (suite *IdempotencyTestSuite) TestFailedMutationReleasesKey() {
+	postID := suite.newPost("post-key")
+	_, err := suite.storage.SetCommentsEnabled(postID, false, nil)
+	require.NoError(suite.T(), err)
+
+	var resp struct{ AddComment struct{ ID, Text string } }
+	err = suite.client.Post(idempotentAddComment, &resp, client.Var("id", postID), client.Var("key", "comment-key"))
+	require.Error(suite.T(), err)
+
+	_, err = suite.storage.SetCommentsEnabled(postID, true, nil)
+	require.NoError(suite.T(), err)
+	suite.client.MustPost(idempotentAddComment, &resp, client.Var("id", postID), client.Var("key", "comment-key"))
+	assert.NotEmpty(suite.T(), resp.AddComment.ID)
+}
+
+// Повтор проходит проверку доступа заново: чужой ключ не выдает ответ
+func (suite *IdempotencyTestSuite) TestRetryChecksAccess() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &post, asUser("author"))
+
+	const setSlowMode = `mutation($id: ID!) { setSlowMode(postID: $id, seconds: 10, clientMutationId: "key-1") { slowModeSeconds } }`
+	var resp struct{ SetSlowMode struct{ SlowModeSeconds int } }
+	suite.client.MustPost(setSlowMode, &resp, client.Var("id", post.NewPost.ID), asUser("author"))
+	assert.Equal(suite.T(), 10, resp.SetSlowMode.SlowModeSeconds)
+
+	err := suite.client.Post(setSlowMode, &resp, client.Var("id", post.NewPost.ID), asUser("stranger"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "access denied")
+}
+
+func TestIdempotencyTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
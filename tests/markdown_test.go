@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/markdown"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tests/testutils"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MarkdownTestSuite struct {
+	suite.Suite
+	renderer *markdown.Renderer
+}
+
+func (suite *MarkdownTestSuite) SetupTest() {
+	suite.renderer = markdown.New(10)
+}
+
+func (suite *MarkdownTestSuite) render(text string) string {
+	html, err := suite.renderer.Render(text)
+	require.NoError(suite.T(), err)
+	return html
+}
+
+// Базовое форматирование CommonMark
+func (suite *MarkdownTestSuite) TestRender_Formatting() {
+	html := suite.render("# Title\n\n**bold** and *italic*\n\n- one\n- two")
+
+	assert.Contains(suite.T(), html, "<h1>Title</h1>")
+	assert.Contains(suite.T(), html, "<strong>bold</strong>")
+	assert.Contains(suite.T(), html, "<em>italic</em>")
+	assert.Contains(suite.T(), html, "<li>one</li>")
+}
+
+// Блок кода с языком
+func (suite *MarkdownTestSuite) TestRender_CodeBlock() {
+	html := suite.render("```go\nfmt.Println(\"<hi>\")\n```")
+
+	assert.Contains(suite.T(), html, `<pre><code class="language-go">`)
+	assert.Contains(suite.T(), html, "&lt;hi&gt;")
+}
+
+// Ссылки без разметки становятся кликабельными
+func (suite *MarkdownTestSuite) TestRender_Autolink() {
+	html := suite.render("see https://example.com/page")
+
+	assert.Contains(suite.T(), html, `href="https://example.com/page"`)
+	assert.Contains(suite.T(), html, `rel="nofollow noopener"`)
+}
+
+// Опасный HTML и ссылки вырезаются
+func (suite *MarkdownTestSuite) TestRender_Sanitized() {
+	html := suite.render("<script>alert(1)</script>\n\n[click](javascript:alert(1))\n\n<img src=x onerror=alert(1)>")
+
+	assert.NotContains(suite.T(), html, "<script")
+	assert.NotContains(suite.T(), html, "javascript:")
+	assert.NotContains(suite.T(), html, "onerror")
+}
+
+// Повторный рендер того же текста берется из кеша
+func (suite *MarkdownTestSuite) TestRender_Cached() {
+	first := suite.render("**text**")
+	second := suite.render("**text**")
+	suite.render("**other text**")
+
+	assert.Equal(suite.T(), first, second)
+	assert.Equal(suite.T(), 2, suite.renderer.Len())
+}
+
+// Формат текста выбирается аргументом поля
+func (suite *MarkdownTestSuite) TestTextFormatField() {
+	s := memory.New()
+	post := testutils.CreateTestPost(suite.T(), s, "**post**", true)
+	testutils.CreateTestComment(suite.T(), s, post.ID, nil, "_comment_")
+
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: s, Markdown: suite.renderer})))
+	srv.AddTransport(transport.POST{})
+
+	var resp struct {
+		GetPost struct {
+			Text     string
+			HTML     string
+			Comments []struct{ HTML string }
+		}
+	}
+	client.New(srv).MustPost(`query($id: ID!) {
+		getPost(postID: $id) {
+			text
+			html: text(format: HTML)
+			comments { html: text(format: HTML) }
+		}
+	}`, &resp, client.Var("id", post.ID))
+
+	assert.Equal(suite.T(), "**post**", resp.GetPost.Text)
+	assert.Equal(suite.T(), "<p><strong>post</strong></p>\n", resp.GetPost.HTML)
+	require.Len(suite.T(), resp.GetPost.Comments, 1)
+	assert.Equal(suite.T(), "<p><em>comment</em></p>\n", resp.GetPost.Comments[0].HTML)
+}
+
+// Запуск тестов
+func TestMarkdownTestSuite(t *testing.T) {
+	suite.Run(t, new(MarkdownTestSuite))
+}
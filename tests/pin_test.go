@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage/memory"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PinTestSuite struct {
+	suite.Suite
+	client *client.Client
+}
+
+const pinMutation = `mutation($id: ID!) { pinComment(commentID: $id) { isPinned } }`
+
+func (suite *PinTestSuite) SetupTest() {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: memory.New()})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+func asUser(id string) client.Option {
+	return client.AddHeader(auth.UserIDHeader, id)
+}
+
+// Пост от имени author с одним комментарием, возвращает ID комментария
+func (suite *PinTestSuite) createComment() string {
+	var post struct{ NewPost struct{ ID, AuthorID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id authorID } }`, &post, asUser("author"))
+	assert.Equal(suite.T(), "author", post.NewPost.AuthorID)
+
+	var comment struct{ AddComment struct{ ID string } }
+	suite.client.MustPost(`mutation($id: ID!) { addComment(postID: $id, text: "comment") { id } }`, &comment,
+		client.Var("id", post.NewPost.ID))
+	return comment.AddComment.ID
+}
+
+// Автор поста может закрепить комментарий
+func (suite *PinTestSuite) TestPin_ByAuthor() {
+	commentID := suite.createComment()
+
+	var resp struct{ PinComment struct{ IsPinned bool } }
+	suite.client.MustPost(pinMutation, &resp, client.Var("id", commentID), asUser("author"))
+
+	assert.True(suite.T(), resp.PinComment.IsPinned)
+}
+
+// Модератор может закрепить комментарий в чужом посте
+func (suite *PinTestSuite) TestPin_ByModerator() {
+	commentID := suite.createComment()
+
+	var resp struct{ PinComment struct{ IsPinned bool } }
+	suite.client.MustPost(pinMutation, &resp, client.Var("id", commentID), asUser("moderator"))
+
+	assert.True(suite.T(), resp.PinComment.IsPinned)
+}
+
+// Остальным закреплять нельзя
+func (suite *PinTestSuite) TestPin_Denied() {
+	commentID := suite.createComment()
+
+	var resp struct{}
+	err := suite.client.Post(pinMutation, &resp, client.Var("id", commentID), asUser("stranger"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "access denied")
+
+	err = suite.client.Post(pinMutation, &resp, client.Var("id", commentID))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "authentication required")
+}
+
+// Запуск тестов
+func TestPinTestSuite(t *testing.T) {
+	suite.Run(t, new(PinTestSuite))
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"PostAndComment/storage"
+	"PostAndComment/storage/postgres"
+	"PostAndComment/tests/testutils"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Режимы выполнения запросов для сравнения: кеш подготовленных выражений (по умолчанию)
+// против разбора каждого запроса заново
+var benchExecModes = []struct {
+	name string
+	mode pgx.QueryExecMode
+}{
+	{"CacheStatement", pgx.QueryExecModeCacheStatement},
+	{"Exec", pgx.QueryExecModeExec},
+	{"SimpleProtocol", pgx.QueryExecModeSimpleProtocol},
+}
+
+// Хранилище Postgres на пуле с заданным режимом выполнения запросов. Соединения ограничены одним,
+// чтобы кеш выражений прогревался на первой итерации
+func benchStorage(b *testing.B, mode pgx.QueryExecMode) storage.Storage {
+	b.Helper()
+
+	testutils.SetupTestDB(b).Close()
+	config := testutils.TestPoolConfig(b)
+	config.ConnConfig.DefaultQueryExecMode = mode
+	config.MaxConns = 1
+	config.MinConns = 0
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		testutils.CleanTestPool(b, pool)
+		pool.Close()
+	})
+	return postgres.New(pool)
+}
+
+// Пост с деревом из 20 корневых комментариев по 2 ответа
+func benchPost(b *testing.B, s storage.Storage) string {
+	b.Helper()
+
+	post := testutils.CreateTestPost(b, s, "Benchmark post", true)
+	for i := 0; i < 20; i++ {
+		root := testutils.CreateTestComment(b, s, post.ID, nil, fmt.Sprintf("Comment %d", i))
+		for j := 0; j < 2; j++ {
+			testutils.CreateTestComment(b, s, post.ID, &root.ID, fmt.Sprintf("Reply %d.%d", i, j))
+		}
+	}
+	return post.ID
+}
+
+func BenchmarkPostgres_GetPost(b *testing.B) {
+	testutils.SkipIfNoDatabase(b)
+	for _, m := range benchExecModes {
+		b.Run(m.name, func(b *testing.B) {
+			s := benchStorage(b, m.mode)
+			postID := benchPost(b, s)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.GetPost(postID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPostgres_GetCommentsTree(b *testing.B) {
+	testutils.SkipIfNoDatabase(b)
+	for _, m := range benchExecModes {
+		b.Run(m.name, func(b *testing.B) {
+			s := benchStorage(b, m.mode)
+			postID := benchPost(b, s)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.GetCommentsTree(postID, 10, 0, storage.TimeRange{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Запись комментария: вставка, счетчики и уведомления уходят одной пачкой
+func BenchmarkPostgres_AddComment(b *testing.B) {
+	testutils.SkipIfNoDatabase(b)
+	for _, m := range benchExecModes {
+		b.Run(m.name, func(b *testing.B) {
+			s := benchStorage(b, m.mode)
+			post := testutils.CreateTestPost(b, s, "Benchmark post", true)
+			root := testutils.CreateTestComment(b, s, post.ID, nil, "Root")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := s.AddComment(storage.NewCommentParams{PostID: post.ID, ParentID: &root.ID, Text: "Reply"})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
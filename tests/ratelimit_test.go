@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/ratelimit"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tests/testutils"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+// Бакет пропускает burst запросов, затем отклоняет до пополнения
+func (suite *RateLimitTestSuite) TestMemoryStore_Burst() {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 10, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Take("client", limit)
+		require.NoError(suite.T(), err)
+		assert.True(suite.T(), result.Allowed)
+	}
+
+	result, err := store.Take("client", limit)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), result.Allowed)
+	assert.Greater(suite.T(), result.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(suite.T(), result.RetryAfter, 100*time.Millisecond)
+	retryAfter := result.RetryAfter
+
+	// другой клиент не затронут
+	result, err = store.Take("other-client", limit)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), result.Allowed)
+
+	// после пополнения токен снова доступен
+	time.Sleep(retryAfter + 20*time.Millisecond)
+	result, err = store.Take("client", limit)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), result.Allowed)
+}
+
+// Ограничение одновременных подписок клиента
+func (suite *RateLimitTestSuite) TestConcurrencyLimiter() {
+	limiter := ratelimit.NewConcurrencyLimiter(2)
+
+	release1, ok := limiter.Acquire("client")
+	require.True(suite.T(), ok)
+	_, ok = limiter.Acquire("client")
+	require.True(suite.T(), ok)
+
+	_, ok = limiter.Acquire("client")
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), 2, limiter.Active("client"))
+
+	// повторное освобождение слота ничего не ломает
+	release1()
+	release1()
+	assert.Equal(suite.T(), 1, limiter.Active("client"))
+
+	_, ok = limiter.Acquire("client")
+	assert.True(suite.T(), ok)
+}
+
+// Лимит 0 - подписки не ограничены
+func (suite *RateLimitTestSuite) TestConcurrencyLimiter_Unlimited() {
+	limiter := ratelimit.NewConcurrencyLimiter(0)
+
+	for i := 0; i < 100; i++ {
+		_, ok := limiter.Acquire("client")
+		require.True(suite.T(), ok)
+	}
+	assert.Equal(suite.T(), 100, limiter.Active("client"))
+}
+
+// Превышение лимита мутаций возвращает ошибку RATE_LIMITED с retryAfter
+func (suite *RateLimitTestSuite) TestExtension_RateLimitedMutation() {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{Storage: memory.New()}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(&ratelimit.Extension{
+		Store: ratelimit.NewMemoryStore(),
+		Limits: map[ast.Operation]ratelimit.Limit{
+			ast.Mutation: {Rate: 0.1, Burst: 2},
+		},
+	})
+	c := client.New(ratelimit.Middleware(ratelimit.ClientOptions{})(srv))
+
+	mutation := `mutation { newPost(text: "post", commentsEnabled: true) { id } }`
+	for i := 0; i < 2; i++ {
+		resp, err := c.RawPost(mutation)
+		require.NoError(suite.T(), err)
+		assert.Nil(suite.T(), resp.Errors)
+	}
+
+	resp, err := c.RawPost(mutation)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), resp.Errors)
+
+	var errs []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	}
+	require.NoError(suite.T(), json.Unmarshal(resp.Errors, &errs))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), ratelimit.ErrorCode, errs[0].Extensions["code"])
+	assert.EqualValues(suite.T(), 10, errs[0].Extensions["retryAfter"])
+
+	// запросы на чтение ограничиваются отдельно
+	resp, err = c.RawPost(`query { getPosts { id } }`)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), resp.Errors)
+}
+
+// Свой бакет только у известных API-ключей: смена неизвестного ключа в каждом запросе
+// не сбрасывает лимит по IP
+func (suite *RateLimitTestSuite) TestMiddleware_APIKeys() {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{Storage: memory.New()}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(&ratelimit.Extension{
+		Store: ratelimit.NewMemoryStore(),
+		Limits: map[ast.Operation]ratelimit.Limit{
+			ast.Mutation: {Rate: 0.1, Burst: 2},
+		},
+	})
+	c := client.New(ratelimit.Middleware(ratelimit.ClientOptions{APIKeys: []string{"known-key"}})(srv))
+
+	mutation := `mutation { newPost(text: "post", commentsEnabled: true) { id } }`
+	for i := 0; i < 3; i++ {
+		resp, err := c.RawPost(mutation, client.AddHeader(ratelimit.APIKeyHeader, fmt.Sprintf("random-%d", i)))
+		require.NoError(suite.T(), err)
+		if i < 2 {
+			assert.Nil(suite.T(), resp.Errors)
+		} else {
+			assert.NotNil(suite.T(), resp.Errors, "rotating an unknown API key must not reset the limit")
+		}
+	}
+
+	// У известного ключа свой бакет
+	resp, err := c.RawPost(mutation, client.AddHeader(ratelimit.APIKeyHeader, "known-key"))
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), resp.Errors)
+}
+
+// X-User-ID задает сам клиент, поэтому без TrustUserID смена пользователя в каждом запросе
+// не сбрасывает лимит по IP. С TrustUserID у пользователя свой бакет
+func (suite *RateLimitTestSuite) TestMiddleware_UserID() {
+	newClient := func(opts ratelimit.ClientOptions) *client.Client {
+		srv := handler.New(graph.NewExecutableSchema(graph.Config{
+			Resolvers: &graph.Resolver{Storage: memory.New()}}))
+		srv.AddTransport(transport.POST{})
+		srv.Use(&ratelimit.Extension{
+			Store: ratelimit.NewMemoryStore(),
+			Limits: map[ast.Operation]ratelimit.Limit{
+				ast.Mutation: {Rate: 0.1, Burst: 2},
+			},
+		})
+		return client.New(auth.New(nil).Middleware(ratelimit.Middleware(opts)(srv)))
+	}
+	mutation := `mutation { newPost(text: "post", commentsEnabled: true) { id } }`
+
+	untrusted := newClient(ratelimit.ClientOptions{})
+	for i := 0; i < 3; i++ {
+		resp, err := untrusted.RawPost(mutation, client.AddHeader(auth.UserIDHeader, fmt.Sprintf("user-%d", i)))
+		require.NoError(suite.T(), err)
+		if i < 2 {
+			assert.Nil(suite.T(), resp.Errors)
+		} else {
+			assert.NotNil(suite.T(), resp.Errors, "rotating an untrusted user ID must not reset the limit")
+		}
+	}
+
+	trusted := newClient(ratelimit.ClientOptions{TrustUserID: true})
+	for i := 0; i < 3; i++ {
+		resp, err := trusted.RawPost(mutation, client.AddHeader(auth.UserIDHeader, fmt.Sprintf("user-%d", i)))
+		require.NoError(suite.T(), err)
+		assert.Nil(suite.T(), resp.Errors)
+	}
+}
+
+// Бакеты в Postgres
+func (suite *RateLimitTestSuite) TestPostgresStore_Burst() {
+	db := testutils.SetupTestDB(suite.T())
+	defer db.Close()
+	defer testutils.CleanTestDB(suite.T(), db)
+
+	store := ratelimit.NewPostgresStore(db)
+	limit := ratelimit.Limit{Rate: 0.1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Take("client", limit)
+		require.NoError(suite.T(), err)
+		assert.True(suite.T(), result.Allowed)
+	}
+
+	result, err := store.Take("client", limit)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), result.Allowed)
+	assert.Greater(suite.T(), result.RetryAfter, time.Duration(0))
+}
+
+// Запуск тестов
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/storage/postgres"
+	"PostAndComment/tests/testutils"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Реплика, которая не принимает соединения
+func unreachableReplica(t *testing.T) *pgxpool.Pool {
+	pool, err := pgxpool.New(context.Background(),
+		"host=127.0.0.1 port=1 user=nobody dbname=none sslmode=disable connect_timeout=1")
+	require.NoError(t, err)
+	return pool
+}
+
+// Недоступная реплика после проверки исключается
+func TestReplicas_UnreachableIsUnhealthy(t *testing.T) {
+	replicas := postgres.NewReplicas([]*pgxpool.Pool{unreachableReplica(t)}, time.Hour, 0)
+	defer replicas.Close()
+
+	assert.Equal(t, 1, replicas.Healthy())
+	replicas.Check()
+	assert.Equal(t, 0, replicas.Healthy())
+}
+
+// Отмененный запрос не исключает реплику
+func TestReplicas_CanceledReadKeepsHealthy(t *testing.T) {
+	replicas := postgres.NewReplicas([]*pgxpool.Pool{unreachableReplica(t)}, time.Hour, 0)
+	defer replicas.Close()
+
+	ctx, cancel := context.WithCancel(storage.NewSession(context.Background()))
+	cancel()
+	s := storage.ForContext(ctx, postgres.NewReplicated(unreachableReplica(t), replicas))
+
+	_, err := s.GetPost("post-id")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, replicas.Healthy())
+}
+
+type ReplicaRoutingTestSuite struct {
+	suite.Suite
+	primary *pgxpool.Pool
+	replica *pgxpool.Pool
+}
+
+func (suite *ReplicaRoutingTestSuite) SetupTest() {
+	suite.primary = testutils.SetupTestPool(suite.T())
+	// Реплика - отдельный пул соединений с той же базой: по его счетчику захватов видно, куда шло чтение
+	suite.replica = testutils.OpenTestPool(suite.T())
+}
+
+func (suite *ReplicaRoutingTestSuite) TearDownTest() {
+	testutils.CleanTestPool(suite.T(), suite.primary)
+	suite.primary.Close()
+}
+
+func (suite *ReplicaRoutingTestSuite) replicated(dbs ...*pgxpool.Pool) (storage.Storage, *postgres.Replicas) {
+	replicas := postgres.NewReplicas(dbs, time.Hour, 0)
+	suite.T().Cleanup(func() { replicas.Close() })
+	return postgres.NewReplicated(suite.primary, replicas), replicas
+}
+
+// Чтение без записей в запросе идет на реплику
+func (suite *ReplicaRoutingTestSuite) TestReadsGoToReplica() {
+	s, _ := suite.replicated(suite.replica)
+	post := testutils.CreateTestPost(suite.T(), s, "Test post", true)
+
+	retrieved, err := s.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.ID, retrieved.ID)
+	_, err = s.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	posts, err := s.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 1)
+
+	assert.Positive(suite.T(), suite.replica.Stat().AcquireCount())
+}
+
+// После записи запрос читает из основной базы
+func (suite *ReplicaRoutingTestSuite) TestReadYourWrites() {
+	s, _ := suite.replicated(suite.replica)
+	ctx := storage.NewSession(context.Background())
+	bound := storage.ForContext(ctx, s)
+
+	post := testutils.CreateTestPost(suite.T(), bound, "Test post", true)
+	_, err := bound.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	_, err = bound.GetCommentsTree(post.ID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+
+	assert.True(suite.T(), storage.SessionFrom(ctx).Wrote())
+	assert.Zero(suite.T(), suite.replica.Stat().AcquireCount())
+}
+
+// Отказ реплики: чтение повторяется в основной базе, реплика исключается
+func (suite *ReplicaRoutingTestSuite) TestFallbackToPrimary() {
+	s, replicas := suite.replicated(unreachableReplica(suite.T()))
+	post := testutils.CreateTestPost(suite.T(), s, "Test post", true)
+
+	retrieved, err := s.GetPost(post.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), post.ID, retrieved.ID)
+	assert.Equal(suite.T(), 0, replicas.Healthy())
+
+	posts, err := s.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 1)
+}
+
+func TestReplicaRoutingTestSuite(t *testing.T) {
+	testutils.SkipIfNoDatabase(t)
+	suite.Run(t, new(ReplicaRoutingTestSuite))
+}
+
+// Хранилище, которое запоминает, была ли запись в сессии запроса на момент чтения поста
+type sessionRecorder struct {
+	*memory.InMemoryStorage
+	mu    sync.Mutex
+	wrote []bool
+}
+
+type boundRecorder struct {
+	*sessionRecorder
+	session *storage.Session
+}
+
+func (s *sessionRecorder) WithContext(ctx context.Context) storage.Storage {
+	return &boundRecorder{sessionRecorder: s, session: storage.SessionFrom(ctx)}
+}
+
+func (b *boundRecorder) GetPost(postID string) (*model.Post, error) {
+	b.mu.Lock()
+	b.wrote = append(b.wrote, b.session.Wrote())
+	b.mu.Unlock()
+	return b.InMemoryStorage.GetPost(postID)
+}
+
+// Мутация целиком читает из основной базы, запрос - с реплики
+func TestSessions_MutationReadsPrimary(t *testing.T) {
+	recorder := &sessionRecorder{InMemoryStorage: memory.New()}
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: recorder})))
+	srv.AddTransport(transport.POST{})
+	srv.Use(graph.Sessions{})
+	c := client.New(auth.New(nil).Middleware(srv))
+
+	var post struct{ NewPost struct{ ID string } }
+	c.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &post, asUser("author"))
+
+	var get struct{ GetPost struct{ ID string } }
+	c.MustPost(`query($id: ID!) { getPost(postID: $id) { id } }`, &get, client.Var("id", post.NewPost.ID))
+
+	var edit struct{ EditPost struct{ ID string } }
+	c.MustPost(`mutation($id: ID!) { editPost(postID: $id, text: "edited") { id } }`, &edit,
+		client.Var("id", post.NewPost.ID), asUser("author"))
+
+	assert.Equal(t, []bool{false, true}, recorder.wrote)
+}
@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/graph/model"
+	"PostAndComment/ratelimit"
+	"PostAndComment/rest"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tenant"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type RESTTestSuite struct {
+	suite.Suite
+	storage storage.Storage
+	rest    *rest.Handler
+	handler http.Handler
+	graphql *client.Client
+}
+
+// Ответ API: статус, заголовки и тело
+type restResponse struct {
+	*httptest.ResponseRecorder
+}
+
+func (r restResponse) decode(t *testing.T, dst any) {
+	require.NoError(t, json.Unmarshal(r.Body.Bytes(), dst), r.Body.String())
+}
+
+type restPost struct {
+	ID, Text, Status string
+	CommentCount     int32
+	Version          int32
+}
+
+type restComment struct {
+	ID, Text string
+	Replies  []restComment
+}
+
+type restError struct {
+	Message, Code  string
+	CurrentVersion *int32
+}
+
+func (suite *RESTTestSuite) SetupTest() {
+	suite.storage = memory.New()
+	resolver := &graph.Resolver{Storage: suite.storage}
+	spaces, err := tenant.New(nil)
+	require.NoError(suite.T(), err)
+	authenticator := auth.New([]string{"moderator"})
+
+	suite.rest = rest.New(resolver, nil)
+	suite.handler = spaces.Middleware(authenticator.Middleware(suite.rest))
+
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.AddTransport(transport.POST{})
+	suite.graphql = client.New(authenticator.Middleware(srv))
+}
+
+// Запрос к API от имени userID (пустой - анонимно), body сериализуется в JSON
+func (suite *RESTTestSuite) do(method, path, userID string, body any, headers ...string) restResponse {
+	var reader *strings.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(suite.T(), err)
+		reader = strings.NewReader(string(data))
+	} else {
+		reader = strings.NewReader("")
+	}
+
+	req := httptest.NewRequest(method, rest.Prefix+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set(auth.UserIDHeader, userID)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	suite.handler.ServeHTTP(w, req)
+	return restResponse{w}
+}
+
+func (suite *RESTTestSuite) createPost(userID string, body map[string]any) restPost {
+	resp := suite.do(http.MethodPost, "/posts", userID, body)
+	require.Equal(suite.T(), http.StatusCreated, resp.Code, resp.Body.String())
+	var post restPost
+	resp.decode(suite.T(), &post)
+	return post
+}
+
+func (suite *RESTTestSuite) assertError(resp restResponse, status int, code string) restError {
+	assert.Equal(suite.T(), status, resp.Code, resp.Body.String())
+	var body restError
+	resp.decode(suite.T(), &body)
+	assert.Equal(suite.T(), code, body.Code)
+	return body
+}
+
+// Пост и комментарии: создание, чтение, список и дерево ответов
+func (suite *RESTTestSuite) TestPostsAndComments() {
+	post := suite.createPost("author", map[string]any{"title": "Title", "text": "Hello **world**", "tags": []string{"go"}})
+	assert.Equal(suite.T(), "PUBLISHED", post.Status)
+
+	resp := suite.do(http.MethodPost, "/posts/"+post.ID+"/comments", "alice", map[string]any{"text": "Root"})
+	require.Equal(suite.T(), http.StatusCreated, resp.Code, resp.Body.String())
+	var root restComment
+	resp.decode(suite.T(), &root)
+	resp = suite.do(http.MethodPost, "/posts/"+post.ID+"/comments", "bob", map[string]any{"text": "Reply", "parentID": root.ID})
+	require.Equal(suite.T(), http.StatusCreated, resp.Code, resp.Body.String())
+
+	resp = suite.do(http.MethodGet, "/posts/"+post.ID, "", nil)
+	require.Equal(suite.T(), http.StatusOK, resp.Code)
+	var stored restPost
+	resp.decode(suite.T(), &stored)
+	assert.Equal(suite.T(), int32(2), stored.CommentCount)
+
+	resp = suite.do(http.MethodGet, "/posts/"+post.ID+"?format=html", "", nil)
+	resp.decode(suite.T(), &stored)
+	assert.Contains(suite.T(), stored.Text, "<strong>world</strong>")
+
+	resp = suite.do(http.MethodGet, "/posts/"+post.ID+"/comments", "", nil)
+	require.Equal(suite.T(), http.StatusOK, resp.Code)
+	var comments struct{ Comments []restComment }
+	resp.decode(suite.T(), &comments)
+	require.Len(suite.T(), comments.Comments, 1)
+	assert.Equal(suite.T(), "Root", comments.Comments[0].Text)
+	require.Len(suite.T(), comments.Comments[0].Replies, 1)
+	assert.Equal(suite.T(), "Reply", comments.Comments[0].Replies[0].Text)
+
+	resp = suite.do(http.MethodGet, "/posts?limit=10", "", nil)
+	var posts struct{ Posts []restPost }
+	resp.decode(suite.T(), &posts)
+	require.Len(suite.T(), posts.Posts, 1)
+	assert.Equal(suite.T(), post.ID, posts.Posts[0].ID)
+
+	resp = suite.do(http.MethodGet, "/tags/go/posts?first=1", "", nil)
+	var tagged struct {
+		Posts    []restPost
+		PageInfo struct{ HasNextPage bool }
+	}
+	resp.decode(suite.T(), &tagged)
+	assert.Len(suite.T(), tagged.Posts, 1)
+	assert.False(suite.T(), tagged.PageInfo.HasNextPage)
+
+	resp = suite.do(http.MethodGet, "/tags?prefix=g", "", nil)
+	var tags struct{ Tags []struct{ Name string } }
+	resp.decode(suite.T(), &tags)
+	require.Len(suite.T(), tags.Tags, 1)
+	assert.Equal(suite.T(), "go", tags.Tags[0].Name)
+}
+
+// Проверки аргументов те же, что у GraphQL, с тем же текстом ошибки
+func (suite *RESTTestSuite) TestValidation() {
+	body := suite.assertError(suite.do(http.MethodPost, "/posts", "", map[string]any{"text": strings.Repeat("a", 2001)}),
+		http.StatusBadRequest, "INVALID_ARGUMENT")
+	assert.Equal(suite.T(), "message too long: maximum allowed is 2000 characters", body.Message)
+	suite.assertError(suite.do(http.MethodGet, "/posts?limit=1000", "", nil), http.StatusBadRequest, "INVALID_ARGUMENT")
+	suite.assertError(suite.do(http.MethodGet, "/tags/go/posts?after=bad", "", nil), http.StatusBadRequest, "INVALID_ARGUMENT")
+
+	suite.assertError(suite.do(http.MethodPost, "/posts", "", map[string]any{"text": "post", "unknown": 1}),
+		http.StatusBadRequest, "INVALID_ARGUMENT")
+	suite.assertError(suite.do(http.MethodPost, "/posts", "author", map[string]any{"text": "post", "status": "LATER"}),
+		http.StatusBadRequest, "INVALID_ARGUMENT")
+	suite.assertError(suite.do(http.MethodGet, "/posts?limit=many", "", nil), http.StatusBadRequest, "INVALID_ARGUMENT")
+	suite.assertError(suite.do(http.MethodGet, "/posts?createdAfter=yesterday", "", nil), http.StatusBadRequest, "INVALID_ARGUMENT")
+	suite.assertError(suite.do(http.MethodGet, "/posts?format=pdf", "", nil), http.StatusBadRequest, "INVALID_ARGUMENT")
+}
+
+// Права доступа: аноним - 401, чужой пост - 403, черновик чужого автора - 404
+func (suite *RESTTestSuite) TestAccess() {
+	post := suite.createPost("author", map[string]any{"text": "post"})
+
+	suite.assertError(suite.do(http.MethodPatch, "/posts/"+post.ID, "", map[string]any{"text": "edited"}),
+		http.StatusUnauthorized, "UNAUTHENTICATED")
+	suite.assertError(suite.do(http.MethodPatch, "/posts/"+post.ID, "mallory", map[string]any{"text": "edited"}),
+		http.StatusForbidden, "ACCESS_DENIED")
+
+	resp := suite.do(http.MethodPatch, "/posts/"+post.ID, "author", map[string]any{"text": "edited"})
+	require.Equal(suite.T(), http.StatusOK, resp.Code, resp.Body.String())
+	var edited restPost
+	resp.decode(suite.T(), &edited)
+	assert.Equal(suite.T(), "edited", edited.Text)
+
+	draft := suite.createPost("author", map[string]any{"text": "draft", "status": "DRAFT"})
+	suite.assertError(suite.do(http.MethodGet, "/posts/"+draft.ID, "mallory", nil), http.StatusNotFound, "NOT_FOUND")
+	suite.assertError(suite.do(http.MethodGet, "/posts/"+draft.ID+"/comments", "", nil), http.StatusNotFound, "NOT_FOUND")
+	assert.Equal(suite.T(), http.StatusOK, suite.do(http.MethodGet, "/posts/"+draft.ID, "author", nil).Code)
+
+	resp = suite.do(http.MethodPost, "/posts/"+draft.ID+"/publish", "author", nil)
+	require.Equal(suite.T(), http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(suite.T(), http.StatusOK, suite.do(http.MethodGet, "/posts/"+draft.ID, "mallory", nil).Code)
+
+	suite.assertError(suite.do(http.MethodGet, "/posts/missing", "", nil), http.StatusNotFound, "NOT_FOUND")
+	suite.assertError(suite.do(http.MethodPost, "/posts/missing/comments", "", map[string]any{"text": "hi"}),
+		http.StatusNotFound, "NOT_FOUND")
+}
+
+// Правила обсуждения и версии: коды ошибок совпадают с GraphQL
+func (suite *RESTTestSuite) TestConflicts() {
+	post := suite.createPost("author", map[string]any{"text": "post", "commentsEnabled": false})
+	suite.assertError(suite.do(http.MethodPost, "/posts/"+post.ID+"/comments", "", map[string]any{"text": "hi"}),
+		http.StatusForbidden, "COMMENTS_DISABLED")
+
+	body := suite.assertError(suite.do(http.MethodPatch, "/posts/"+post.ID, "author", map[string]any{"text": "edited", "expectedVersion": 5}),
+		http.StatusConflict, "CONFLICT")
+	require.NotNil(suite.T(), body.CurrentVersion)
+	assert.Equal(suite.T(), post.Version, *body.CurrentVersion)
+
+	resp := suite.do(http.MethodPatch, "/posts/"+post.ID, "author", map[string]any{"text": "edited", "expectedVersion": post.Version})
+	assert.Equal(suite.T(), http.StatusOK, resp.Code, resp.Body.String())
+}
+
+// Повтор с тем же Idempotency-Key возвращает первый ответ, ключ общий с clientMutationId GraphQL
+func (suite *RESTTestSuite) TestIdempotencyKey() {
+	first := suite.do(http.MethodPost, "/posts", "author", map[string]any{"text": "post"}, rest.IdempotencyKeyHeader, "key-1")
+	second := suite.do(http.MethodPost, "/posts", "author", map[string]any{"text": "post"}, rest.IdempotencyKeyHeader, "key-1")
+	require.Equal(suite.T(), http.StatusCreated, first.Code)
+	require.Equal(suite.T(), http.StatusCreated, second.Code)
+
+	var firstPost, secondPost restPost
+	first.decode(suite.T(), &firstPost)
+	second.decode(suite.T(), &secondPost)
+	assert.Equal(suite.T(), firstPost.ID, secondPost.ID)
+
+	var resp struct{ NewPost struct{ ID string } }
+	suite.graphql.MustPost(`mutation { newPost(text: "post", commentsEnabled: true, clientMutationId: "key-1") { id } }`,
+		&resp, asUser("author"))
+	assert.Equal(suite.T(), firstPost.ID, resp.NewPost.ID)
+
+	posts, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), posts, 1)
+}
+
+// Пространство выбирается так же, как для GraphQL
+func (suite *RESTTestSuite) TestSpaces() {
+	spaces, err := tenant.New([]tenant.Space{{ID: "docs"}, {ID: storage.DefaultSpace}})
+	require.NoError(suite.T(), err)
+	suite.handler = spaces.Middleware(auth.New(nil).Middleware(suite.rest))
+
+	resp := suite.do(http.MethodPost, "/posts", "", map[string]any{"text": "docs"}, tenant.SpaceHeader, "docs")
+	require.Equal(suite.T(), http.StatusCreated, resp.Code)
+	var post restPost
+	resp.decode(suite.T(), &post)
+
+	suite.assertError(suite.do(http.MethodGet, "/posts/"+post.ID, "", nil), http.StatusNotFound, "NOT_FOUND")
+	assert.Equal(suite.T(), http.StatusOK, suite.do(http.MethodGet, "/posts/"+post.ID, "", nil, tenant.SpaceHeader, "docs").Code)
+}
+
+// Запросы списывают токены из бакетов GraphQL: GET - query, остальные - mutation
+func (suite *RESTTestSuite) TestRateLimit() {
+	limits := &ratelimit.Extension{
+		Store:  ratelimit.NewMemoryStore(),
+		Limits: map[ast.Operation]ratelimit.Limit{ast.Mutation: {Rate: 0.001, Burst: 1}},
+	}
+	suite.handler = ratelimit.Middleware(ratelimit.ClientOptions{})(rest.New(&graph.Resolver{Storage: suite.storage}, limits))
+
+	suite.createPost("", map[string]any{"text": "first"})
+	resp := suite.do(http.MethodPost, "/posts", "", map[string]any{"text": "second"})
+	suite.assertError(resp, http.StatusTooManyRequests, ratelimit.ErrorCode)
+	assert.NotEmpty(suite.T(), resp.Header().Get("Retry-After"))
+
+	assert.Equal(suite.T(), http.StatusOK, suite.do(http.MethodGet, "/posts", "", nil).Code)
+}
+
+// Описание API перечисляет ровно те маршруты, что обслуживает обработчик
+// Анонимный комментарий в медленном режиме - 401
+func (suite *RESTTestSuite) TestSlowModeAnonymous() {
+	post := suite.createPost("author", map[string]any{"text": "post"})
+	_, err := suite.storage.SetSlowMode(post.ID, 30, nil)
+	require.NoError(suite.T(), err)
+
+	suite.assertError(suite.do(http.MethodPost, "/posts/"+post.ID+"/comments", "", map[string]any{"text": "comment"}),
+		http.StatusUnauthorized, "UNAUTHENTICATED")
+}
+
+// Хранилище, чтение из которого падает с внутренней ошибкой
+type failingStorage struct {
+	storage.Storage
+}
+
+func (s failingStorage) GetPosts(limit, offset int32, createdIn storage.TimeRange) ([]*model.Post, error) {
+	return nil, errors.New("pq: password authentication failed for user \"blog\"")
+}
+
+// Ошибка без вида - 500 с общим сообщением, ее текст клиенту не отдается
+func (suite *RESTTestSuite) TestInternalError() {
+	handler := rest.New(&graph.Resolver{Storage: failingStorage{memory.New()}}, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, rest.Prefix+"/posts", nil))
+
+	body := suite.assertError(restResponse{w}, http.StatusInternalServerError, "INTERNAL")
+	assert.Equal(suite.T(), "internal server error", body.Message)
+	assert.NotContains(suite.T(), w.Body.String(), "password")
+}
+
+func (suite *RESTTestSuite) TestOpenAPI() {
+	resp := suite.do(http.MethodGet, "/openapi.json", "", nil)
+	require.Equal(suite.T(), http.StatusOK, resp.Code)
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	resp.decode(suite.T(), &doc)
+	assert.True(suite.T(), strings.HasPrefix(doc.OpenAPI, "3."))
+
+	var documented []string
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			documented = append(documented, strings.ToUpper(method)+" "+rest.Prefix+path)
+		}
+	}
+	routes := suite.rest.Routes()
+	sort.Strings(documented)
+	sort.Strings(routes)
+	assert.Equal(suite.T(), routes, documented)
+
+	suite.assertError(suite.do(http.MethodGet, "/unknown", "", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestRESTTestSuite(t *testing.T) {
+	suite.Run(t, new(RESTTestSuite))
+}
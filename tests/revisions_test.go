@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/diff"
+	"PostAndComment/graph"
+	"PostAndComment/graph/model"
+	"PostAndComment/storage/memory"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RevisionsTestSuite struct {
+	suite.Suite
+	storage *memory.InMemoryStorage
+	client  *client.Client
+}
+
+type diffChunk struct {
+	Op   string
+	Text string
+}
+
+func (suite *RevisionsTestSuite) SetupTest() {
+	suite.storage = memory.New()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: suite.storage})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+// Склеенные участки Equal+Delete дают старый текст, Equal+Insert - новый
+func (suite *RevisionsTestSuite) TestWords() {
+	chunks := diff.Words("the quick brown fox", "the slow brown fox!")
+	assert.Equal(suite.T(), []diff.Chunk{
+		{Op: diff.Equal, Text: "the "},
+		{Op: diff.Delete, Text: "quick"},
+		{Op: diff.Insert, Text: "slow"},
+		{Op: diff.Equal, Text: " brown fox"},
+		{Op: diff.Insert, Text: "!"},
+	}, chunks)
+
+	assert.Empty(suite.T(), diff.Words("", ""))
+	assert.Equal(suite.T(), []diff.Chunk{{Op: diff.Insert, Text: "new text"}}, diff.Words("", "new text"))
+}
+
+func (suite *RevisionsTestSuite) TestLines() {
+	chunks := diff.Lines("one\ntwo\nthree", "one\n2\nthree")
+	assert.Equal(suite.T(), []diff.Chunk{
+		{Op: diff.Equal, Text: "one\n"},
+		{Op: diff.Delete, Text: "two\n"},
+		{Op: diff.Insert, Text: "2\n"},
+		{Op: diff.Equal, Text: "three"},
+	}, chunks)
+}
+
+// Правка поста автором, версии и разница между ними
+func (suite *RevisionsTestSuite) TestEditPost() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "hello old world", commentsEnabled: true) { id } }`, &post, asUser("author"))
+	id := post.NewPost.ID
+
+	const edit = `mutation($id: ID!, $text: String!) { editPost(postID: $id, text: $text) { text editedAt } }`
+	var resp struct {
+		EditPost struct {
+			Text     string
+			EditedAt *string
+		}
+	}
+	err := suite.client.Post(edit, &resp, client.Var("id", id), client.Var("text", "hacked"), asUser("stranger"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "access denied")
+
+	err = suite.client.Post(edit, &resp, client.Var("id", id), client.Var("text", ""), asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "at least one character")
+
+	suite.client.MustPost(edit, &resp, client.Var("id", id), client.Var("text", "hello new world"), asUser("author"))
+	assert.Equal(suite.T(), "hello new world", resp.EditPost.Text)
+	assert.NotNil(suite.T(), resp.EditPost.EditedAt)
+
+	var history struct {
+		GetPost struct {
+			Revisions []struct {
+				Number   int
+				Text     string
+				EditorID *string
+			}
+			Diff []diffChunk
+		}
+	}
+	suite.client.MustPost(`query($id: ID!) {
+		getPost(postID: $id) { revisions { number text editorID } diff(fromRevision: 1, toRevision: 2) { op text } }
+	}`, &history, client.Var("id", id))
+
+	require.Len(suite.T(), history.GetPost.Revisions, 2)
+	assert.Equal(suite.T(), "hello old world", history.GetPost.Revisions[0].Text)
+	assert.Equal(suite.T(), 2, history.GetPost.Revisions[1].Number)
+	require.NotNil(suite.T(), history.GetPost.Revisions[1].EditorID)
+	assert.Equal(suite.T(), "author", *history.GetPost.Revisions[1].EditorID)
+	assert.Equal(suite.T(), []diffChunk{
+		{Op: string(model.DiffOpEqual), Text: "hello "},
+		{Op: string(model.DiffOpDelete), Text: "old"},
+		{Op: string(model.DiffOpInsert), Text: "new"},
+		{Op: string(model.DiffOpEqual), Text: " world"},
+	}, history.GetPost.Diff)
+
+	err = suite.client.Post(`query($id: ID!) { getPost(postID: $id) { diff(fromRevision: 1, toRevision: 5) { op } } }`,
+		&history, client.Var("id", id))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "revision 5 not found")
+}
+
+// Комментарий правит автор или модератор, скрытый модерацией - только модератор
+func (suite *RevisionsTestSuite) TestEditComment() {
+	var post struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &post, asUser("author"))
+
+	var comment struct{ AddComment struct{ ID string } }
+	suite.client.MustPost(`mutation($id: ID!) { addComment(postID: $id, text: "line one\nline two") { id } }`,
+		&comment, client.Var("id", post.NewPost.ID), asUser("commenter"))
+	id := comment.AddComment.ID
+
+	const edit = `mutation($id: ID!, $text: String!) { editComment(commentID: $id, text: $text) { text } }`
+	var resp struct{ EditComment struct{ Text string } }
+	err := suite.client.Post(edit, &resp, client.Var("id", id), client.Var("text", "spam"), asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "access denied")
+
+	suite.client.MustPost(edit, &resp, client.Var("id", id), client.Var("text", "line one\nline 2"), asUser("commenter"))
+	assert.Equal(suite.T(), "line one\nline 2", resp.EditComment.Text)
+
+	const history = `query($id: ID!) {
+		getPost(postID: $id) { comments { revisions { number } diff(fromRevision: 1, toRevision: 2, unit: LINE) { op text } } }
+	}`
+	var tree struct {
+		GetPost struct {
+			Comments []struct {
+				Revisions []struct{ Number int }
+				Diff      []diffChunk
+			}
+		}
+	}
+	suite.client.MustPost(history, &tree, client.Var("id", post.NewPost.ID))
+	require.Len(suite.T(), tree.GetPost.Comments, 1)
+	assert.Len(suite.T(), tree.GetPost.Comments[0].Revisions, 2)
+	assert.Equal(suite.T(), []diffChunk{
+		{Op: string(model.DiffOpEqual), Text: "line one\n"},
+		{Op: string(model.DiffOpDelete), Text: "line two"},
+		{Op: string(model.DiffOpInsert), Text: "line 2"},
+	}, tree.GetPost.Comments[0].Diff)
+
+	_, err = suite.storage.ModerateComment(id, "moderator", model.ModerationActionHide, nil, nil)
+	require.NoError(suite.T(), err)
+
+	err = suite.client.Post(edit, &resp, client.Var("id", id), client.Var("text", "sneaky"), asUser("commenter"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "hidden by moderation")
+	suite.client.MustPost(edit, &resp, client.Var("id", id), client.Var("text", "cleaned up"), asUser("moderator"))
+
+	var hidden struct {
+		GetPost struct {
+			Comments []struct{ Revisions []struct{ Number int } }
+		}
+	}
+	suite.client.MustPost(`query($id: ID!) { getPost(postID: $id) { comments { revisions { number } } } }`,
+		&hidden, client.Var("id", post.NewPost.ID))
+	require.Len(suite.T(), hidden.GetPost.Comments, 1)
+	assert.Empty(suite.T(), hidden.GetPost.Comments[0].Revisions)
+}
+
+// Запуск тестов
+func TestRevisionsTestSuite(t *testing.T) {
+	suite.Run(t, new(RevisionsTestSuite))
+}
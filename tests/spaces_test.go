@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tenant"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpacesTestSuite struct {
+	suite.Suite
+	storage storage.Storage
+	handler http.Handler
+	client  *client.Client
+}
+
+func (suite *SpacesTestSuite) SetupTest() {
+	spaces, err := tenant.ParseSpaces("docs=docs.example.com|Docs.Local:8080, blog=blog.example.com, default")
+	require.NoError(suite.T(), err)
+	resolver, err := tenant.New(spaces)
+	require.NoError(suite.T(), err)
+
+	suite.storage = memory.New()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: suite.storage})))
+	srv.AddTransport(transport.POST{})
+	srv.Use(graph.Dataloaders{Storage: suite.storage})
+
+	suite.handler = resolver.Middleware(auth.New([]string{"moderator"}).Middleware(srv))
+	suite.client = client.New(suite.handler)
+}
+
+func inSpace(id string) client.Option {
+	return client.AddHeader(tenant.SpaceHeader, id)
+}
+
+func onHost(host string) client.Option {
+	return func(bd *client.Request) { bd.HTTP.Host = host }
+}
+
+func (suite *SpacesTestSuite) newPost(options ...client.Option) string {
+	var resp struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id } }`, &resp, options...)
+	return resp.NewPost.ID
+}
+
+func (suite *SpacesTestSuite) postCount(options ...client.Option) int {
+	var resp struct{ GetPosts []struct{ ID string } }
+	suite.client.MustPost(`{ getPosts { id } }`, &resp, options...)
+	return len(resp.GetPosts)
+}
+
+// Пространство выбирается заголовком, затем хостом (без порта и регистра), иначе - default
+func (suite *SpacesTestSuite) TestResolve() {
+	var resp struct{ Space struct{ ID string } }
+	suite.client.MustPost(`{ space { id } }`, &resp, inSpace("blog"))
+	assert.Equal(suite.T(), "blog", resp.Space.ID)
+
+	suite.client.MustPost(`{ space { id } }`, &resp, onHost("docs.local:8080"))
+	assert.Equal(suite.T(), "docs", resp.Space.ID)
+
+	suite.client.MustPost(`{ space { id } }`, &resp, onHost("docs.example.com"), inSpace("blog"))
+	assert.Equal(suite.T(), "blog", resp.Space.ID)
+
+	suite.client.MustPost(`{ space { id } }`, &resp, onHost("unknown.example.com"))
+	assert.Equal(suite.T(), storage.DefaultSpace, resp.Space.ID)
+}
+
+// Запрос к неизвестному пространству получает 404
+func (suite *SpacesTestSuite) TestUnknownSpace() {
+	var resp struct{ Space struct{ ID string } }
+	err := suite.client.Post(`{ space { id } }`, &resp, inSpace("other"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "http 404")
+}
+
+// Без пространства по умолчанию в списке запросы с незнакомым хостом отклоняются
+func (suite *SpacesTestSuite) TestNoDefaultSpace() {
+	resolver, err := tenant.New([]tenant.Space{{ID: "docs", Hosts: []string{"docs.example.com"}}})
+	require.NoError(suite.T(), err)
+	h := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(suite.T(), "docs", storage.SpaceFrom(r.Context()))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://other.example.com/query", nil))
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://docs.example.com/query", nil))
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+// Пост одного пространства не виден и не изменяется из другого
+func (suite *SpacesTestSuite) TestCrossSpaceAccess() {
+	postID := suite.newPost(inSpace("docs"))
+
+	assert.Equal(suite.T(), 1, suite.postCount(onHost("docs.example.com")))
+	assert.Equal(suite.T(), 0, suite.postCount(inSpace("blog")))
+	assert.Equal(suite.T(), 0, suite.postCount())
+
+	var post struct{ GetPost struct{ ID string } }
+	err := suite.client.Post(`query($id: ID!) { getPost(postID: $id) { id } }`, &post, client.Var("id", postID), inSpace("blog"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "not found")
+
+	var comment struct{ AddComment struct{ ID string } }
+	err = suite.client.Post(`mutation($id: ID!) { addComment(postID: $id, text: "intruder") { id } }`, &comment,
+		client.Var("id", postID), inSpace("blog"))
+	require.Error(suite.T(), err)
+
+	comments, err := storage.ForContext(storage.WithSpace(context.Background(), "docs"), suite.storage).
+		GetCommentsTree(postID, 10, 0, storage.TimeRange{})
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), comments)
+}
+
+func TestSpacesTestSuite(t *testing.T) {
+	suite.Run(t, new(SpacesTestSuite))
+}
+
+func TestParseSpaces(t *testing.T) {
+	spaces, err := tenant.ParseSpaces("docs=docs.example.com|docs.local, blog ,")
+	require.NoError(t, err)
+	assert.Equal(t, []tenant.Space{
+		{ID: "docs", Hosts: []string{"docs.example.com", "docs.local"}},
+		{ID: "blog"},
+	}, spaces)
+
+	_, err = tenant.ParseSpaces("=docs.example.com")
+	assert.Error(t, err)
+	_, err = tenant.New([]tenant.Space{{ID: "Docs"}})
+	assert.ErrorIs(t, err, storage.ErrInvalidArgument)
+	_, err = tenant.New([]tenant.Space{{ID: "docs", Hosts: []string{"a.example.com"}}, {ID: "blog", Hosts: []string{"A.example.com"}}})
+	assert.Error(t, err)
+}
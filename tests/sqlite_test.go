@@ -0,0 +1,17 @@
+package tests
+
+import (
+	"PostAndComment/storage"
+	"PostAndComment/storage/sqlite"
+	"PostAndComment/tests/conformance"
+	"PostAndComment/tests/testutils"
+	"testing"
+)
+
+func TestSQLiteStorageTestSuite(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) storage.Storage {
+		db := testutils.SetupTestSQLite(t)
+		t.Cleanup(func() { db.Close() })
+		return sqlite.New(db)
+	})
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"PostAndComment/graph"
+	"PostAndComment/storage/memory"
+	"fmt"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TagsTestSuite struct {
+	suite.Suite
+	client *client.Client
+}
+
+type postsByTagResponse struct {
+	PostsByTag struct {
+		Edges []struct {
+			Cursor string
+			Node   struct{ Title string }
+		}
+		PageInfo struct {
+			EndCursor   *string
+			HasNextPage bool
+		}
+	}
+}
+
+const postsByTagQuery = `query($after: String) {
+	postsByTag(tag: "go", first: 2, after: $after) {
+		edges { cursor node { title } }
+		pageInfo { endCursor hasNextPage }
+	}
+}`
+
+func (suite *TagsTestSuite) SetupTest() {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(
+		&graph.Resolver{Storage: memory.New()})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(srv)
+}
+
+func (suite *TagsTestSuite) createPost(title string, tags ...string) {
+	var resp struct{ NewPost struct{ ID string } }
+	suite.client.MustPost(`mutation($title: String, $tags: [String!]) {
+		newPost(title: $title, text: "text", tags: $tags, commentsEnabled: true) { id }
+	}`, &resp, client.Var("title", title), client.Var("tags", tags))
+}
+
+// Постраничный обход ленты по тегу через курсор
+func (suite *TagsTestSuite) TestPostsByTag_Pages() {
+	for i := 1; i <= 3; i++ {
+		suite.createPost(fmt.Sprintf("Post %d", i), "go")
+	}
+	suite.createPost("Other", "rust")
+
+	var first postsByTagResponse
+	suite.client.MustPost(postsByTagQuery, &first)
+
+	require.Len(suite.T(), first.PostsByTag.Edges, 2)
+	assert.Equal(suite.T(), "Post 3", first.PostsByTag.Edges[0].Node.Title)
+	assert.Equal(suite.T(), "Post 2", first.PostsByTag.Edges[1].Node.Title)
+	assert.True(suite.T(), first.PostsByTag.PageInfo.HasNextPage)
+	require.NotNil(suite.T(), first.PostsByTag.PageInfo.EndCursor)
+	assert.Equal(suite.T(), first.PostsByTag.Edges[1].Cursor, *first.PostsByTag.PageInfo.EndCursor)
+
+	var second postsByTagResponse
+	suite.client.MustPost(postsByTagQuery, &second, client.Var("after", *first.PostsByTag.PageInfo.EndCursor))
+
+	require.Len(suite.T(), second.PostsByTag.Edges, 1)
+	assert.Equal(suite.T(), "Post 1", second.PostsByTag.Edges[0].Node.Title)
+	assert.False(suite.T(), second.PostsByTag.PageInfo.HasNextPage)
+}
+
+// Некорректный курсор
+func (suite *TagsTestSuite) TestPostsByTag_InvalidCursor() {
+	var resp postsByTagResponse
+	err := suite.client.Post(postsByTagQuery, &resp, client.Var("after", "not a cursor"))
+
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "invalid cursor")
+}
+
+// Подсказки тегов с числом постов
+func (suite *TagsTestSuite) TestTagsAutocomplete() {
+	suite.createPost("Post 1", "go", "golang")
+	suite.createPost("Post 2", "Go")
+
+	var resp struct {
+		Tags []struct {
+			Name      string
+			PostCount int
+		}
+	}
+	suite.client.MustPost(`query { tags(prefix: "go") { name postCount } }`, &resp)
+
+	require.Len(suite.T(), resp.Tags, 2)
+	assert.Equal(suite.T(), "go", resp.Tags[0].Name)
+	assert.Equal(suite.T(), 2, resp.Tags[0].PostCount)
+	assert.Equal(suite.T(), "golang", resp.Tags[1].Name)
+	assert.Equal(suite.T(), 1, resp.Tags[1].PostCount)
+}
+
+// Запуск тестов
+func TestTagsTestSuite(t *testing.T) {
+	suite.Run(t, new(TagsTestSuite))
+}
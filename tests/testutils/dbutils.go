@@ -1,107 +1,131 @@
 package testutils
 
 import (
+	"PostAndComment/storage/migrations"
+	"PostAndComment/storage/postgres"
+	"PostAndComment/storage/sqlite"
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
 // SetupTestDB создает и настраивает тестовую базу данных
-func SetupTestDB(t *testing.T) *sql.DB {
+func SetupTestDB(t testing.TB) *sql.DB {
 	t.Helper()
 
-	dbHost := getEnv("TEST_DB_HOST", "localhost")
-	dbPort := getEnv("TEST_DB_PORT", "5433")
-	dbUser := getEnv("TEST_DB_USER", "testuser")
-	dbPassword := getEnv("TEST_DB_PASSWORD", "testpass")
-	dbName := getEnv("TEST_DB_NAME", "test_comments_db")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
-
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("pgx", testConnString())
 	if err != nil {
-		t.Skipf("Failed to connect to test database: %v", err)
+		skipWithoutDatabase(t, "Failed to connect to test database: %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
-		t.Skipf("Failed to ping test database: %v", err)
+		skipWithoutDatabase(t, "Failed to ping test database: %v", err)
 	}
 
 	createTestTables(t, db)
 	return db
 }
 
-// CleanTestDB очищает тестовую БД
-func CleanTestDB(t *testing.T, db *sql.DB) {
+// SetupTestSQLite открывает новую базу SQLite во временном каталоге теста
+func SetupTestSQLite(t *testing.T) *sql.DB {
 	t.Helper()
-	_, err := db.Exec("TRUNCATE TABLE posts CASCADE")
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
-		t.Logf("Warning: failed to truncate tables: %v", err)
+		t.Fatalf("Failed to open SQLite database: %v", err)
 	}
+	return db
 }
 
-// createTestTables создание таблиц для тестов
-func createTestTables(t *testing.T, db *sql.DB) {
+// SetupTestPool создает таблицы, как SetupTestDB, и возвращает пул pgx для хранилища Postgres
+func SetupTestPool(t testing.TB) *pgxpool.Pool {
 	t.Helper()
 
-	// Удаляем таблицы если существуют
-	_, err := db.Exec("DROP TABLE IF EXISTS comments CASCADE")
+	SetupTestDB(t).Close()
+	return OpenTestPool(t)
+}
+
+// OpenTestPool открывает еще один пул соединений с тестовой БД без пересоздания таблиц (например, как реплику)
+func OpenTestPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := postgres.Connect(context.Background(), testConnString())
 	if err != nil {
-		t.Fatalf("Failed to drop comments table: %v", err)
+		t.Fatalf("Failed to open test database: %v", err)
 	}
+	return pool
+}
+
+// TestPoolConfig - настройки пула тестовой БД, которые тест может поменять перед подключением
+func TestPoolConfig(t testing.TB) *pgxpool.Config {
+	t.Helper()
 
-	_, err = db.Exec("DROP TABLE IF EXISTS posts CASCADE")
+	config, err := postgres.ParseConfig(testConnString())
 	if err != nil {
-		t.Fatalf("Failed to drop posts table: %v", err)
+		t.Fatalf("Failed to parse test connection string: %v", err)
+	}
+	return config
+}
+
+// Без БД тесты Postgres пропускаются, а с TEST_DB_REQUIRED=true (в CI) падают:
+// иначе недоступная БД незаметно выключила бы все тесты Postgres
+func skipWithoutDatabase(t testing.TB, format string, args ...any) {
+	t.Helper()
+	if os.Getenv("TEST_DB_REQUIRED") == "true" {
+		t.Fatalf(format, args...)
 	}
+	t.Skipf(format, args...)
+}
+
+// Строка подключения к тестовой БД из переменных окружения
+func testConnString() string {
+	dbHost := getEnv("TEST_DB_HOST", "localhost")
+	dbPort := getEnv("TEST_DB_PORT", "5433")
+	dbUser := getEnv("TEST_DB_USER", "testuser")
+	dbPassword := getEnv("TEST_DB_PASSWORD", "testpass")
+	dbName := getEnv("TEST_DB_NAME", "test_comments_db")
 
-	// Создаем таблицу постов
-	createPostsTable := `
-        CREATE TABLE posts (
-            id VARCHAR(36) PRIMARY KEY,
-            text TEXT NOT NULL,
-            comments_enabled BOOLEAN NOT NULL DEFAULT true,
-            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-        )`
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dbHost, dbPort, dbUser, dbPassword, dbName)
+}
 
-	_, err = db.Exec(createPostsTable)
+// CleanTestDB очищает тестовую БД
+func CleanTestDB(t *testing.T, db *sql.DB) {
+	t.Helper()
+	_, err := db.Exec("TRUNCATE TABLE posts, revisions, rate_limits, idempotency_keys CASCADE")
 	if err != nil {
-		t.Fatalf("Failed to create posts table: %v", err)
+		t.Logf("Warning: failed to truncate tables: %v", err)
 	}
+}
 
-	// Создаем таблицу комментариев
-	createCommentsTable := `
-        CREATE TABLE comments (
-            id VARCHAR(36) PRIMARY KEY,
-            post_id VARCHAR(36) NOT NULL,
-            parent_id VARCHAR(36),
-            text TEXT NOT NULL,
-            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-            FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-            FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
-        )`
-
-	_, err = db.Exec(createCommentsTable)
+// CleanTestPool очищает тестовую БД через пул pgx
+func CleanTestPool(t testing.TB, pool *pgxpool.Pool) {
+	t.Helper()
+	_, err := pool.Exec(context.Background(), "TRUNCATE TABLE posts, revisions, rate_limits, idempotency_keys CASCADE")
 	if err != nil {
-		t.Fatalf("Failed to create comments table: %v", err)
+		t.Logf("Warning: failed to truncate tables: %v", err)
 	}
+}
+
+// createTestTables создание таблиц для тестов: схема с нуля теми же миграциями, что и у сервера
+func createTestTables(t testing.TB, db *sql.DB) {
+	t.Helper()
 
-	// Создаем индексы
-	indexes := []string{
-		"CREATE INDEX idx_comments_post_id ON comments(post_id)",
-		"CREATE INDEX idx_comments_parent_id ON comments(parent_id)",
-		"CREATE INDEX idx_comments_created_at ON comments(created_at)",
+	// Удаляем таблицы если существуют
+	_, err := db.Exec(`DROP TABLE IF EXISTS schema_migrations, reports, moderation_decisions, notifications, revisions,
+		rate_limits, idempotency_keys, comments, post_tags, posts CASCADE`)
+	if err != nil {
+		t.Fatalf("Failed to drop tables: %v", err)
 	}
 
-	for _, index := range indexes {
-		_, err = db.Exec(index)
-		if err != nil {
-			t.Logf("Warning: failed to create index: %v", err)
-		}
+	if err := migrations.Apply(db, migrations.Postgres); err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
 	}
 }
 
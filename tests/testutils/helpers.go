@@ -9,14 +9,14 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func CreateTestPost(t *testing.T, s storage.Storage, text string, commentsEnabled bool) *model.Post {
-	post, err := s.NewPost(text, commentsEnabled)
+func CreateTestPost(t testing.TB, s storage.Storage, text string, commentsEnabled bool) *model.Post {
+	post, err := s.NewPost(storage.NewPostParams{Text: text, CommentsEnabled: commentsEnabled})
 	require.NoError(t, err)
 	return post
 }
 
-func CreateTestComment(t *testing.T, s storage.Storage, postID string, parentID *string, text string) *model.Comment {
-	comment, err := s.AddComment(postID, parentID, text)
+func CreateTestComment(t testing.TB, s storage.Storage, postID string, parentID *string, text string) *model.Comment {
+	comment, err := s.AddComment(storage.NewCommentParams{PostID: postID, ParentID: parentID, Text: text})
 	require.NoError(t, err)
 	return comment
 }
@@ -34,10 +34,11 @@ func AssertPostEqual(t *testing.T, expected, actual *model.Post) {
 	assert.Equal(t, expected.ID, actual.ID)
 	assert.Equal(t, expected.Text, actual.Text)
 	assert.Equal(t, expected.CommentsEnabled, actual.CommentsEnabled)
+	assert.True(t, expected.CreatedAt.Equal(actual.CreatedAt), "createdAt: expected %v, actual %v", expected.CreatedAt, actual.CreatedAt)
 }
 
 // Пропускает тест если нет БД
-func SkipIfNoDatabase(t *testing.T) {
+func SkipIfNoDatabase(t testing.TB) {
 	t.Helper()
 
 	db := SetupTestDB(t)
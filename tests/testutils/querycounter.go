@@ -0,0 +1,68 @@
+package testutils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	queryCount     atomic.Int64
+	roundTripCount atomic.Int64
+)
+
+// Кол-во SQL-запросов, выполненных через SetupCountingTestPool. Запросы пачки считаются по отдельности
+func QueryCount() int64 {
+	return queryCount.Load()
+}
+
+// Кол-во обменов с базой: пачка запросов - один обмен
+func RoundTripCount() int64 {
+	return roundTripCount.Load()
+}
+
+func ResetQueryCount() {
+	queryCount.Store(0)
+	roundTripCount.Store(0)
+}
+
+// SetupCountingTestPool - тестовая БД, подключенная через пул со счетчиком запросов
+func SetupCountingTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	SetupTestDB(t).Close()
+
+	config := TestPoolConfig(t)
+	config.ConnConfig.Tracer = countingTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		skipWithoutDatabase(t, "Failed to connect to test database: %v", err)
+	}
+	return pool
+}
+
+// Трассировщик pgx, считающий запросы и обмены с базой
+type countingTracer struct{}
+
+func (countingTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	queryCount.Add(1)
+	roundTripCount.Add(1)
+	return ctx
+}
+
+func (countingTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (countingTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	roundTripCount.Add(1)
+	return ctx
+}
+
+func (countingTracer) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {
+	queryCount.Add(1)
+}
+
+func (countingTracer) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage"
+	"PostAndComment/storage/memory"
+	"PostAndComment/tests/testutils"
+	"PostAndComment/transfer"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransferTestSuite struct {
+	suite.Suite
+	storage *memory.InMemoryStorage
+}
+
+func (suite *TransferTestSuite) SetupTest() {
+	suite.storage = memory.New()
+}
+
+// Пост с веткой из двух уровней и черновик
+func (suite *TransferTestSuite) fill() (root, reply string) {
+	post := testutils.CreateTestPost(suite.T(), suite.storage, "Published post", true)
+	r := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, nil, "Root")
+	rep := testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &r.ID, "Reply")
+	testutils.CreateTestComment(suite.T(), suite.storage, post.ID, &rep.ID, "Deep reply")
+
+	_, err := suite.storage.NewPost(storage.NewPostParams{AuthorID: "author", Text: "Draft", Draft: true})
+	require.NoError(suite.T(), err)
+	return r.ID, rep.ID
+}
+
+// Перенос в пустое хранилище через NDJSON и JSON дает те же посты и деревья
+func (suite *TransferTestSuite) TestRoundTrip() {
+	rootID, replyID := suite.fill()
+
+	for _, format := range []transfer.Format{transfer.NDJSON, transfer.JSON} {
+		var buf bytes.Buffer
+		count, err := transfer.Export(&buf, suite.storage, format)
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 2, count)
+		if format == transfer.NDJSON {
+			assert.Equal(suite.T(), 2, strings.Count(buf.String(), "\n"))
+		} else {
+			assert.True(suite.T(), strings.HasPrefix(buf.String(), "["))
+		}
+
+		target := memory.New()
+		count, err = transfer.Import(&buf, target)
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 2, count)
+
+		source, err := suite.storage.GetPosts(10, 0, storage.TimeRange{})
+		require.NoError(suite.T(), err)
+		posts, err := target.GetPosts(10, 0, storage.TimeRange{})
+		require.NoError(suite.T(), err)
+		require.Len(suite.T(), posts, 1)
+		assert.Equal(suite.T(), source[0].ID, posts[0].ID)
+		assert.True(suite.T(), source[0].CreatedAt.Equal(posts[0].CreatedAt))
+		assert.EqualValues(suite.T(), 3, posts[0].CommentCount)
+
+		tree, err := target.GetCommentsTree(posts[0].ID, 10, 0, storage.TimeRange{})
+		require.NoError(suite.T(), err)
+		require.Len(suite.T(), tree, 1)
+		assert.Equal(suite.T(), rootID, tree[0].ID)
+		require.Len(suite.T(), tree[0].Replies, 1)
+		assert.Equal(suite.T(), replyID, tree[0].Replies[0].ID)
+		assert.EqualValues(suite.T(), 2, tree[0].DescendantCount)
+		require.Len(suite.T(), tree[0].Replies[0].Replies, 1)
+
+		drafts, err := target.GetDrafts("author", 10, 0)
+		require.NoError(suite.T(), err)
+		assert.Len(suite.T(), drafts, 1)
+	}
+}
+
+// Ошибка в записи останавливает загрузку, предыдущие посты остаются
+func (suite *TransferTestSuite) TestImport_Errors() {
+	now := time.Now().Format(time.RFC3339Nano)
+	input := `{"id":"p1","text":"first","commentsEnabled":true,"createdAt":"` + now + `","comments":[]}
+{"id":"p2","text":"second","createdAt":"` + now + `","comments":[{"id":"c1","text":"x","status":"UNKNOWN","createdAt":"` + now + `"}]}
+`
+	count, err := transfer.Import(strings.NewReader(input), suite.storage)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "post #2")
+	assert.Equal(suite.T(), 1, count)
+
+	_, err = suite.storage.GetPost("p1")
+	require.NoError(suite.T(), err)
+
+	_, err = transfer.Import(strings.NewReader(`{"id": "broken"`), suite.storage)
+	require.Error(suite.T(), err)
+
+	_, err = transfer.Export(&bytes.Buffer{}, suite.storage, "xml")
+	require.Error(suite.T(), err)
+}
+
+// Выгрузка и загрузка через GraphQL доступны только модератору
+func (suite *TransferTestSuite) TestGraphQL() {
+	suite.fill()
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: suite.storage})))
+	srv.AddTransport(transport.POST{})
+	c := client.New(auth.New([]string{"moderator"}).Middleware(srv))
+
+	var exported struct{ ExportPosts string }
+	err := c.Post(`query { exportPosts }`, &exported, asUser("author"))
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "moderator")
+
+	c.MustPost(`query { exportPosts(format: JSON) }`, &exported, asUser("moderator"))
+	assert.True(suite.T(), strings.HasPrefix(exported.ExportPosts, "["))
+
+	target := memory.New()
+	targetSrv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: target})))
+	targetSrv.AddTransport(transport.POST{})
+	targetClient := client.New(auth.New([]string{"moderator"}).Middleware(targetSrv))
+
+	var imported struct{ ImportPosts int }
+	targetClient.MustPost(`mutation($data: String!) { importPosts(data: $data) }`, &imported,
+		client.Var("data", exported.ExportPosts), asUser("moderator"))
+	assert.Equal(suite.T(), 2, imported.ImportPosts)
+}
+
+// Запуск тестов
+func TestTransferTestSuite(t *testing.T) {
+	suite.Run(t, new(TransferTestSuite))
+}
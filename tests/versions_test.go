@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"PostAndComment/auth"
+	"PostAndComment/graph"
+	"PostAndComment/storage/memory"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionsTestSuite struct {
+	suite.Suite
+	client *client.Client
+}
+
+const (
+	setCommentsEnabledVersioned = `mutation($id: ID!, $enabled: Boolean!, $version: Int) {
+		setCommentsEnabled(postID: $id, enabled: $enabled, expectedVersion: $version) { commentsEnabled version }
+	}`
+	hideContentVersioned = `mutation($id: ID!, $version: Int) {
+		hideContent(targetID: $id, expectedVersion: $version) { status version }
+	}`
+)
+
+func (suite *VersionsTestSuite) SetupTest() {
+	srv := handler.New(graph.NewExecutableSchema(graph.NewConfig(&graph.Resolver{Storage: memory.New()})))
+	srv.AddTransport(transport.POST{})
+
+	suite.client = client.New(auth.New([]string{"moderator"}).Middleware(srv))
+}
+
+func (suite *VersionsTestSuite) errors(query string, options ...client.Option) []gqlError {
+	resp, err := suite.client.RawPost(query, options...)
+	require.NoError(suite.T(), err)
+	var errs []gqlError
+	require.NoError(suite.T(), json.Unmarshal(resp.Errors, &errs))
+	return errs
+}
+
+// Пост от имени author, возвращает ID и версию
+func (suite *VersionsTestSuite) newPost() (string, int) {
+	var resp struct {
+		NewPost struct {
+			ID      string
+			Version int
+		}
+	}
+	suite.client.MustPost(`mutation { newPost(text: "post", commentsEnabled: true) { id version } }`, &resp, asUser("author"))
+	return resp.NewPost.ID, resp.NewPost.Version
+}
+
+// Два модератора переключают комментарии с одной прочитанной версии: второй получает CONFLICT
+func (suite *VersionsTestSuite) TestSetCommentsEnabled_Conflict() {
+	postID, version := suite.newPost()
+	assert.Equal(suite.T(), 1, version)
+
+	var resp struct {
+		SetCommentsEnabled struct {
+			CommentsEnabled bool
+			Version         int
+		}
+	}
+	suite.client.MustPost(setCommentsEnabledVersioned, &resp, client.Var("id", postID), client.Var("enabled", false),
+		client.Var("version", version), asUser("moderator"))
+	assert.False(suite.T(), resp.SetCommentsEnabled.CommentsEnabled)
+	assert.Equal(suite.T(), 2, resp.SetCommentsEnabled.Version)
+
+	errs := suite.errors(setCommentsEnabledVersioned, client.Var("id", postID), client.Var("enabled", true),
+		client.Var("version", version), asUser("moderator"))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "CONFLICT", errs[0].Extensions["code"])
+	assert.EqualValues(suite.T(), 2, errs[0].Extensions["currentVersion"])
+
+	var post struct {
+		GetPost struct {
+			CommentsEnabled bool
+			Version         int
+		}
+	}
+	suite.client.MustPost(`query($id: ID!) { getPost(postID: $id) { commentsEnabled version } }`, &post, client.Var("id", postID))
+	assert.False(suite.T(), post.GetPost.CommentsEnabled)
+	assert.Equal(suite.T(), 2, post.GetPost.Version)
+}
+
+// Без expectedVersion изменение применяется к любой версии
+func (suite *VersionsTestSuite) TestWithoutExpectedVersion() {
+	postID, _ := suite.newPost()
+
+	var resp struct {
+		SetCommentsEnabled struct {
+			CommentsEnabled bool
+			Version         int
+		}
+	}
+	for _, enabled := range []bool{false, true} {
+		suite.client.MustPost(setCommentsEnabledVersioned, &resp, client.Var("id", postID), client.Var("enabled", enabled),
+			client.Var("version", nil), asUser("moderator"))
+	}
+	assert.True(suite.T(), resp.SetCommentsEnabled.CommentsEnabled)
+	assert.Equal(suite.T(), 3, resp.SetCommentsEnabled.Version)
+}
+
+// Правка текста и модерация комментария проверяют версию так же
+func (suite *VersionsTestSuite) TestCommentConflict() {
+	postID, _ := suite.newPost()
+	var comment struct {
+		AddComment struct {
+			ID      string
+			Version int
+		}
+	}
+	suite.client.MustPost(`mutation($id: ID!) { addComment(postID: $id, text: "comment") { id version } }`, &comment,
+		client.Var("id", postID), asUser("alice"))
+	commentID := comment.AddComment.ID
+
+	const editComment = `mutation($id: ID!, $version: Int) {
+		editComment(commentID: $id, text: "edited", expectedVersion: $version) { version }
+	}`
+	var edited struct{ EditComment struct{ Version int } }
+	suite.client.MustPost(editComment, &edited, client.Var("id", commentID), client.Var("version", 1), asUser("alice"))
+	assert.Equal(suite.T(), 2, edited.EditComment.Version)
+
+	errs := suite.errors(hideContentVersioned, client.Var("id", commentID), client.Var("version", 1), asUser("moderator"))
+	require.Len(suite.T(), errs, 1)
+	assert.Equal(suite.T(), "CONFLICT", errs[0].Extensions["code"])
+
+	var hidden struct {
+		HideContent struct {
+			Status  string
+			Version int
+		}
+	}
+	suite.client.MustPost(hideContentVersioned, &hidden, client.Var("id", commentID), client.Var("version", 2), asUser("moderator"))
+	assert.Equal(suite.T(), "HIDDEN", hidden.HideContent.Status)
+	assert.Equal(suite.T(), 3, hidden.HideContent.Version)
+}
+
+// Запуск тестов
+func TestVersionsTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionsTestSuite))
+}
@@ -0,0 +1,246 @@
+package transfer
+
+import (
+	"PostAndComment/graph/model"
+	"PostAndComment/storage"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+	"unicode"
+)
+
+// Формат выгрузки
+type Format string
+
+const (
+	NDJSON Format = "ndjson" // Один пост на строку, удобно читать и писать потоком
+	JSON   Format = "json"   // Массив постов
+)
+
+const PageSize = 100 // Сколько постов читать из хранилища за один запрос
+
+// Пост в выгрузке. Комментарии вложены деревом, поэтому связи с родителями задаются вложенностью
+type Post struct {
+	ID                     string           `json:"id"`
+	AuthorID               *string          `json:"authorId,omitempty"`
+	Title                  string           `json:"title,omitempty"`
+	Slug                   string           `json:"slug,omitempty"`
+	Text                   string           `json:"text"`
+	Tags                   []string         `json:"tags,omitempty"`
+	CommentsEnabled        bool             `json:"commentsEnabled"`
+	Status                 model.PostStatus `json:"status"`
+	PublishAt              *time.Time       `json:"publishAt,omitempty"`
+	CloseCommentsAfterDays int32            `json:"closeCommentsAfterDays,omitempty"`
+	MaxComments            int32            `json:"maxComments,omitempty"`
+	SlowModeSeconds        int32            `json:"slowModeSeconds,omitempty"`
+	CreatedAt              time.Time        `json:"createdAt"`
+	EditedAt               *time.Time       `json:"editedAt,omitempty"`
+	Comments               []*Comment       `json:"comments"`
+}
+
+// Комментарий в выгрузке с ответами
+type Comment struct {
+	ID        string                 `json:"id"`
+	AuthorID  *string                `json:"authorId,omitempty"`
+	Text      string                 `json:"text"`
+	Status    model.ModerationStatus `json:"status"`
+	IsPinned  bool                   `json:"isPinned,omitempty"`
+	IsLocked  bool                   `json:"isLocked,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+	EditedAt  *time.Time             `json:"editedAt,omitempty"`
+	Replies   []*Comment             `json:"replies,omitempty"`
+}
+
+func ParseFormat(format string) (Format, error) {
+	switch f := Format(format); f {
+	case NDJSON, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, use %q or %q", format, NDJSON, JSON)
+	}
+}
+
+// Выгрузка всех постов хранилища в w страницами по PageSize. Возвращает число выгруженных постов
+func Export(w io.Writer, s storage.Storage, format Format) (int, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	if format == JSON {
+		out.WriteString("[")
+	}
+
+	count := 0
+	for {
+		page, err := s.ExportPosts(PageSize, int32(count))
+		if err != nil {
+			return count, fmt.Errorf("failed to read posts: %w", err)
+		}
+
+		for _, export := range page {
+			data, err := json.Marshal(toRecord(export))
+			if err != nil {
+				return count, err
+			}
+			switch {
+			case format == NDJSON:
+				out.Write(append(data, '\n'))
+			case count == 0:
+				out.WriteString("\n")
+				out.Write(data)
+			default:
+				out.WriteString(",\n")
+				out.Write(data)
+			}
+			count++
+		}
+
+		if len(page) < PageSize {
+			break
+		}
+	}
+
+	if format == JSON {
+		out.WriteString("\n]\n")
+	}
+	return count, out.Flush()
+}
+
+// Загрузка постов из NDJSON или JSON-массива (формат определяется по первому символу).
+// Посты загружаются по одному, при ошибке уже загруженные остаются в хранилище
+func Import(r io.Reader, s storage.Storage) (int, error) {
+	in := bufio.NewReader(r)
+	array, err := startsWithArray(in)
+	if err != nil {
+		return 0, err
+	}
+
+	dec := json.NewDecoder(in)
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	for !array || dec.More() {
+		var record Post
+		err := dec.Decode(&record)
+		if err == io.EOF && !array {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("post #%d: %w", count+1, err)
+		}
+		if err := s.ImportPost(record.toExport()); err != nil {
+			return count, fmt.Errorf("post #%d: %w", count+1, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Начинается ли ввод с "[" (пробелы в начале пропускаются)
+func startsWithArray(in *bufio.Reader) (bool, error) {
+	for {
+		r, _, err := in.ReadRune()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !unicode.IsSpace(r) {
+			return r == '[', in.UnreadRune()
+		}
+	}
+}
+
+func toRecord(export *storage.PostExport) *Post {
+	post := export.Post
+	record := &Post{
+		ID:                     post.ID,
+		AuthorID:               post.AuthorID,
+		Title:                  post.Title,
+		Slug:                   post.Slug,
+		Text:                   post.Text,
+		Tags:                   post.Tags,
+		CommentsEnabled:        post.CommentsEnabled,
+		Status:                 post.Status,
+		PublishAt:              post.PublishAt,
+		CloseCommentsAfterDays: export.Policy.CloseAfterDays,
+		MaxComments:            export.Policy.MaxComments,
+		SlowModeSeconds:        post.SlowModeSeconds,
+		CreatedAt:              post.CreatedAt,
+		EditedAt:               post.EditedAt,
+		Comments:               []*Comment{},
+	}
+
+	// Комментарии идут в порядке создания, поэтому родитель всегда встречается раньше ответов
+	byID := make(map[string]*Comment, len(export.Comments))
+	for _, c := range export.Comments {
+		comment := &Comment{
+			ID:        c.ID,
+			AuthorID:  c.AuthorID,
+			Text:      c.Text,
+			Status:    c.Status,
+			IsPinned:  c.IsPinned,
+			IsLocked:  c.IsLocked,
+			CreatedAt: c.CreatedAt,
+			EditedAt:  c.EditedAt,
+		}
+		byID[c.ID] = comment
+
+		if c.ParentID == nil {
+			record.Comments = append(record.Comments, comment)
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+	return record
+}
+
+func (p *Post) toExport() *storage.PostExport {
+	export := &storage.PostExport{
+		Post: &model.Post{
+			ID:              p.ID,
+			AuthorID:        p.AuthorID,
+			Title:           p.Title,
+			Slug:            p.Slug,
+			Text:            p.Text,
+			Tags:            p.Tags,
+			CommentsEnabled: p.CommentsEnabled,
+			Status:          p.Status,
+			PublishAt:       p.PublishAt,
+			SlowModeSeconds: p.SlowModeSeconds,
+			CreatedAt:       p.CreatedAt,
+			EditedAt:        p.EditedAt,
+		},
+		Policy: storage.CommentsPolicy{CloseAfterDays: p.CloseCommentsAfterDays, MaxComments: p.MaxComments},
+	}
+
+	var flatten func(comments []*Comment, parentID *string)
+	flatten = func(comments []*Comment, parentID *string) {
+		for _, c := range comments {
+			export.Comments = append(export.Comments, &model.Comment{
+				ID:        c.ID,
+				PostID:    p.ID,
+				ParentID:  parentID,
+				AuthorID:  c.AuthorID,
+				Text:      c.Text,
+				Status:    c.Status,
+				IsPinned:  c.IsPinned,
+				IsLocked:  c.IsLocked,
+				CreatedAt: c.CreatedAt,
+				EditedAt:  c.EditedAt,
+			})
+			id := c.ID
+			flatten(c.Replies, &id)
+		}
+	}
+	flatten(p.Comments, nil)
+	return export
+}